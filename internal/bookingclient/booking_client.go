@@ -0,0 +1,70 @@
+package bookingclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// ErrNotFound — брони с таким ID нет
+var ErrNotFound = errors.New("booking not found")
+
+// Booking — структура, ожидаемая от Booking-service
+type Booking struct {
+	ID        string `json:"id"`
+	ListingID string `json:"listingId"`
+	UserID    string `json:"userId"` // гость, который бронирует
+}
+
+// Client — HTTP клиент для связи с Booking-service
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// New создаёт новый клиент
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: 5 * time.Second,
+			// Спан на каждый запрос и W3C traceparent в заголовках
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+// GetByID — отправляет GET-запрос на /api/bookings/{id}
+func (c *Client) GetByID(ctx context.Context, bookingID string) (Booking, error) {
+	u := fmt.Sprintf("%s/api/bookings/%s", c.BaseURL, url.PathEscape(bookingID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Booking{}, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return Booking{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Booking{}, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Booking{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var booking Booking
+	if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+		return Booking{}, fmt.Errorf("decode response: %w", err)
+	}
+	return booking, nil
+}
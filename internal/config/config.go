@@ -5,30 +5,121 @@ import (
 	"github.com/joho/godotenv"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config хранит все нужные настройки из окружения
 type Config struct {
-	Port                int    ` env:"PORT,required"`
-	DatabaseURL         string `env:"DATABASE_URL,required"`
-	JWTSecret           string `env:"JWT_SECRET,required"`
-	UserServiceURL      string `env:"USER_SERVICE_URL,required" ` // ← вот это поле
-	StripeSecretKey     string `env:"STRIPE_SECRET_KEY,required"`
-	StripeWebhookSecret string `env:"STRIPE_WEBHOOK_SECRET,required"`
+	Port                int           ` env:"PORT,required"`
+	DatabaseURL         string        `env:"DATABASE_URL,required"`
+	JWTSecret           string        `env:"JWT_SECRET"`                 // для HS256-токенов
+	JWKSURL             string        `env:"JWT_JWKS_URL"`               // JWKS для RS256/ES256-токенов...
+	JWKSFile            string        `env:"JWT_JWKS_FILE"`              // ...или локальный файл с ним
+	JWKSRefresh         time.Duration `env:"JWT_JWKS_REFRESH"`           // как долго кэшировать ключи
+	JWTAlgorithms       []string      `env:"JWT_ALGORITHMS"`             // через запятую; по умолчанию по настроенным ключам
+	JWTIssuer           string        `env:"JWT_ISSUER"`                 // ожидаемый iss
+	JWTAudience         []string      `env:"JWT_AUDIENCE"`               // через запятую; допустимые aud
+	JWTUserIDClaim      string        `env:"JWT_USER_ID_CLAIM"`          // claim с ID пользователя; без него ID берётся из User-service
+	JWTLeeway           time.Duration `env:"JWT_LEEWAY"`                 // допуск расхождения часов для exp/nbf
+	UserServiceURL      string        `env:"USER_SERVICE_URL,required" ` // ← вот это поле
+	UserCacheTTL        time.Duration `env:"USER_CACHE_TTL"`             // сколько кэшировать пользователей User-service
+	ListingServiceURL   string        `env:"LISTING_SERVICE_URL"`        // без него хосты объявлений не получают доступ к платежам
+	BookingServiceURL   string        `env:"BOOKING_SERVICE_URL"`        // без него listing_id из запроса не сверяется с бронью
+	StripeSecretKey     string        `env:"STRIPE_SECRET_KEY,required"`
+	StripeWebhookSecret string        `env:"STRIPE_WEBHOOK_SECRET,required"`
+	PaymentGateway      string        `env:"PAYMENT_GATEWAY"`          // "stripe" (по умолчанию) или "fake"
+	FakeWebhookURL      string        `env:"FAKE_GATEWAY_WEBHOOK_URL"` // куда fake-шлюз шлёт события
+	AutoMigrate         bool          `env:"AUTO_MIGRATE"`             // накатывать миграции при старте
+	AdminEmails         []string      `env:"ADMIN_EMAILS"`             // через запятую; доступ к /admin
+	EventRetryInterval  time.Duration `env:"EVENT_RETRY_INTERVAL"`     // как часто повторять упавшие события Stripe
+
+	OutboxPublisher     string        `env:"OUTBOX_PUBLISHER"`      // "log" (по умолчанию), "http", "nats" или "kafka"
+	OutboxRelayInterval time.Duration `env:"OUTBOX_RELAY_INTERVAL"` // как часто relay проверяет outbox
+	OutboxHTTPURL       string        `env:"OUTBOX_HTTP_URL"`       // для OUTBOX_PUBLISHER=http
+	NATSURL             string        `env:"NATS_URL"`              // для OUTBOX_PUBLISHER=nats
+	NATSSubjectPrefix   string        `env:"NATS_SUBJECT_PREFIX"`   // subject = <prefix>.<тип события>
+	KafkaBrokers        []string      `env:"KAFKA_BROKERS"`         // через запятую; для OUTBOX_PUBLISHER=kafka
+	KafkaTopic          string        `env:"KAFKA_TOPIC"`
+
+	WebhookDispatchInterval time.Duration `env:"WEBHOOK_DISPATCH_INTERVAL"` // как часто отправлять исходящие webhooks
+
+	TracingExporter    string  `env:"TRACING_EXPORTER"`     // "none" (по умолчанию), "otlp" или "stdout"
+	OTLPEndpoint       string  `env:"OTLP_ENDPOINT"`        // URL OTLP/HTTP-коллектора, например http://otel-collector:4318
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO"` // доля новых трассировок, от 0 до 1
+
+	LogLevel  string `env:"LOG_LEVEL"`  // debug, info (по умолчанию), warn или error
+	LogFormat string `env:"LOG_FORMAT"` // "json" (по умолчанию) или "text"
+
+	PlatformFeeBPS int64 `env:"PLATFORM_FEE_BPS"` // комиссия платформы с платежей в базисных пунктах (1% = 100)
+
+	StripeConnectWebhookSecret string        `env:"STRIPE_CONNECT_WEBHOOK_SECRET"` // секрет Connect-endpoint'а; пусто — /stripe/connect/webhook выключен
+	ConnectRefreshURL          string        `env:"CONNECT_REFRESH_URL"`           // куда Stripe вернёт хоста с просроченной ссылкой онбординга
+	ConnectReturnURL           string        `env:"CONNECT_RETURN_URL"`            // куда Stripe вернёт хоста после онбординга
+	HostTransferInterval       time.Duration `env:"HOST_TRANSFER_INTERVAL"`        // как часто выполнять переводы хостам
+
+	ReconcileInterval time.Duration `env:"RECONCILE_INTERVAL"` // как часто сверять платежи со Stripe
+	ReconcileWindow   time.Duration `env:"RECONCILE_WINDOW"`   // за какой срок сверяются созданные PaymentIntents
+
+	DepositHoldTTL        time.Duration `env:"DEPOSIT_HOLD_TTL"`         // сколько действует авторизация депозита
+	DepositHoldNotice     time.Duration `env:"DEPOSIT_HOLD_NOTICE"`      // за сколько до истечения публиковать deposit.hold_expiring
+	DepositHoldActionLead time.Duration `env:"DEPOSIT_HOLD_ACTION_LEAD"` // за сколько до истечения списывать или отпускать hold
+	DepositExpiryAction   string        `env:"DEPOSIT_EXPIRY_ACTION"`    // "none" (по умолчанию), "capture" или "release" без политики брони и объявления
+	HoldExpiryInterval    time.Duration `env:"HOLD_EXPIRY_INTERVAL"`     // как часто проверять истекающие депозиты
+
+	MetricsPort     int           `env:"METRICS_PORT"`      // отдельный порт для /metrics, не открывается наружу; 0 — выключено; по умолчанию 9090 (9091 при PORT=9090)
+	MetricsStatsTTL time.Duration `env:"METRICS_STATS_TTL"` // как долго кэшировать статистику платежей из базы между scrape
 }
 
+// Допустимые значения PAYMENT_GATEWAY
+const (
+	GatewayStripe = "stripe"
+	GatewayFake   = "fake"
+)
+
+// Допустимые значения DEPOSIT_EXPIRY_ACTION
+const (
+	HoldActionNone    = "none"
+	HoldActionCapture = "capture"
+	HoldActionRelease = "release"
+)
+
+// Допустимые значения OUTBOX_PUBLISHER
+const (
+	PublisherLog   = "log"
+	PublisherHTTP  = "http"
+	PublisherNATS  = "nats"
+	PublisherKafka = "kafka"
+)
+
+// Допустимые значения TRACING_EXPORTER
+const (
+	TracingNone   = "none"
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+)
+
+// Допустимые значения LOG_FORMAT
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
 // Load читает .env и парсит переменнfunc
 func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	cfg := &Config{}
 
-	cfg.DatabaseURL = os.Getenv("DATABASE_URL")
-	if cfg.DatabaseURL == "" {
-		return nil, fmt.Errorf("DATABASE_URL must be set")
+	dbURL, err := LoadDatabaseURL()
+	if err != nil {
+		return nil, err
 	}
+	cfg.DatabaseURL = dbURL
 
-	cfg.JWTSecret = os.Getenv("JWT_SECRET")
+	if err := loadJWT(cfg); err != nil {
+		return nil, err
+	}
 
 	portStr := os.Getenv("PORT")
 	if portStr == "" {
@@ -40,18 +131,314 @@ func Load() (*Config, error) {
 	}
 	cfg.Port = port
 
-	cfg.StripeSecretKey = os.Getenv("STRIPE_SECRET_KEY")
-	if cfg.StripeSecretKey == "" {
-		return nil, fmt.Errorf("STRIPE_SECRET_KEY must be set")
+	if v := os.Getenv("METRICS_PORT"); v != "" {
+		cfg.MetricsPort, err = strconv.Atoi(v)
+		if err != nil || cfg.MetricsPort < 0 {
+			return nil, fmt.Errorf("invalid METRICS_PORT: %q", v)
+		}
+		if cfg.MetricsPort == cfg.Port {
+			return nil, fmt.Errorf("METRICS_PORT must differ from PORT: /metrics is not served on the public port")
+		}
+	} else {
+		// По умолчанию 9090, а если его занял PORT — соседний порт
+		cfg.MetricsPort = 9090
+		if cfg.Port == cfg.MetricsPort {
+			cfg.MetricsPort = 9091
+		}
+	}
+	cfg.MetricsStatsTTL, err = durationEnv("METRICS_STATS_TTL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	if v := os.Getenv("AUTO_MIGRATE"); v != "" {
+		cfg.AutoMigrate, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTO_MIGRATE: %w", err)
+		}
+	}
+
+	cfg.AdminEmails = splitList(os.Getenv("ADMIN_EMAILS"))
+
+	cfg.EventRetryInterval, err = durationEnv("EVENT_RETRY_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadOutbox(cfg); err != nil {
+		return nil, err
+	}
+
+	cfg.WebhookDispatchInterval, err = durationEnv("WEBHOOK_DISPATCH_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadTracing(cfg); err != nil {
+		return nil, err
+	}
+
+	cfg.LogLevel = strings.ToLower(os.Getenv("LOG_LEVEL"))
+	switch cfg.LogLevel {
+	case "":
+		cfg.LogLevel = "info"
+	case "debug", "info", "warn", "error":
+	default:
+		return nil, fmt.Errorf("invalid LOG_LEVEL: %q", cfg.LogLevel)
+	}
+	cfg.LogFormat = os.Getenv("LOG_FORMAT")
+	switch cfg.LogFormat {
+	case "":
+		cfg.LogFormat = LogFormatJSON
+	case LogFormatJSON, LogFormatText:
+	default:
+		return nil, fmt.Errorf("invalid LOG_FORMAT: %q", cfg.LogFormat)
+	}
+
+	cfg.PaymentGateway = os.Getenv("PAYMENT_GATEWAY")
+	if cfg.PaymentGateway == "" {
+		cfg.PaymentGateway = GatewayStripe
 	}
 
+	cfg.StripeSecretKey = os.Getenv("STRIPE_SECRET_KEY")
 	cfg.StripeWebhookSecret = os.Getenv("STRIPE_WEBHOOK_SECRET")
 
+	switch cfg.PaymentGateway {
+	case GatewayStripe:
+		if cfg.StripeSecretKey == "" {
+			return nil, fmt.Errorf("STRIPE_SECRET_KEY must be set")
+		}
+	case GatewayFake:
+		// Fake-шлюз подписывает события тем же секретом, что проверяет WebhookHandler
+		if cfg.StripeWebhookSecret == "" {
+			cfg.StripeWebhookSecret = "whsec_fake"
+		}
+		cfg.FakeWebhookURL = os.Getenv("FAKE_GATEWAY_WEBHOOK_URL")
+		if cfg.FakeWebhookURL == "" {
+			cfg.FakeWebhookURL = fmt.Sprintf("http://localhost:%d/stripe/webhook", cfg.Port)
+		}
+	default:
+		return nil, fmt.Errorf("invalid PAYMENT_GATEWAY: %q", cfg.PaymentGateway)
+	}
+
 	// ✅ Вот это добавь
 	cfg.UserServiceURL = os.Getenv("USER_SERVICE_URL")
 	if cfg.UserServiceURL == "" {
 		return nil, fmt.Errorf("USER_SERVICE_URL must be set")
 	}
+	cfg.UserCacheTTL, err = durationEnv("USER_CACHE_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ListingServiceURL = os.Getenv("LISTING_SERVICE_URL")
+	cfg.BookingServiceURL = os.Getenv("BOOKING_SERVICE_URL")
+
+	if v := os.Getenv("PLATFORM_FEE_BPS"); v != "" {
+		cfg.PlatformFeeBPS, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || cfg.PlatformFeeBPS < 0 || cfg.PlatformFeeBPS > 10000 {
+			return nil, fmt.Errorf("invalid PLATFORM_FEE_BPS: %q", v)
+		}
+	}
+
+	cfg.StripeConnectWebhookSecret = os.Getenv("STRIPE_CONNECT_WEBHOOK_SECRET")
+	cfg.ConnectRefreshURL = os.Getenv("CONNECT_REFRESH_URL")
+	cfg.ConnectReturnURL = os.Getenv("CONNECT_RETURN_URL")
+	cfg.HostTransferInterval, err = durationEnv("HOST_TRANSFER_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ReconcileInterval, err = durationEnv("RECONCILE_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ReconcileWindow, err = durationEnv("RECONCILE_WINDOW", 72*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadHoldExpiry(cfg); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
+
+// LoadDatabaseURL читает только DATABASE_URL — этого достаточно для `migrate`.
+func LoadDatabaseURL() (string, error) {
+	_ = godotenv.Load()
+
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		return "", fmt.Errorf("DATABASE_URL must be set")
+	}
+	return dbURL, nil
+}
+
+// loadHoldExpiry читает настройки истечения авторизации депозитов. Stripe
+// держит авторизацию карты около семи дней.
+func loadHoldExpiry(cfg *Config) error {
+	var err error
+	if cfg.DepositHoldTTL, err = durationEnv("DEPOSIT_HOLD_TTL", 7*24*time.Hour); err != nil {
+		return err
+	}
+	if cfg.DepositHoldNotice, err = durationEnv("DEPOSIT_HOLD_NOTICE", 24*time.Hour); err != nil {
+		return err
+	}
+	if cfg.DepositHoldActionLead, err = durationEnv("DEPOSIT_HOLD_ACTION_LEAD", 2*time.Hour); err != nil {
+		return err
+	}
+	if cfg.HoldExpiryInterval, err = durationEnv("HOLD_EXPIRY_INTERVAL", 5*time.Minute); err != nil {
+		return err
+	}
+	cfg.DepositExpiryAction = os.Getenv("DEPOSIT_EXPIRY_ACTION")
+	switch cfg.DepositExpiryAction {
+	case "":
+		cfg.DepositExpiryAction = HoldActionNone
+	case HoldActionNone, HoldActionCapture, HoldActionRelease:
+	default:
+		return fmt.Errorf("invalid DEPOSIT_EXPIRY_ACTION: %q", cfg.DepositExpiryAction)
+	}
+	return nil
+}
+
+// loadJWT читает настройки проверки токенов. Допустимые алгоритмы по умолчанию
+// определяются настроенными ключами: HS256 для JWT_SECRET, RS256 и ES256 для JWKS.
+func loadJWT(cfg *Config) error {
+	var err error
+	cfg.JWTSecret = os.Getenv("JWT_SECRET")
+	cfg.JWKSURL = os.Getenv("JWT_JWKS_URL")
+	cfg.JWKSFile = os.Getenv("JWT_JWKS_FILE")
+	if cfg.JWKSURL != "" && cfg.JWKSFile != "" {
+		return fmt.Errorf("only one of JWT_JWKS_URL and JWT_JWKS_FILE can be set")
+	}
+	hasJWKS := cfg.JWKSURL != "" || cfg.JWKSFile != ""
+	if cfg.JWTSecret == "" && !hasJWKS {
+		return fmt.Errorf("JWT_SECRET or JWT_JWKS_URL/JWT_JWKS_FILE must be set")
+	}
+
+	cfg.JWTAlgorithms = splitList(os.Getenv("JWT_ALGORITHMS"))
+	if len(cfg.JWTAlgorithms) == 0 {
+		if cfg.JWTSecret != "" {
+			cfg.JWTAlgorithms = append(cfg.JWTAlgorithms, "HS256")
+		}
+		if hasJWKS {
+			cfg.JWTAlgorithms = append(cfg.JWTAlgorithms, "RS256", "ES256")
+		}
+	}
+	for _, alg := range cfg.JWTAlgorithms {
+		switch alg {
+		case "HS256", "HS384", "HS512":
+			if cfg.JWTSecret == "" {
+				return fmt.Errorf("JWT_ALGORITHMS: %s requires JWT_SECRET", alg)
+			}
+		case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512":
+			if !hasJWKS {
+				return fmt.Errorf("JWT_ALGORITHMS: %s requires JWT_JWKS_URL or JWT_JWKS_FILE", alg)
+			}
+		default:
+			return fmt.Errorf("JWT_ALGORITHMS: unsupported algorithm %q", alg)
+		}
+	}
+
+	cfg.JWTIssuer = os.Getenv("JWT_ISSUER")
+	cfg.JWTAudience = splitList(os.Getenv("JWT_AUDIENCE"))
+	cfg.JWTUserIDClaim = os.Getenv("JWT_USER_ID_CLAIM")
+	if cfg.JWTUserIDClaim == "" {
+		cfg.JWTUserIDClaim = "user_id"
+	}
+	cfg.JWTLeeway, err = durationEnv("JWT_LEEWAY", 30*time.Second)
+	if err != nil {
+		return err
+	}
+	cfg.JWKSRefresh, err = durationEnv("JWT_JWKS_REFRESH", 10*time.Minute)
+	return err
+}
+
+// loadOutbox читает настройки публикации событий из outbox.
+func loadOutbox(cfg *Config) error {
+	var err error
+	cfg.OutboxRelayInterval, err = durationEnv("OUTBOX_RELAY_INTERVAL", 2*time.Second)
+	if err != nil {
+		return err
+	}
+
+	cfg.OutboxPublisher = os.Getenv("OUTBOX_PUBLISHER")
+	switch cfg.OutboxPublisher {
+	case "", PublisherLog:
+		cfg.OutboxPublisher = PublisherLog
+	case PublisherHTTP:
+		cfg.OutboxHTTPURL = os.Getenv("OUTBOX_HTTP_URL")
+		if cfg.OutboxHTTPURL == "" {
+			return fmt.Errorf("OUTBOX_HTTP_URL must be set for OUTBOX_PUBLISHER=http")
+		}
+	case PublisherNATS:
+		cfg.NATSURL = os.Getenv("NATS_URL")
+		if cfg.NATSURL == "" {
+			return fmt.Errorf("NATS_URL must be set for OUTBOX_PUBLISHER=nats")
+		}
+		cfg.NATSSubjectPrefix = os.Getenv("NATS_SUBJECT_PREFIX")
+		if cfg.NATSSubjectPrefix == "" {
+			cfg.NATSSubjectPrefix = "payments"
+		}
+	case PublisherKafka:
+		cfg.KafkaBrokers = splitList(os.Getenv("KAFKA_BROKERS"))
+		if len(cfg.KafkaBrokers) == 0 {
+			return fmt.Errorf("KAFKA_BROKERS must be set for OUTBOX_PUBLISHER=kafka")
+		}
+		cfg.KafkaTopic = os.Getenv("KAFKA_TOPIC")
+		if cfg.KafkaTopic == "" {
+			cfg.KafkaTopic = "payment-events"
+		}
+	default:
+		return fmt.Errorf("invalid OUTBOX_PUBLISHER: %q", cfg.OutboxPublisher)
+	}
+	return nil
+}
+
+// loadTracing читает настройки экспорта спанов OpenTelemetry.
+func loadTracing(cfg *Config) error {
+	cfg.TracingExporter = os.Getenv("TRACING_EXPORTER")
+	switch cfg.TracingExporter {
+	case "":
+		cfg.TracingExporter = TracingNone
+	case TracingNone, TracingOTLP, TracingStdout:
+	default:
+		return fmt.Errorf("invalid TRACING_EXPORTER: %q", cfg.TracingExporter)
+	}
+	cfg.OTLPEndpoint = os.Getenv("OTLP_ENDPOINT")
+
+	cfg.TracingSampleRatio = 1
+	if v := os.Getenv("TRACING_SAMPLE_RATIO"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 || r > 1 {
+			return fmt.Errorf("invalid TRACING_SAMPLE_RATIO: %q", v)
+		}
+		cfg.TracingSampleRatio = r
+	}
+	return nil
+}
+
+// splitList разбирает список через запятую, пропуская пустые элементы.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// durationEnv читает длительность вида "30s" или возвращает значение по умолчанию.
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,122 @@
+// internal/events/events.go
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Aggregate types: the entity an event belongs to. Events of one aggregate
+// are delivered in the order they were recorded.
+const (
+	AggregateCustomer      = "customer"
+	AggregatePaymentMethod = "payment_method"
+	AggregatePayment       = "payment"
+	AggregateDeposit       = "deposit"
+	AggregateRefund        = "refund"
+)
+
+// Event types published to other services.
+const (
+	TypeCustomerCreated      = "customer.created"
+	TypePaymentMethodSaved   = "payment_method.saved"
+	TypePaymentCreated       = "payment.created"
+	TypePaymentStatusChanged = "payment.status_changed"
+	TypeDepositCreated       = "deposit.created"
+	TypeDepositStatusChanged = "deposit.status_changed"
+	TypeDepositHoldExpiring  = "deposit.hold_expiring"
+	TypeRefundCreated        = "refund.created"
+	TypeRefundStatusChanged  = "refund.status_changed"
+)
+
+// Types lists every event type, e.g. for validating subscription filters.
+var Types = []string{
+	TypeCustomerCreated,
+	TypePaymentMethodSaved,
+	TypePaymentCreated,
+	TypePaymentStatusChanged,
+	TypeDepositCreated,
+	TypeDepositStatusChanged,
+	TypeDepositHoldExpiring,
+	TypeRefundCreated,
+	TypeRefundStatusChanged,
+}
+
+// IsKnownType reports whether t is one of Types.
+func IsKnownType(t string) bool {
+	for _, known := range Types {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Envelope is the message delivered to subscribers. ID is unique and stable
+// across redeliveries, so consumers can use it to drop duplicates.
+type Envelope struct {
+	ID            int64           `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// Customer is the data of customer.* events.
+type Customer struct {
+	UserID           string `json:"user_id"`
+	StripeCustomerID string `json:"stripe_customer_id"`
+}
+
+// PaymentMethod is the data of payment_method.* events.
+type PaymentMethod struct {
+	UserID          string `json:"user_id"`
+	PaymentMethodID string `json:"payment_method_id"`
+	Brand           string `json:"brand"`
+	Last4           string `json:"last4"`
+	ExpMonth        int    `json:"exp_month"`
+	ExpYear         int    `json:"exp_year"`
+}
+
+// Payment is the data of payment.* events.
+type Payment struct {
+	PaymentIntentID string `json:"payment_intent_id"`
+	BookingID       string `json:"booking_id"`
+	ListingID       string `json:"listing_id,omitempty"`
+	UserID          string `json:"user_id"`
+	Amount          int64  `json:"amount"`
+	Currency        string `json:"currency"`
+	Status          string `json:"status"`
+	PreviousStatus  string `json:"previous_status,omitempty"`
+	Source          string `json:"source,omitempty"`
+}
+
+// Deposit is the data of deposit.* events.
+type Deposit struct {
+	DepositID      string `json:"deposit_id"`
+	BookingID      string `json:"booking_id"`
+	ListingID      string `json:"listing_id"`
+	UserID         string `json:"user_id"`
+	Amount         int64  `json:"amount"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	Source         string `json:"source,omitempty"`
+	CapturedAmount int64  `json:"captured_amount"`
+	CaptureReason  string `json:"capture_reason,omitempty"`
+	// HoldExpiresAt is when the authorization lapses; set once the deposit is authorized.
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+}
+
+// Refund is the data of refund.* events.
+type Refund struct {
+	RefundID        int64  `json:"refund_id"`
+	StripeRefundID  string `json:"stripe_refund_id,omitempty"`
+	PaymentIntentID string `json:"payment_intent_id"`
+	Kind            string `json:"kind"`
+	Amount          int64  `json:"amount"`
+	Currency        string `json:"currency"`
+	Reason          string `json:"reason,omitempty"`
+	Status          string `json:"status"`
+}
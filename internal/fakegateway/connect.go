@@ -0,0 +1,183 @@
+// internal/fakegateway/connect.go
+package fakegateway
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"Payment-service/internal/gateway"
+)
+
+var _ gateway.ConnectGateway = (*Gateway)(nil)
+
+// accountLinkTTL mirrors how long Stripe account links stay valid.
+const accountLinkTTL = 5 * time.Minute
+
+// CreateConnectedAccount creates an account that has not finished onboarding.
+func (g *Gateway) CreateConnectedAccount(ctx context.Context, hostID, email string) (*gateway.ConnectedAccount, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	a := &gateway.ConnectedAccount{ID: g.newID("acct"), HostID: hostID}
+	g.accounts[a.ID] = a
+	out := *a
+	return &out, nil
+}
+
+// RetrieveConnectedAccount returns the account state.
+func (g *Gateway) RetrieveConnectedAccount(ctx context.Context, accountID string) (*gateway.ConnectedAccount, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	a, ok := g.accounts[accountID]
+	if !ok {
+		return nil, fmt.Errorf("%w: account %s", ErrNotFound, accountID)
+	}
+	out := *a
+	return &out, nil
+}
+
+// CreateAccountLink points to the fake onboarding endpoint instead of Stripe's hosted form.
+func (g *Gateway) CreateAccountLink(ctx context.Context, accountID, refreshURL, returnURL string) (*gateway.AccountLink, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.accounts[accountID]; !ok {
+		return nil, fmt.Errorf("%w: account %s", ErrNotFound, accountID)
+	}
+	return &gateway.AccountLink{
+		URL:       "/fake-gateway/accounts/" + accountID + "/onboard",
+		ExpiresAt: time.Now().Add(accountLinkTTL),
+	}, nil
+}
+
+// CompleteOnboarding plays the role of the hosted onboarding form: it enables
+// charges and payouts on the account and emits account.updated.
+func (g *Gateway) CompleteOnboarding(ctx context.Context, accountID string) (*gateway.ConnectedAccount, error) {
+	g.mu.Lock()
+	a, ok := g.accounts[accountID]
+	if !ok {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: account %s", ErrNotFound, accountID)
+	}
+	a.ChargesEnabled = true
+	a.PayoutsEnabled = true
+	a.DetailsSubmitted = true
+	out, obj := *a, accountObject(a)
+	g.mu.Unlock()
+
+	g.emitter.emit("account.updated", obj)
+	return &out, nil
+}
+
+// CreateTransfer records a transfer to an account with payouts enabled.
+func (g *Gateway) CreateTransfer(ctx context.Context, p gateway.TransferParams) (*gateway.Transfer, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	a, ok := g.accounts[p.AccountID]
+	if !ok {
+		return nil, fmt.Errorf("%w: account %s", ErrNotFound, p.AccountID)
+	}
+	if !a.PayoutsEnabled {
+		return nil, fmt.Errorf("%w: account %s cannot receive transfers", ErrInvalidState, p.AccountID)
+	}
+	if p.PaymentIntentID != "" {
+		pi, ok := g.paymentIntents[p.PaymentIntentID]
+		if !ok {
+			return nil, fmt.Errorf("%w: payment intent %s", ErrNotFound, p.PaymentIntentID)
+		}
+		if pi.Status != gateway.StatusSucceeded {
+			return nil, fmt.Errorf("%w: payment intent is %s", ErrInvalidState, pi.Status)
+		}
+	}
+	if t, ok := g.transfers[p.IdempotencyKey]; ok && p.IdempotencyKey != "" {
+		out := *t
+		return &out, nil
+	}
+	t := &gateway.Transfer{ID: g.newID("tr"), AccountID: p.AccountID, Amount: p.Amount, Currency: p.Currency}
+	key := p.IdempotencyKey
+	if key == "" {
+		key = t.ID
+	}
+	g.transfers[key] = t
+	out := *t
+	return &out, nil
+}
+
+// CreateTransferReversal returns part of a transfer; together with earlier
+// reversals it cannot exceed the transfer.
+func (g *Gateway) CreateTransferReversal(ctx context.Context, p gateway.TransferReversalParams) (*gateway.TransferReversal, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r, ok := g.reversals[p.IdempotencyKey]; ok && p.IdempotencyKey != "" {
+		out := *r
+		return &out, nil
+	}
+	t := g.transferByID(p.TransferID)
+	if t == nil {
+		return nil, fmt.Errorf("%w: transfer %s", ErrNotFound, p.TransferID)
+	}
+	if p.Amount <= 0 || p.Amount > t.Amount-g.reversedLocked(t.ID) {
+		return nil, fmt.Errorf("%w: reversal exceeds the unreversed amount of %s", ErrInvalidState, t.ID)
+	}
+	r := &gateway.TransferReversal{ID: g.newID("trr"), TransferID: t.ID, Amount: p.Amount, Currency: t.Currency}
+	key := p.IdempotencyKey
+	if key == "" {
+		key = r.ID
+	}
+	g.reversals[key] = r
+	out := *r
+	return &out, nil
+}
+
+// transferByID must be called with g.mu held; transfers are keyed by idempotency key.
+func (g *Gateway) transferByID(id string) *gateway.Transfer {
+	for _, t := range g.transfers {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// reversedLocked must be called with g.mu held.
+func (g *Gateway) reversedLocked(transferID string) int64 {
+	var sum int64
+	for _, r := range g.reversals {
+		if r.TransferID == transferID {
+			sum += r.Amount
+		}
+	}
+	return sum
+}
+
+// GetAccountBalance reports every transfer to the account, less reversals, as
+// available: the fake gateway has no settlement delay and never pays out.
+func (g *Gateway) GetAccountBalance(ctx context.Context, accountID string) (*gateway.AccountBalance, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.accounts[accountID]; !ok {
+		return nil, fmt.Errorf("%w: account %s", ErrNotFound, accountID)
+	}
+	byCurrency := make(map[string]int64)
+	for _, t := range g.transfers {
+		if t.AccountID == accountID {
+			byCurrency[t.Currency] += t.Amount - g.reversedLocked(t.ID)
+		}
+	}
+	b := &gateway.AccountBalance{Available: []gateway.Money{}, Pending: []gateway.Money{}}
+	for currency, amount := range byCurrency {
+		b.Available = append(b.Available, gateway.Money{Amount: amount, Currency: currency})
+	}
+	sort.Slice(b.Available, func(i, j int) bool { return b.Available[i].Currency < b.Available[j].Currency })
+	return b, nil
+}
+
+// ListPayouts always returns no payouts.
+func (g *Gateway) ListPayouts(ctx context.Context, accountID string, limit int) ([]gateway.Payout, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.accounts[accountID]; !ok {
+		return nil, fmt.Errorf("%w: account %s", ErrNotFound, accountID)
+	}
+	return []gateway.Payout{}, nil
+}
@@ -0,0 +1,197 @@
+// internal/fakegateway/events.go
+package fakegateway
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"Payment-service/internal/gateway"
+
+	stripepkg "github.com/stripe/stripe-go/v74"
+	stripeWebhook "github.com/stripe/stripe-go/v74/webhook"
+)
+
+// emitter posts Stripe-shaped events to the webhook endpoint one by one,
+// so the receiver sees them in the order they happened.
+type emitter struct {
+	url        string
+	secret     string
+	httpClient *http.Client
+	queue      chan []byte
+}
+
+func newEmitter(url, secret string) *emitter {
+	e := &emitter{
+		url:        url,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		queue:      make(chan []byte, 256),
+	}
+	if url != "" {
+		go e.run()
+	}
+	return e
+}
+
+func (e *emitter) emit(eventType string, object map[string]interface{}) {
+	if e.url == "" {
+		return
+	}
+	event := map[string]interface{}{
+		"id":               "evt_fake" + randomHex(12),
+		"object":           "event",
+		"api_version":      stripepkg.APIVersion,
+		"created":          time.Now().Unix(),
+		"livemode":         false,
+		"pending_webhooks": 1,
+		"type":             eventType,
+		"data":             map[string]interface{}{"object": object},
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("fakegateway: marshal event", "event_type", eventType, "error", err)
+		return
+	}
+	select {
+	case e.queue <- payload:
+	default:
+		slog.Warn("fakegateway: event queue full, dropping event", "event_type", eventType)
+	}
+}
+
+func (e *emitter) run() {
+	for payload := range e.queue {
+		if err := e.deliver(payload); err != nil {
+			slog.Error("fakegateway: deliver event", "error", err)
+		}
+	}
+}
+
+func (e *emitter) deliver(payload []byte) error {
+	now := time.Now()
+	sig := stripeWebhook.ComputeSignature(now, payload, e.secret)
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", fmt.Sprintf("t=%d,v1=%s", now.Unix(), hex.EncodeToString(sig)))
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// The *Object helpers render objects in Stripe's JSON shape so the regular
+// webhook handler can unmarshal them into stripe-go types.
+
+func paymentIntentObject(pi *paymentIntent) map[string]interface{} {
+	captureMethod := "automatic"
+	if pi.manualCapture {
+		captureMethod = "manual"
+	}
+	obj := map[string]interface{}{
+		"id":                pi.ID,
+		"object":            "payment_intent",
+		"amount":            pi.Amount,
+		"amount_capturable": pi.AmountCapturable,
+		"amount_received":   pi.AmountReceived,
+		"capture_method":    captureMethod,
+		"client_secret":     pi.ClientSecret,
+		"currency":          pi.Currency,
+		"customer":          pi.CustomerID,
+		"livemode":          false,
+		"metadata":          pi.Metadata,
+		"status":            pi.Status,
+	}
+	if pi.paymentMethodID != "" {
+		obj["payment_method"] = pi.paymentMethodID
+	}
+	if pi.declineCode != "" {
+		obj["last_payment_error"] = map[string]interface{}{
+			"type":         "card_error",
+			"code":         "card_declined",
+			"decline_code": pi.declineCode,
+			"message":      "Your card was declined.",
+		}
+	}
+	return obj
+}
+
+func setupIntentObject(si *setupIntent, userID string) map[string]interface{} {
+	obj := map[string]interface{}{
+		"id":            si.ID,
+		"object":        "setup_intent",
+		"client_secret": si.ClientSecret,
+		"customer":      si.CustomerID,
+		"livemode":      false,
+		"metadata":      map[string]string{"user_id": userID},
+		"status":        si.Status,
+		"usage":         si.usage,
+	}
+	if si.paymentMethodID != "" {
+		obj["payment_method"] = si.paymentMethodID
+	}
+	return obj
+}
+
+func chargeObject(pi *paymentIntent) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              "ch_" + pi.ID,
+		"object":          "charge",
+		"amount":          pi.Amount,
+		"amount_captured": pi.AmountReceived,
+		"amount_refunded": pi.amountRefunded,
+		"currency":        pi.Currency,
+		"customer":        pi.CustomerID,
+		"livemode":        false,
+		"metadata":        pi.Metadata,
+		"payment_intent":  pi.ID,
+		"refunded":        pi.amountRefunded >= pi.AmountReceived,
+		"status":          "succeeded",
+	}
+}
+
+// refundObject — refund в том виде, в каком его присылают события refund.*
+func refundObject(r *gateway.Refund) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             r.ID,
+		"object":         "refund",
+		"amount":         r.Amount,
+		"charge":         "ch_" + r.PaymentIntentID,
+		"currency":       r.Currency,
+		"payment_intent": r.PaymentIntentID,
+		"status":         r.Status,
+		"metadata":       map[string]string{"reason": r.Reason, "refund_id": r.ReferenceID},
+	}
+}
+
+func accountObject(a *gateway.ConnectedAccount) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                a.ID,
+		"object":            "account",
+		"type":              "express",
+		"charges_enabled":   a.ChargesEnabled,
+		"payouts_enabled":   a.PayoutsEnabled,
+		"details_submitted": a.DetailsSubmitted,
+		"metadata":          map[string]string{"host_id": a.HostID},
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,490 @@
+// internal/fakegateway/fake.go
+package fakegateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"Payment-service/internal/gateway"
+)
+
+// Test card numbers, mirroring Stripe's test cards.
+const (
+	CardVisa              = "4242424242424242"
+	CardMastercard        = "5555555555554444"
+	CardDeclined          = "4000000000000002"
+	CardInsufficientFunds = "4000000000009995"
+	CardRequiresAction    = "4000002500003155"
+)
+
+// Magic amounts that make an off-session confirmation fail regardless of the card.
+const (
+	AmountDeclined          int64 = 402
+	AmountInsufficientFunds int64 = 9995
+	AmountRequiresAction    int64 = 3155
+)
+
+// Decline codes reported in payment_intent.payment_failed events.
+const (
+	DeclineGeneric           = "generic_decline"
+	DeclineInsufficientFunds = "insufficient_funds"
+)
+
+// AuthorizationTTL is how long a simulated card authorization can be
+// captured, like Stripe's default for online card payments.
+const AuthorizationTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrNotFound is returned for unknown object IDs.
+	ErrNotFound = errors.New("fakegateway: no such object")
+	// ErrInvalidState is returned when an operation is not allowed in the current status.
+	ErrInvalidState = errors.New("fakegateway: invalid state for operation")
+)
+
+// DeclineError is returned when a simulated card is declined.
+type DeclineError struct {
+	Code string
+}
+
+func (e *DeclineError) Error() string {
+	return "fakegateway: card declined: " + e.Code
+}
+
+type customer struct {
+	id     string
+	email  string
+	userID string
+}
+
+type setupIntent struct {
+	gateway.SetupIntent
+	usage           string
+	paymentMethodID string
+}
+
+type paymentIntent struct {
+	gateway.PaymentIntent
+	manualCapture   bool
+	paymentMethodID string
+	amountRefunded  int64
+	declineCode     string
+}
+
+type paymentMethod struct {
+	gateway.Card
+	number string
+}
+
+// Gateway is an in-memory PaymentGateway for local development and tests.
+// It keeps every object in memory and reports state changes by posting
+// Stripe-compatible signed events to the configured webhook URL.
+type Gateway struct {
+	mu             sync.Mutex
+	seq            int64
+	customers      map[string]*customer
+	setupIntents   map[string]*setupIntent
+	paymentIntents map[string]*paymentIntent
+	paymentMethods map[string]*paymentMethod
+	refunds        map[string]*gateway.Refund
+	accounts       map[string]*gateway.ConnectedAccount
+	transfers      map[string]*gateway.Transfer
+	reversals      map[string]*gateway.TransferReversal
+
+	emitter *emitter
+}
+
+var _ gateway.PaymentGateway = (*Gateway)(nil)
+
+// New creates a fake gateway. When webhookURL is empty no events are sent.
+func New(webhookURL, webhookSecret string) *Gateway {
+	return &Gateway{
+		customers:      make(map[string]*customer),
+		setupIntents:   make(map[string]*setupIntent),
+		paymentIntents: make(map[string]*paymentIntent),
+		paymentMethods: make(map[string]*paymentMethod),
+		refunds:        make(map[string]*gateway.Refund),
+		accounts:       make(map[string]*gateway.ConnectedAccount),
+		transfers:      make(map[string]*gateway.Transfer),
+		reversals:      make(map[string]*gateway.TransferReversal),
+		emitter:        newEmitter(webhookURL, webhookSecret),
+	}
+}
+
+// newID must be called with g.mu held.
+func (g *Gateway) newID(prefix string) string {
+	g.seq++
+	return fmt.Sprintf("%s_fake%d%06d", prefix, time.Now().Unix(), g.seq)
+}
+
+// CreateCustomer stores a customer and returns its ID.
+func (g *Gateway) CreateCustomer(ctx context.Context, email, userID string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c := &customer{id: g.newID("cus"), email: email, userID: userID}
+	g.customers[c.id] = c
+	return c.id, nil
+}
+
+// CreateSetupIntent creates a SetupIntent waiting for a card.
+func (g *Gateway) CreateSetupIntent(ctx context.Context, customerID, usage string) (*gateway.SetupIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.customers[customerID]; !ok {
+		return nil, fmt.Errorf("%w: customer %s", ErrNotFound, customerID)
+	}
+	id := g.newID("seti")
+	si := &setupIntent{
+		SetupIntent: gateway.SetupIntent{
+			ID:           id,
+			ClientSecret: id + "_secret_fake",
+			Status:       gateway.StatusRequiresPaymentMethod,
+			CustomerID:   customerID,
+		},
+		usage: usage,
+	}
+	g.setupIntents[id] = si
+	out := si.SetupIntent
+	return &out, nil
+}
+
+// ConfirmSetupIntent plays the role of Stripe.js: it attaches the card to the
+// customer and emits setup_intent.succeeded or setup_intent.setup_failed.
+func (g *Gateway) ConfirmSetupIntent(ctx context.Context, setupIntentID, cardNumber string) (*gateway.SetupIntent, error) {
+	g.mu.Lock()
+	si, ok := g.setupIntents[setupIntentID]
+	if !ok {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: setup intent %s", ErrNotFound, setupIntentID)
+	}
+	if si.Status != gateway.StatusRequiresPaymentMethod {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: setup intent is %s", ErrInvalidState, si.Status)
+	}
+
+	var declineErr error
+	if code := cardDeclineCode(cardNumber); code != "" {
+		declineErr = &DeclineError{Code: code}
+	} else {
+		pm := &paymentMethod{Card: newCard(g.newID("pm"), cardNumber), number: cardNumber}
+		g.paymentMethods[pm.PaymentMethodID] = pm
+		si.paymentMethodID = pm.PaymentMethodID
+		si.Status = gateway.StatusSucceeded
+	}
+	userID := ""
+	if c := g.customers[si.CustomerID]; c != nil {
+		userID = c.userID
+	}
+	obj := setupIntentObject(si, userID)
+	out := si.SetupIntent
+	g.mu.Unlock()
+
+	if declineErr != nil {
+		g.emitter.emit("setup_intent.setup_failed", obj)
+		return nil, declineErr
+	}
+	g.emitter.emit("setup_intent.succeeded", obj)
+	return &out, nil
+}
+
+// CreatePaymentIntent creates a PaymentIntent. With a saved card it is
+// confirmed immediately, the same way Stripe confirms off-session intents.
+func (g *Gateway) CreatePaymentIntent(ctx context.Context, p gateway.CreatePaymentIntentParams) (*gateway.PaymentIntent, error) {
+	g.mu.Lock()
+	if _, ok := g.customers[p.CustomerID]; !ok {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: customer %s", ErrNotFound, p.CustomerID)
+	}
+	id := g.newID("pi")
+	pi := &paymentIntent{
+		PaymentIntent: gateway.PaymentIntent{
+			ID:           id,
+			ClientSecret: id + "_secret_fake",
+			Status:       gateway.StatusRequiresPaymentMethod,
+			Amount:       p.Amount,
+			Currency:     strings.ToLower(p.Currency),
+			CustomerID:   p.CustomerID,
+			Created:      time.Now(),
+			Metadata: map[string]string{
+				"booking_id": p.BookingID,
+				"user_id":    p.UserID,
+			},
+		},
+		manualCapture: p.ManualCapture,
+	}
+	if p.ListingID != "" {
+		pi.Metadata["listing_id"] = p.ListingID
+	}
+	g.paymentIntents[id] = pi
+
+	if p.PaymentMethodID == "" {
+		out := copyIntent(pi)
+		g.mu.Unlock()
+		g.emitter.emit("payment_intent.created", paymentIntentObject(pi))
+		return out, nil
+	}
+
+	pm, ok := g.paymentMethods[p.PaymentMethodID]
+	if !ok {
+		delete(g.paymentIntents, id)
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: payment method %s", ErrNotFound, p.PaymentMethodID)
+	}
+	eventType, err := g.confirmLocked(pi, pm)
+	out, obj := copyIntent(pi), paymentIntentObject(pi)
+	g.mu.Unlock()
+
+	g.emitter.emit(eventType, obj)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ConfirmPaymentIntent plays the role of Stripe.js for a PaymentIntent created
+// without a saved card.
+func (g *Gateway) ConfirmPaymentIntent(ctx context.Context, paymentIntentID, cardNumber string) (*gateway.PaymentIntent, error) {
+	g.mu.Lock()
+	pi, ok := g.paymentIntents[paymentIntentID]
+	if !ok {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: payment intent %s", ErrNotFound, paymentIntentID)
+	}
+	if pi.Status != gateway.StatusRequiresPaymentMethod && pi.Status != gateway.StatusRequiresAction {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: payment intent is %s", ErrInvalidState, pi.Status)
+	}
+	pm := &paymentMethod{Card: newCard(g.newID("pm"), cardNumber), number: cardNumber}
+	g.paymentMethods[pm.PaymentMethodID] = pm
+	eventType, err := g.confirmLocked(pi, pm)
+	out, obj := copyIntent(pi), paymentIntentObject(pi)
+	g.mu.Unlock()
+
+	g.emitter.emit(eventType, obj)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// confirmLocked applies a confirmation attempt and returns the event to emit.
+func (g *Gateway) confirmLocked(pi *paymentIntent, pm *paymentMethod) (string, error) {
+	pi.paymentMethodID = pm.PaymentMethodID
+	if code := amountDeclineCode(pi.Amount); code != "" {
+		return g.declineLocked(pi, code)
+	}
+	if code := cardDeclineCode(pm.number); code != "" {
+		return g.declineLocked(pi, code)
+	}
+	if pi.Amount == AmountRequiresAction || pm.number == CardRequiresAction {
+		if pi.Status != gateway.StatusRequiresAction {
+			pi.Status = gateway.StatusRequiresAction
+			return "payment_intent.requires_action", nil
+		}
+	}
+	pi.declineCode = ""
+	if pi.manualCapture {
+		pi.Status = gateway.StatusRequiresCapture
+		pi.AmountCapturable = pi.Amount
+		pi.CaptureBefore = time.Now().Add(AuthorizationTTL)
+		return "payment_intent.amount_capturable_updated", nil
+	}
+	pi.Status = gateway.StatusSucceeded
+	pi.AmountReceived = pi.Amount
+	return "payment_intent.succeeded", nil
+}
+
+func (g *Gateway) declineLocked(pi *paymentIntent, code string) (string, error) {
+	pi.Status = gateway.StatusRequiresPaymentMethod
+	pi.declineCode = code
+	return "payment_intent.payment_failed", &DeclineError{Code: code}
+}
+
+// CapturePaymentIntent captures an authorized manual-capture PaymentIntent,
+// releasing the remainder on partial capture like Stripe does.
+func (g *Gateway) CapturePaymentIntent(ctx context.Context, paymentIntentID string, amountToCapture int64) (*gateway.PaymentIntent, error) {
+	g.mu.Lock()
+	pi, ok := g.paymentIntents[paymentIntentID]
+	if !ok {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: payment intent %s", ErrNotFound, paymentIntentID)
+	}
+	if pi.Status != gateway.StatusRequiresCapture {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: payment intent is %s", ErrInvalidState, pi.Status)
+	}
+	if amountToCapture == 0 {
+		amountToCapture = pi.AmountCapturable
+	}
+	if amountToCapture < 0 || amountToCapture > pi.AmountCapturable {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: amount_to_capture %d exceeds capturable %d", ErrInvalidState, amountToCapture, pi.AmountCapturable)
+	}
+	pi.AmountReceived = amountToCapture
+	pi.AmountCapturable = 0
+	pi.Status = gateway.StatusSucceeded
+	out, obj := copyIntent(pi), paymentIntentObject(pi)
+	g.mu.Unlock()
+
+	g.emitter.emit("payment_intent.succeeded", obj)
+	return out, nil
+}
+
+// CancelPaymentIntent cancels a PaymentIntent that has not been captured yet.
+func (g *Gateway) CancelPaymentIntent(ctx context.Context, paymentIntentID string) (*gateway.PaymentIntent, error) {
+	g.mu.Lock()
+	pi, ok := g.paymentIntents[paymentIntentID]
+	if !ok {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: payment intent %s", ErrNotFound, paymentIntentID)
+	}
+	if pi.Status == gateway.StatusSucceeded || pi.Status == gateway.StatusCanceled {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: payment intent is %s", ErrInvalidState, pi.Status)
+	}
+	pi.AmountCapturable = 0
+	pi.Status = gateway.StatusCanceled
+	out, obj := copyIntent(pi), paymentIntentObject(pi)
+	g.mu.Unlock()
+
+	g.emitter.emit("payment_intent.canceled", obj)
+	return out, nil
+}
+
+// GetPaymentIntent returns a PaymentIntent; gateway.ErrNotFound for unknown IDs.
+func (g *Gateway) GetPaymentIntent(ctx context.Context, paymentIntentID string) (*gateway.PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	pi, ok := g.paymentIntents[paymentIntentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: payment intent %s", gateway.ErrNotFound, paymentIntentID)
+	}
+	return copyIntent(pi), nil
+}
+
+// ListPaymentIntents returns PaymentIntents created since the given time.
+func (g *Gateway) ListPaymentIntents(ctx context.Context, since time.Time) ([]gateway.PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var out []gateway.PaymentIntent
+	for _, pi := range g.paymentIntents {
+		if !pi.Created.Before(since) {
+			out = append(out, *copyIntent(pi))
+		}
+	}
+	return out, nil
+}
+
+// RetrieveCard returns a card saved through ConfirmSetupIntent or ConfirmPaymentIntent.
+func (g *Gateway) RetrieveCard(ctx context.Context, paymentMethodID string) (*gateway.Card, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	pm, ok := g.paymentMethods[paymentMethodID]
+	if !ok {
+		return nil, fmt.Errorf("%w: payment method %s", ErrNotFound, paymentMethodID)
+	}
+	out := pm.Card
+	return &out, nil
+}
+
+// CreateRefund refunds a succeeded PaymentIntent and emits charge.refunded
+// and refund.created. Like the current Stripe API, the charge does not embed
+// its refunds.
+func (g *Gateway) CreateRefund(ctx context.Context, p gateway.RefundParams) (*gateway.Refund, error) {
+	g.mu.Lock()
+	pi, ok := g.paymentIntents[p.PaymentIntentID]
+	if !ok {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: payment intent %s", ErrNotFound, p.PaymentIntentID)
+	}
+	if pi.Status != gateway.StatusSucceeded {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: payment intent is %s", ErrInvalidState, pi.Status)
+	}
+	refundable := pi.AmountReceived - pi.amountRefunded
+	amount := p.Amount
+	if amount == 0 {
+		amount = refundable
+	}
+	if amount <= 0 || amount > refundable {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: refund amount %d exceeds refundable %d", ErrInvalidState, amount, refundable)
+	}
+	pi.amountRefunded += amount
+	r := &gateway.Refund{
+		ID:              g.newID("re"),
+		PaymentIntentID: pi.ID,
+		Amount:          amount,
+		Currency:        pi.Currency,
+		Status:          gateway.RefundStatusSucceeded,
+		Reason:          p.Reason,
+		ReferenceID:     p.ReferenceID,
+	}
+	g.refunds[r.ID] = r
+	out := *r
+	chargeObj, refundObj := chargeObject(pi), refundObject(r)
+	g.mu.Unlock()
+
+	g.emitter.emit("charge.refunded", chargeObj)
+	g.emitter.emit("refund.created", refundObj)
+	return &out, nil
+}
+
+func copyIntent(pi *paymentIntent) *gateway.PaymentIntent {
+	out := pi.PaymentIntent
+	out.Metadata = make(map[string]string, len(pi.Metadata))
+	for k, v := range pi.Metadata {
+		out.Metadata[k] = v
+	}
+	return &out
+}
+
+func newCard(pmID, number string) gateway.Card {
+	last4 := number
+	if len(number) > 4 {
+		last4 = number[len(number)-4:]
+	}
+	return gateway.Card{
+		PaymentMethodID: pmID,
+		Brand:           cardBrand(number),
+		Last4:           last4,
+		ExpMonth:        12,
+		ExpYear:         time.Now().Year() + 3,
+	}
+}
+
+func cardBrand(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case strings.HasPrefix(number, "5"):
+		return "mastercard"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "amex"
+	default:
+		return "unknown"
+	}
+}
+
+func cardDeclineCode(number string) string {
+	switch number {
+	case CardDeclined:
+		return DeclineGeneric
+	case CardInsufficientFunds:
+		return DeclineInsufficientFunds
+	}
+	return ""
+}
+
+func amountDeclineCode(amount int64) string {
+	switch amount {
+	case AmountDeclined:
+		return DeclineGeneric
+	case AmountInsufficientFunds:
+		return DeclineInsufficientFunds
+	}
+	return ""
+}
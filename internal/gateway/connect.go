@@ -0,0 +1,100 @@
+// internal/gateway/connect.go
+package gateway
+
+import (
+	"context"
+	"time"
+)
+
+// ConnectedAccount is a provider-agnostic view of a host's payout account.
+type ConnectedAccount struct {
+	ID               string
+	HostID           string
+	ChargesEnabled   bool
+	PayoutsEnabled   bool
+	DetailsSubmitted bool
+}
+
+// AccountLink is a one-time URL that takes a host through onboarding.
+type AccountLink struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
+// Money is an amount in the smallest currency unit.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// AccountBalance is the balance of a connected account, per currency.
+type AccountBalance struct {
+	Available []Money
+	Pending   []Money
+}
+
+// Payout is a payout from a connected account to the host's bank.
+type Payout struct {
+	ID          string
+	Amount      int64
+	Currency    string
+	Status      string
+	ArrivalDate time.Time
+	CreatedAt   time.Time
+}
+
+// TransferParams holds the input for CreateTransfer.
+type TransferParams struct {
+	AccountID string
+	Amount    int64
+	Currency  string
+	// PaymentIntentID links the transfer to the charge it pays out, so the
+	// transfer does not depend on the platform's available balance.
+	PaymentIntentID string
+	// IdempotencyKey makes retries of the same transfer safe.
+	IdempotencyKey string
+}
+
+// Transfer is a provider-agnostic view of a transfer to a connected account.
+type Transfer struct {
+	ID        string
+	AccountID string
+	Amount    int64
+	Currency  string
+}
+
+// TransferReversalParams holds the input for CreateTransferReversal.
+type TransferReversalParams struct {
+	TransferID string
+	Amount     int64
+	// IdempotencyKey makes retries of the same reversal safe.
+	IdempotencyKey string
+}
+
+// TransferReversal is a provider-agnostic view of money returned from a
+// connected account to the platform.
+type TransferReversal struct {
+	ID         string
+	TransferID string
+	Amount     int64
+	Currency   string
+}
+
+// ConnectGateway covers the marketplace operations: host onboarding and
+// moving captured money to hosts. Implementations must be safe for concurrent use.
+type ConnectGateway interface {
+	// CreateConnectedAccount creates a payout account for the host.
+	CreateConnectedAccount(ctx context.Context, hostID, email string) (*ConnectedAccount, error)
+	// RetrieveConnectedAccount returns the current onboarding state of an account.
+	RetrieveConnectedAccount(ctx context.Context, accountID string) (*ConnectedAccount, error)
+	// CreateAccountLink returns an onboarding link for the account.
+	CreateAccountLink(ctx context.Context, accountID, refreshURL, returnURL string) (*AccountLink, error)
+	// CreateTransfer moves money from the platform to a connected account.
+	CreateTransfer(ctx context.Context, params TransferParams) (*Transfer, error)
+	// CreateTransferReversal returns part of a transfer from the connected account.
+	CreateTransferReversal(ctx context.Context, params TransferReversalParams) (*TransferReversal, error)
+	// GetAccountBalance returns the balance held on a connected account.
+	GetAccountBalance(ctx context.Context, accountID string) (*AccountBalance, error)
+	// ListPayouts returns the latest payouts of a connected account, newest first.
+	ListPayouts(ctx context.Context, accountID string, limit int) ([]Payout, error)
+}
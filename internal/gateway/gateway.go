@@ -0,0 +1,140 @@
+// internal/gateway/gateway.go
+package gateway
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// PaymentIntent statuses. Values match Stripe's so they can be stored as-is.
+const (
+	StatusRequiresPaymentMethod = "requires_payment_method"
+	StatusRequiresConfirmation  = "requires_confirmation"
+	StatusRequiresAction        = "requires_action"
+	StatusProcessing            = "processing"
+	StatusRequiresCapture       = "requires_capture"
+	StatusCanceled              = "canceled"
+	StatusSucceeded             = "succeeded"
+)
+
+// CancellationAutomatic is the cancellation reason of an intent the provider
+// canceled itself, e.g. when an uncaptured authorization expired.
+const CancellationAutomatic = "automatic"
+
+// Refund statuses.
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+	RefundStatusCanceled  = "canceled"
+)
+
+// SetupIntent usages.
+const (
+	UsageOffSession = "off_session"
+	UsageOnSession  = "on_session"
+)
+
+// ErrNotFound is returned when the provider has no object with the given ID.
+var ErrNotFound = errors.New("gateway: no such object")
+
+// PaymentIntent is a provider-agnostic view of a payment authorization.
+type PaymentIntent struct {
+	ID               string
+	ClientSecret     string
+	Status           string
+	Amount           int64
+	AmountCapturable int64
+	AmountReceived   int64
+	Currency         string
+	CustomerID       string
+	Metadata         map[string]string
+	// CancellationReason is set for canceled intents.
+	CancellationReason string
+	// Created is when the intent was created.
+	Created time.Time
+	// CaptureBefore is when an uncaptured card authorization lapses. Zero when
+	// the intent has no authorized charge or the charge was not loaded.
+	CaptureBefore time.Time
+}
+
+// SetupIntent is a provider-agnostic view of a card-saving flow.
+type SetupIntent struct {
+	ID           string
+	ClientSecret string
+	Status       string
+	CustomerID   string
+}
+
+// Card describes a saved card payment method.
+type Card struct {
+	PaymentMethodID string
+	Brand           string
+	Last4           string
+	ExpMonth        int
+	ExpYear         int
+}
+
+// Refund is a provider-agnostic view of a refund.
+type Refund struct {
+	ID              string
+	PaymentIntentID string
+	Amount          int64
+	Currency        string
+	Status          string
+	Reason          string
+	// ReferenceID is the caller's own refund ID echoed back from metadata.
+	ReferenceID string
+}
+
+// CreatePaymentIntentParams holds the input for CreatePaymentIntent.
+// Amount is in the smallest currency unit (cents or kopecks).
+type CreatePaymentIntentParams struct {
+	CustomerID string
+	Amount     int64
+	Currency   string
+	BookingID  string
+	UserID     string
+	ListingID  string
+	// ManualCapture places a hold that must be captured explicitly.
+	ManualCapture bool
+	// PaymentMethodID, when set, confirms the intent off-session with a saved card.
+	PaymentMethodID string
+}
+
+// RefundParams holds the input for CreateRefund.
+// A zero Amount refunds everything that is still refundable.
+type RefundParams struct {
+	PaymentIntentID string
+	Amount          int64
+	Reason          string
+	// ReferenceID is stored in metadata so webhooks can be matched to local rows.
+	ReferenceID string
+}
+
+// PaymentGateway covers the provider operations the Payment-service needs.
+// Implementations must be safe for concurrent use.
+type PaymentGateway interface {
+	// CreateCustomer creates a customer and returns its provider ID.
+	CreateCustomer(ctx context.Context, email, userID string) (string, error)
+	// CreateSetupIntent starts saving a card for the customer.
+	CreateSetupIntent(ctx context.Context, customerID, usage string) (*SetupIntent, error)
+	// CreatePaymentIntent creates a PaymentIntent for the customer.
+	CreatePaymentIntent(ctx context.Context, params CreatePaymentIntentParams) (*PaymentIntent, error)
+	// CapturePaymentIntent captures a previously authorized PaymentIntent.
+	// A zero amountToCapture captures the full authorized amount; with a smaller
+	// amount the remainder of the hold is released.
+	CapturePaymentIntent(ctx context.Context, paymentIntentID string, amountToCapture int64) (*PaymentIntent, error)
+	// CancelPaymentIntent releases an uncaptured PaymentIntent.
+	CancelPaymentIntent(ctx context.Context, paymentIntentID string) (*PaymentIntent, error)
+	// GetPaymentIntent returns the current state of a PaymentIntent;
+	// ErrNotFound if the provider has no such intent.
+	GetPaymentIntent(ctx context.Context, paymentIntentID string) (*PaymentIntent, error)
+	// ListPaymentIntents returns every PaymentIntent created at or after since.
+	ListPaymentIntents(ctx context.Context, since time.Time) ([]PaymentIntent, error)
+	// RetrieveCard returns card details of a saved payment method.
+	RetrieveCard(ctx context.Context, paymentMethodID string) (*Card, error)
+	// CreateRefund refunds a captured PaymentIntent fully or partially.
+	CreateRefund(ctx context.Context, params RefundParams) (*Refund, error)
+}
@@ -0,0 +1,18 @@
+// internal/gateway/idempotency.go
+package gateway
+
+import "context"
+
+type idempotencyKeyCtx struct{}
+
+// WithIdempotencyKey attaches a client idempotency key to ctx. Gateways that
+// support it forward the key (suffixed per operation) to the provider.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+// IdempotencyKey returns the key attached by WithIdempotencyKey, if any.
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyCtx{}).(string)
+	return key
+}
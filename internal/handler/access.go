@@ -0,0 +1,101 @@
+// internal/handler/access.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"Payment-service/internal/listingclient"
+	"Payment-service/internal/middleware"
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessChecker решает, кто может работать с платежом или депозитом:
+// владелец, хост объявления или администратор.
+type AccessChecker struct {
+	payments service.PaymentService
+	deposits service.DepositService
+	listings *listingclient.Client // nil — хосты объявлений не проверяются
+}
+
+// NewAccessChecker конструктор
+func NewAccessChecker(
+	payments service.PaymentService,
+	deposits service.DepositService,
+	listings *listingclient.Client,
+) *AccessChecker {
+	return &AccessChecker{payments: payments, deposits: deposits, listings: listings}
+}
+
+// payment загружает платёж и проверяет доступ к нему.
+// При ошибке сам отвечает клиенту и возвращает false.
+func (a *AccessChecker) payment(c *gin.Context, paymentIntentID string) (repository.PaymentIntent, bool) {
+	pi, err := a.payments.Get(c.Request.Context(), paymentIntentID)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return repository.PaymentIntent{}, false
+	}
+	if !a.authorize(c, "payment", pi.UserID, pi.ListingID) {
+		return repository.PaymentIntent{}, false
+	}
+	return pi, true
+}
+
+// deposit загружает депозит и проверяет доступ к нему.
+// При ошибке сам отвечает клиенту и возвращает false.
+func (a *AccessChecker) deposit(c *gin.Context, depositID string) (repository.Deposit, bool) {
+	d, err := a.deposits.GetDeposit(c.Request.Context(), depositID)
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deposit not found"})
+		return repository.Deposit{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return repository.Deposit{}, false
+	}
+	if !a.authorize(c, "deposit", d.UserID, d.ListingID) {
+		return repository.Deposit{}, false
+	}
+	return d, true
+}
+
+// currentUser возвращает пользователя запроса. Сервис, вызывающий
+// /internal/v1/pay от своего имени, получает 403.
+func currentUser(c *gin.Context) (*middleware.Principal, bool) {
+	p, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "operation requires a user, set " + middleware.OnBehalfOfHeader})
+	}
+	return p, ok
+}
+
+// authorize пропускает администратора, владельца (ownerID), хоста объявления
+// listingID и сервис, действующий от своего имени (его права ограничены scopes).
+func (a *AccessChecker) authorize(c *gin.Context, resource, ownerID, listingID string) bool {
+	if middleware.HasRole(c, middleware.RoleAdmin) || middleware.ActingService(c) {
+		return true
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return false
+	}
+	if user.UserID == ownerID {
+		return true
+	}
+	if listingID != "" && a.listings != nil {
+		listing, err := a.listings.GetByID(c.Request.Context(), listingID)
+		switch {
+		case errors.Is(err, listingclient.ErrNotFound):
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot fetch listing: " + err.Error()})
+			return false
+		case listing.HostID == user.UserID:
+			return true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": resource + " belongs to another user"})
+	return false
+}
@@ -0,0 +1,81 @@
+// internal/handler/admin_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler — служебные операции для администраторов
+type AdminHandler struct {
+	eventService service.StripeEventService
+}
+
+// NewAdminHandler конструктор
+func NewAdminHandler(eventSvc service.StripeEventService) *AdminHandler {
+	return &AdminHandler{eventService: eventSvc}
+}
+
+// StripeEventResponse — представление события Stripe из журнала
+type StripeEventResponse struct {
+	ID            string     `json:"id"`
+	Type          string     `json:"type"`
+	ReceivedAt    time.Time  `json:"received_at"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
+	Error         *string    `json:"error,omitempty"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+func toStripeEventResponse(e repository.StripeEvent) StripeEventResponse {
+	return StripeEventResponse{
+		ID:            e.ID,
+		Type:          e.Type,
+		ReceivedAt:    e.ReceivedAt,
+		ProcessedAt:   e.ProcessedAt,
+		Error:         e.Error,
+		Attempts:      e.Attempts,
+		NextAttemptAt: e.NextAttemptAt,
+	}
+}
+
+// ListStripeEvents — GET /api/v1/pay/admin/stripe-events?failed=true&limit=50
+func (h *AdminHandler) ListStripeEvents(c *gin.Context) {
+	failedOnly := c.Query("failed") == "true"
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	list, err := h.eventService.ListEvents(c.Request.Context(), failedOnly, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]StripeEventResponse, 0, len(list))
+	for _, e := range list {
+		out = append(out, toStripeEventResponse(e))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// ReplayStripeEvent — POST /api/v1/pay/admin/stripe-events/:id/replay
+func (h *AdminHandler) ReplayStripeEvent(c *gin.Context) {
+	err := h.eventService.Replay(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusOK)
+}
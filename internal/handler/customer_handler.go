@@ -1,36 +1,30 @@
 package handler
 
 import (
-	"context"
 	"net/http"
 
 	"Payment-service/internal/service"
-	"Payment-service/internal/userclient"
 	"github.com/gin-gonic/gin"
 )
 
 // CustomerHandler держит зависимости
 type CustomerHandler struct {
-	svc        service.CustomerService
-	userClient *userclient.Client
+	svc service.CustomerService
 }
 
 // NewCustomerHandler конструктор
-func NewCustomerHandler(svc service.CustomerService, uc *userclient.Client) *CustomerHandler {
-	return &CustomerHandler{svc: svc, userClient: uc}
+func NewCustomerHandler(svc service.CustomerService) *CustomerHandler {
+	return &CustomerHandler{svc: svc}
 }
 
 // CreateCustomer — POST /api/v1/pay/customers (без тела запроса)
 func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
-	email := c.GetString("userEmail")
-
-	user, err := h.userClient.GetByEmail(c.Request.Context(), email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot fetch user: " + err.Error()})
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
 
-	stripeID, err := h.svc.EnsureCustomer(context.Background(), user.ID, user.Email)
+	stripeID, err := h.svc.EnsureCustomer(c.Request.Context(), user.UserID, user.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot ensure Stripe customer: " + err.Error()})
 		return
@@ -3,35 +3,39 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
+	"Payment-service/internal/repository"
 	"Payment-service/internal/service"
-	"Payment-service/internal/userclient"
 
 	"github.com/gin-gonic/gin"
 )
 
 // DepositHandler держит зависимости для операций с депозитами.
 type DepositHandler struct {
-	svc        service.DepositService
-	custSvc    service.CustomerService
-	userClient *userclient.Client
+	svc       service.DepositService
+	custSvc   service.CustomerService
+	refundSvc service.RefundService
+	access    *AccessChecker
 }
 
 // NewDepositHandler конструктор
 func NewDepositHandler(
 	svc service.DepositService,
 	custSvc service.CustomerService,
-	userClient *userclient.Client,
+	refundSvc service.RefundService,
+	access *AccessChecker,
 ) *DepositHandler {
-	return &DepositHandler{svc: svc, custSvc: custSvc, userClient: userClient}
+	return &DepositHandler{svc: svc, custSvc: custSvc, refundSvc: refundSvc, access: access}
 }
 
 // CreateDepositRequest — payload для POST /deposits
-// booking_id, listing_id, amount и currency приходят из клиента.
-// userID и customerID получаем из контекста и CustomerService соответственно.
+// booking_id, amount и currency приходят из клиента. listing_id необязателен:
+// объявление берётся из брони, а переданное значение должно с ней совпадать.
+// userID берём из Principal, customerID — из CustomerService.
 type CreateDepositRequest struct {
 	BookingID string `json:"booking_id" binding:"required"`
-	ListingID string `json:"listing_id" binding:"required"`
+	ListingID string `json:"listing_id,omitempty"`
 	Amount    int64  `json:"amount" binding:"required,gt=0"`
 	Currency  string `json:"currency" binding:"required"`
 }
@@ -44,79 +48,230 @@ type CreateDepositResponse struct {
 
 // CreateDeposit обрабатывает POST /api/v1/pay/deposits
 func (h *DepositHandler) CreateDeposit(c *gin.Context) {
-	// 1) Получаем email из JWT, middleware написал ранее
-	email := c.GetString("userEmail")
-
-	// 2) Достаём userID из User-service
-	user, err := h.userClient.GetByEmail(c.Request.Context(), email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot fetch user: " + err.Error()})
+	// 1) Пользователь из токена, middleware положил его в контекст
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
 
-	// 3) Проверяем или создаём Stripe Customer
-	stripeCustID, err := h.custSvc.EnsureCustomer(c.Request.Context(), user.ID, user.Email)
+	// 2) Проверяем или создаём Stripe Customer
+	stripeCustID, err := h.custSvc.EnsureCustomer(c.Request.Context(), user.UserID, user.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot ensure customer: " + err.Error()})
 		return
 	}
 
-	// 4) Парсим тело запроса
+	// 3) Парсим тело запроса
 	var req CreateDepositRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 5) Авторизуем депозит
+	// 4) Авторизуем депозит
 	clientSecret, depositID, err := h.svc.AuthorizeDeposit(
 		c.Request.Context(),
 		stripeCustID,
-		user.ID,
+		user.UserID,
 		req.BookingID,
 		req.ListingID,
 		req.Currency,
 		req.Amount,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// 6) Возвращаем клиенту данные для подтверждения
+	// 5) Возвращаем клиенту данные для подтверждения
 	resp := CreateDepositResponse{ClientSecret: clientSecret, DepositID: depositID}
 	c.JSON(http.StatusOK, resp)
 }
 
-// CaptureRefundRequest — payload для capture/refund операций
-type CaptureRefundRequest struct {
+// CaptureDepositRequest — payload для capture операции.
+// amount_to_capture не указан — списывается весь hold; иначе списывается часть
+// (например, за ущерб), а остаток автоматически отпускается.
+type CaptureDepositRequest struct {
+	DepositID       string `json:"deposit_id" binding:"required"`
+	AmountToCapture int64  `json:"amount_to_capture" binding:"gte=0"`
+	Reason          string `json:"reason"`
+}
+
+// RefundDepositRequest — payload для refund операции.
+// amount = 0 (или не указан) — вернуть всё; для незахваченного депозита hold просто отпускается.
+type RefundDepositRequest struct {
 	DepositID string `json:"deposit_id" binding:"required"`
+	Amount    int64  `json:"amount" binding:"gte=0"`
+	Reason    string `json:"reason"`
 }
 
 // CaptureDeposit обрабатывает POST /api/v1/pay/deposits/capture
+// Доступно владельцу депозита, хосту объявления и администратору.
 func (h *DepositHandler) CaptureDeposit(c *gin.Context) {
-	var req CaptureRefundRequest
+	var req CaptureDepositRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.svc.CaptureDeposit(c.Request.Context(), req.DepositID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if _, ok := h.access.deposit(c, req.DepositID); !ok {
+		return
+	}
+	d, err := h.svc.CaptureDeposit(c.Request.Context(), req.DepositID, req.AmountToCapture, req.Reason)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.Status(http.StatusOK)
+	c.JSON(http.StatusOK, toDepositResponse(d))
 }
 
 // RefundDeposit обрабатывает POST /api/v1/pay/deposits/refund
+// Доступно владельцу депозита, хосту объявления и администратору.
 func (h *DepositHandler) RefundDeposit(c *gin.Context) {
-	var req CaptureRefundRequest
+	var req RefundDepositRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.svc.RefundDeposit(c.Request.Context(), req.DepositID); err != nil {
+	if _, ok := h.access.deposit(c, req.DepositID); !ok {
+		return
+	}
+	r, err := h.svc.RefundDeposit(c.Request.Context(), req.DepositID, req.Amount, req.Reason)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if r == nil {
+		// hold отпущен, возврата как такового нет
+		c.Status(http.StatusOK)
+		return
+	}
+	c.JSON(http.StatusOK, toRefundResponse(*r))
+}
+
+// DepositResponse — представление депозита в API
+type DepositResponse struct {
+	DepositID string    `json:"deposit_id"`
+	BookingID string    `json:"booking_id"`
+	ListingID string    `json:"listing_id"`
+	UserID    string    `json:"user_id"`
+	Amount    int64     `json:"amount"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	CapturedAmount int64  `json:"captured_amount"`
+	CaptureReason  string `json:"capture_reason,omitempty"`
+	// HoldExpiresAt — когда истечёт авторизация; есть с момента авторизации
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+}
+
+func toDepositResponse(d repository.Deposit) DepositResponse {
+	return DepositResponse{
+		DepositID: d.StripePIID,
+		BookingID: d.BookingID,
+		ListingID: d.ListingID,
+		UserID:    d.UserID,
+		Amount:    d.Amount,
+		Currency:  d.Currency,
+		Status:    d.Status,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+
+		CapturedAmount: d.CapturedAmount,
+		CaptureReason:  d.CaptureReason,
+		HoldExpiresAt:  d.HoldExpiresAt,
+	}
+}
+
+func toDepositResponses(list []repository.Deposit) []DepositResponse {
+	out := make([]DepositResponse, 0, len(list))
+	for _, d := range list {
+		out = append(out, toDepositResponse(d))
+	}
+	return out
+}
+
+// GetDeposit обрабатывает GET /api/v1/pay/deposits/:id
+// Депозит доступен владельцу, хосту объявления и администратору.
+func (h *DepositHandler) GetDeposit(c *gin.Context) {
+	d, ok := h.access.deposit(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toDepositResponse(d))
+}
+
+// ListDepositsByBooking обрабатывает GET /api/v1/pay/deposits?booking_id=
+// Возвращает только депозиты текущего пользователя по этой брони.
+func (h *DepositHandler) ListDepositsByBooking(c *gin.Context) {
+	bookingID := c.Query("booking_id")
+	if bookingID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "booking_id query parameter is required"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.svc.ListByBooking(c.Request.Context(), bookingID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.Status(http.StatusOK)
+	own := make([]repository.Deposit, 0, len(list))
+	for _, d := range list {
+		if d.UserID == user.UserID {
+			own = append(own, d)
+		}
+	}
+	c.JSON(http.StatusOK, toDepositResponses(own))
+}
+
+// ListMyDeposits обрабатывает GET /api/v1/pay/me/deposits
+func (h *DepositHandler) ListMyDeposits(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.svc.ListByUser(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toDepositResponses(list))
+}
+
+// ListDepositRefunds обрабатывает GET /api/v1/pay/deposits/:id/refunds
+func (h *DepositHandler) ListDepositRefunds(c *gin.Context) {
+	d, ok := h.access.deposit(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	list, err := h.refundSvc.ListRefunds(c.Request.Context(), d.StripePIID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toRefundResponses(list))
+}
+
+// GetDepositHistory обрабатывает GET /api/v1/pay/deposits/:id/history
+// История статусов доступна тем же, кому и сам депозит.
+func (h *DepositHandler) GetDepositHistory(c *gin.Context) {
+	d, ok := h.access.deposit(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	list, err := h.svc.History(c.Request.Context(), d.StripePIID)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toStatusChangeResponses(list))
 }
@@ -0,0 +1,80 @@
+// internal/handler/deposit_hold_policy_handler.go
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DepositHoldPolicyHandler — что делать с депозитом объявления или брони
+// перед истечением авторизации
+type DepositHoldPolicyHandler struct {
+	svc service.HoldExpiryService
+}
+
+// NewDepositHoldPolicyHandler конструктор
+func NewDepositHoldPolicyHandler(svc service.HoldExpiryService) *DepositHoldPolicyHandler {
+	return &DepositHoldPolicyHandler{svc: svc}
+}
+
+// SetDepositHoldPolicyRequest — тело PUT-запроса
+type SetDepositHoldPolicyRequest struct {
+	Action string `json:"action" binding:"required"` // none | capture | release
+}
+
+// DepositHoldPolicyResponse — политика объявления или брони
+type DepositHoldPolicyResponse struct {
+	Scope     string    `json:"scope"`
+	ScopeID   string    `json:"scope_id"`
+	Action    string    `json:"action"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func toDepositHoldPolicyResponse(p repository.DepositHoldPolicy) DepositHoldPolicyResponse {
+	return DepositHoldPolicyResponse{
+		Scope:     p.Scope,
+		ScopeID:   p.ScopeID,
+		Action:    p.Action,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+// GetPolicy — GET /deposit-hold-policies/:scope/:id, scope — listing или booking
+func (h *DepositHoldPolicyHandler) GetPolicy(c *gin.Context) {
+	p, err := h.svc.Policy(c.Request.Context(), c.Param("scope"), c.Param("id"))
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toDepositHoldPolicyResponse(p))
+}
+
+// SetPolicy — PUT /deposit-hold-policies/:scope/:id
+func (h *DepositHoldPolicyHandler) SetPolicy(c *gin.Context) {
+	var req SetDepositHoldPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := h.svc.SetPolicy(c.Request.Context(), c.Param("scope"), c.Param("id"), req.Action)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toDepositHoldPolicyResponse(p))
+}
+
+// DeletePolicy — DELETE /deposit-hold-policies/:scope/:id; дальше действует
+// политика объявления или политика по умолчанию
+func (h *DepositHoldPolicyHandler) DeletePolicy(c *gin.Context) {
+	if err := h.svc.DeletePolicy(c.Request.Context(), c.Param("scope"), c.Param("id")); err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+// internal/handler/fake_gateway_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"Payment-service/internal/fakegateway"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FakeGatewayHandler заменяет Stripe.js при PAYMENT_GATEWAY=fake:
+// подтверждает SetupIntent/PaymentIntent тестовой картой и проходит
+// онбординг хоста вместо формы Stripe.
+type FakeGatewayHandler struct {
+	gw *fakegateway.Gateway
+}
+
+// NewFakeGatewayHandler конструктор
+func NewFakeGatewayHandler(gw *fakegateway.Gateway) *FakeGatewayHandler {
+	return &FakeGatewayHandler{gw: gw}
+}
+
+// ConfirmRequest — payload для confirm-эндпоинтов
+type ConfirmRequest struct {
+	CardNumber string `json:"card_number"`
+}
+
+// ConfirmSetupIntent — POST /fake-gateway/setup-intents/:id/confirm
+// :id — ID или client_secret SetupIntent
+func (h *FakeGatewayHandler) ConfirmSetupIntent(c *gin.Context) {
+	req, ok := bindConfirmRequest(c)
+	if !ok {
+		return
+	}
+	si, err := h.gw.ConfirmSetupIntent(c.Request.Context(), objectID(c.Param("id")), req.CardNumber)
+	if err != nil {
+		c.JSON(fakeGatewayStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, si)
+}
+
+// ConfirmPaymentIntent — POST /fake-gateway/payment-intents/:id/confirm
+func (h *FakeGatewayHandler) ConfirmPaymentIntent(c *gin.Context) {
+	req, ok := bindConfirmRequest(c)
+	if !ok {
+		return
+	}
+	pi, err := h.gw.ConfirmPaymentIntent(c.Request.Context(), objectID(c.Param("id")), req.CardNumber)
+	if err != nil {
+		c.JSON(fakeGatewayStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, pi)
+}
+
+// CompleteOnboarding — POST /fake-gateway/accounts/:id/onboard
+// Сюда ведёт ссылка онбординга: аккаунт хоста сразу может принимать переводы.
+func (h *FakeGatewayHandler) CompleteOnboarding(c *gin.Context) {
+	a, err := h.gw.CompleteOnboarding(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(fakeGatewayStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// bindConfirmRequest читает необязательное тело; без карты используется CardVisa.
+func bindConfirmRequest(c *gin.Context) (ConfirmRequest, bool) {
+	var req ConfirmRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return req, false
+		}
+	}
+	if req.CardNumber == "" {
+		req.CardNumber = fakegateway.CardVisa
+	}
+	return req, true
+}
+
+// objectID принимает как ID, так и client_secret ("<id>_secret_...").
+func objectID(idOrSecret string) string {
+	if i := strings.Index(idOrSecret, "_secret"); i > 0 {
+		return idOrSecret[:i]
+	}
+	return idOrSecret
+}
+
+func fakeGatewayStatus(err error) int {
+	var decline *fakegateway.DeclineError
+	switch {
+	case errors.Is(err, fakegateway.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, fakegateway.ErrInvalidState):
+		return http.StatusConflict
+	case errors.As(err, &decline):
+		return http.StatusPaymentRequired
+	default:
+		return http.StatusInternalServerError
+	}
+}
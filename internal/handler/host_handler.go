@@ -0,0 +1,237 @@
+// internal/handler/host_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HostHandler — выплаты хостам через Stripe Connect. Хост — текущий пользователь.
+type HostHandler struct {
+	svc service.ConnectService
+}
+
+// NewHostHandler конструктор
+func NewHostHandler(svc service.ConnectService) *HostHandler {
+	return &HostHandler{svc: svc}
+}
+
+// HostAccountResponse — аккаунт хоста для выплат
+type HostAccountResponse struct {
+	AccountID        string    `json:"account_id"`
+	ChargesEnabled   bool      `json:"charges_enabled"`
+	PayoutsEnabled   bool      `json:"payouts_enabled"`
+	DetailsSubmitted bool      `json:"details_submitted"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func toHostAccountResponse(a repository.HostAccount) HostAccountResponse {
+	return HostAccountResponse{
+		AccountID:        a.StripeAccountID,
+		ChargesEnabled:   a.ChargesEnabled,
+		PayoutsEnabled:   a.PayoutsEnabled,
+		DetailsSubmitted: a.DetailsSubmitted,
+		CreatedAt:        a.CreatedAt,
+		UpdatedAt:        a.UpdatedAt,
+	}
+}
+
+// OnboardingResponse — ссылка на онбординг в Stripe
+type OnboardingResponse struct {
+	HostAccountResponse
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MoneyResponse — сумма в минимальных единицах валюты
+type MoneyResponse struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func toMoneyResponses(list []gateway.Money) []MoneyResponse {
+	out := make([]MoneyResponse, 0, len(list))
+	for _, m := range list {
+		out = append(out, MoneyResponse{Amount: m.Amount, Currency: m.Currency})
+	}
+	return out
+}
+
+// HostBalanceResponse — owed: списано в пользу хоста и ещё не переведено;
+// available/pending — баланс его Stripe-аккаунта (пусто без аккаунта)
+type HostBalanceResponse struct {
+	Owed      []MoneyResponse `json:"owed"`
+	Available []MoneyResponse `json:"available"`
+	Pending   []MoneyResponse `json:"pending"`
+}
+
+// HostTransferResponse — перевод хосту
+type HostTransferResponse struct {
+	ID               int64     `json:"id"`
+	PaymentIntentID  string    `json:"payment_intent_id"`
+	Kind             string    `json:"kind"`
+	Amount           int64     `json:"amount"`
+	PaidAmount       int64     `json:"paid_amount"`
+	ReversedAmount   int64     `json:"reversed_amount"`
+	Currency         string    `json:"currency"`
+	Status           string    `json:"status"`
+	StripeTransferID string    `json:"stripe_transfer_id,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// PayoutResponse — выплата со Stripe-аккаунта хоста на его счёт
+type PayoutResponse struct {
+	ID          string    `json:"id"`
+	Amount      int64     `json:"amount"`
+	Currency    string    `json:"currency"`
+	Status      string    `json:"status"`
+	ArrivalDate time.Time `json:"arrival_date"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// hostErrorStatus дополняет paymentErrorStatus ошибками онбординга
+func hostErrorStatus(err error) int {
+	if errors.Is(err, service.ErrNotConfigured) {
+		return http.StatusServiceUnavailable
+	}
+	return paymentErrorStatus(err)
+}
+
+// hostLimit читает limit из query: 1..100, по умолчанию 20 (столько отдаёт Stripe за раз)
+func hostLimit(c *gin.Context) (int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return 0, false
+	}
+	return limit, true
+}
+
+// Onboard — POST /api/v1/pay/host/account/onboarding
+// Создаёт аккаунт при первом вызове; каждая ссылка одноразовая.
+func (h *HostHandler) Onboard(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	a, link, err := h.svc.Onboard(c.Request.Context(), user.UserID, user.Email)
+	if err != nil {
+		c.JSON(hostErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, OnboardingResponse{
+		HostAccountResponse: toHostAccountResponse(a),
+		URL:                 link.URL,
+		ExpiresAt:           link.ExpiresAt,
+	})
+}
+
+// GetAccount — GET /api/v1/pay/host/account
+func (h *HostHandler) GetAccount(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	a, err := h.svc.Account(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(hostErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toHostAccountResponse(a))
+}
+
+// GetBalance — GET /api/v1/pay/host/balance
+func (h *HostHandler) GetBalance(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	b, err := h.svc.Balance(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(hostErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	resp := HostBalanceResponse{
+		Owed:      toMoneyResponses(b.Owed),
+		Available: []MoneyResponse{},
+		Pending:   []MoneyResponse{},
+	}
+	if b.Account != nil {
+		resp.Available = toMoneyResponses(b.Account.Available)
+		resp.Pending = toMoneyResponses(b.Account.Pending)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListTransfers — GET /api/v1/pay/host/transfers?limit=20
+func (h *HostHandler) ListTransfers(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	limit, ok := hostLimit(c)
+	if !ok {
+		return
+	}
+	list, err := h.svc.Transfers(c.Request.Context(), user.UserID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]HostTransferResponse, 0, len(list))
+	for _, t := range list {
+		out = append(out, HostTransferResponse{
+			ID:               t.ID,
+			PaymentIntentID:  t.StripePIID,
+			Kind:             t.Kind,
+			Amount:           t.Amount,
+			PaidAmount:       t.PaidAmount,
+			ReversedAmount:   t.ReversedAmount,
+			Currency:         t.Currency,
+			Status:           t.Status,
+			StripeTransferID: t.StripeTransferID,
+			CreatedAt:        t.CreatedAt,
+			UpdatedAt:        t.UpdatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// ListPayouts — GET /api/v1/pay/host/payouts?limit=20
+func (h *HostHandler) ListPayouts(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	limit, ok := hostLimit(c)
+	if !ok {
+		return
+	}
+	list, err := h.svc.Payouts(c.Request.Context(), user.UserID, limit)
+	if err != nil {
+		c.JSON(hostErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]PayoutResponse, 0, len(list))
+	for _, p := range list {
+		out = append(out, PayoutResponse{
+			ID:          p.ID,
+			Amount:      p.Amount,
+			Currency:    p.Currency,
+			Status:      p.Status,
+			ArrivalDate: p.ArrivalDate,
+			CreatedAt:   p.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
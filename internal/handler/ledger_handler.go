@@ -0,0 +1,200 @@
+// internal/handler/ledger_handler.go
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LedgerHandler — просмотр главной книги для администраторов
+type LedgerHandler struct {
+	svc service.LedgerService
+}
+
+// NewLedgerHandler конструктор
+func NewLedgerHandler(svc service.LedgerService) *LedgerHandler {
+	return &LedgerHandler{svc: svc}
+}
+
+// Нормальная сторона баланса счёта
+const (
+	normalDebit  = "debit"
+	normalCredit = "credit"
+)
+
+// normalBalance: активы платформы (деньги на карте клиента и на балансе
+// Stripe) растут по дебету, обязательства и доходы — по кредиту
+func normalBalance(accountType string) string {
+	switch accountType {
+	case repository.LedgerCustomer, repository.LedgerPlatform:
+		return normalDebit
+	default:
+		return normalCredit
+	}
+}
+
+// LedgerAccountResponse — счёт главной книги. Balance показан на нормальной
+// стороне счёта: положительный баланс кредитового счёта — это кредит.
+type LedgerAccountResponse struct {
+	ID            int64     `json:"id"`
+	Type          string    `json:"type"`
+	OwnerID       string    `json:"owner_id,omitempty"`
+	Currency      string    `json:"currency"`
+	NormalBalance string    `json:"normal_balance"`
+	Balance       int64     `json:"balance"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func toLedgerAccountResponse(a repository.LedgerAccount) LedgerAccountResponse {
+	resp := LedgerAccountResponse{
+		ID:            a.ID,
+		Type:          a.Type,
+		OwnerID:       a.OwnerID,
+		Currency:      a.Currency,
+		NormalBalance: normalBalance(a.Type),
+		Balance:       a.Balance,
+		CreatedAt:     a.CreatedAt,
+	}
+	if resp.NormalBalance == normalCredit {
+		resp.Balance = -a.Balance
+	}
+	return resp
+}
+
+// LedgerPostingResponse — строка проводки; Amount всегда положительный
+type LedgerPostingResponse struct {
+	AccountID   int64  `json:"account_id"`
+	AccountType string `json:"account_type"`
+	OwnerID     string `json:"owner_id,omitempty"`
+	Direction   string `json:"direction"` // debit | credit
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+}
+
+// LedgerEntryResponse — проводка главной книги
+type LedgerEntryResponse struct {
+	ID          int64                   `json:"id"`
+	Kind        string                  `json:"kind"`
+	Reference   string                  `json:"reference"`
+	Description string                  `json:"description"`
+	CreatedAt   time.Time               `json:"created_at"`
+	Postings    []LedgerPostingResponse `json:"postings"`
+}
+
+func toLedgerEntryResponses(list []repository.LedgerEntry) []LedgerEntryResponse {
+	out := make([]LedgerEntryResponse, 0, len(list))
+	for _, e := range list {
+		resp := LedgerEntryResponse{
+			ID:          e.ID,
+			Kind:        e.Kind,
+			Reference:   e.Reference,
+			Description: e.Description,
+			CreatedAt:   e.CreatedAt,
+			Postings:    make([]LedgerPostingResponse, 0, len(e.Postings)),
+		}
+		for _, p := range e.Postings {
+			posting := LedgerPostingResponse{
+				AccountID:   p.AccountID,
+				AccountType: p.AccountType,
+				OwnerID:     p.OwnerID,
+				Direction:   normalDebit,
+				Amount:      p.Amount,
+				Currency:    p.Currency,
+			}
+			if p.Amount < 0 {
+				posting.Direction = normalCredit
+				posting.Amount = -p.Amount
+			}
+			resp.Postings = append(resp.Postings, posting)
+		}
+		out = append(out, resp)
+	}
+	return out
+}
+
+// ledgerLimit читает limit из query: 1..500, по умолчанию 50
+func ledgerLimit(c *gin.Context) (int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return 0, false
+	}
+	return limit, true
+}
+
+// ListAccounts — GET /api/v1/pay/admin/ledger/accounts?type=&owner_id=&currency=
+func (h *LedgerHandler) ListAccounts(c *gin.Context) {
+	list, err := h.svc.Accounts(c.Request.Context(), repository.LedgerAccountFilter{
+		Type:     c.Query("type"),
+		OwnerID:  c.Query("owner_id"),
+		Currency: c.Query("currency"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]LedgerAccountResponse, 0, len(list))
+	for _, a := range list {
+		out = append(out, toLedgerAccountResponse(a))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetAccount — GET /api/v1/pay/admin/ledger/accounts/:id
+func (h *LedgerHandler) GetAccount(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	a, err := h.svc.Account(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toLedgerAccountResponse(a))
+}
+
+// ListAccountEntries — GET /api/v1/pay/admin/ledger/accounts/:id/entries?limit=50
+func (h *LedgerHandler) ListAccountEntries(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	limit, ok := ledgerLimit(c)
+	if !ok {
+		return
+	}
+	if _, err := h.svc.Account(c.Request.Context(), id); err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	list, err := h.svc.Entries(c.Request.Context(), repository.LedgerEntryFilter{AccountID: id, Limit: limit})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toLedgerEntryResponses(list))
+}
+
+// ListEntries — GET /api/v1/pay/admin/ledger/entries?reference=pi_...&limit=50
+func (h *LedgerHandler) ListEntries(c *gin.Context) {
+	limit, ok := ledgerLimit(c)
+	if !ok {
+		return
+	}
+	list, err := h.svc.Entries(c.Request.Context(), repository.LedgerEntryFilter{
+		Reference: c.Query("reference"),
+		Limit:     limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toLedgerEntryResponses(list))
+}
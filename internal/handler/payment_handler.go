@@ -1,28 +1,37 @@
 package handler
 
 import (
-	"context"
+	"errors"
 	"net/http"
+	"time"
 
+	"Payment-service/internal/middleware"
+	"Payment-service/internal/repository"
 	"Payment-service/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
 // PaymentHandler держит зависимости
 type PaymentHandler struct {
-	svc service.PaymentService
+	svc     service.PaymentService
+	custSvc service.CustomerService
+	access  *AccessChecker
 }
 
 // NewPaymentHandler конструктор
-func NewPaymentHandler(svc service.PaymentService) *PaymentHandler {
-	return &PaymentHandler{svc: svc}
+func NewPaymentHandler(svc service.PaymentService, custSvc service.CustomerService, access *AccessChecker) *PaymentHandler {
+	return &PaymentHandler{svc: svc, custSvc: custSvc, access: access}
 }
 
-// CreatePaymentRequest — payload для /payment-intents
+// CreatePaymentRequest — payload для /payment-intents.
+// user_id нужен, только если сервис действует от своего имени; с
+// X-On-Behalf-Of пользователь берётся из Principal. Stripe Customer
+// определяется по пользователю. listing_id необязателен: объявление
+// берётся из брони, а переданное значение должно с ней совпадать.
 type CreatePaymentRequest struct {
-	UserID        string `json:"user_id" binding:"required"`
-	CustomerID    string `json:"customer_id" binding:"required"`
+	UserID        string `json:"user_id,omitempty"`
 	BookingID     string `json:"booking_id" binding:"required"`
+	ListingID     string `json:"listing_id,omitempty"`
 	Amount        int64  `json:"amount" binding:"required"`
 	Currency      string `json:"currency" binding:"required"`
 	PaymentMehtod string `json:"payment_mehtod,omitempty"`
@@ -41,10 +50,14 @@ func (h *PaymentHandler) CreatePaymentIntent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	secret, piID, err := h.svc.Authorize(context.Background(),
-		req.CustomerID, req.UserID, req.BookingID, req.Currency, req.Amount, req.PaymentMehtod)
+	customerID, ok := h.paymentCustomer(c, &req)
+	if !ok {
+		return
+	}
+	secret, piID, err := h.svc.Authorize(c.Request.Context(),
+		customerID, req.UserID, req.BookingID, req.ListingID, req.Currency, req.Amount, req.PaymentMehtod)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, CreatePaymentResponse{
@@ -53,35 +66,112 @@ func (h *PaymentHandler) CreatePaymentIntent(c *gin.Context) {
 	})
 }
 
+// paymentCustomer определяет плательщика и его Stripe Customer. Пользователь
+// по JWT или с X-On-Behalf-Of платит сам, и чужой user_id в теле отклоняется;
+// сервис от своего имени указывает user_id, у которого уже должен быть Customer.
+// При ошибке сам отвечает клиенту и возвращает false.
+func (h *PaymentHandler) paymentCustomer(c *gin.Context, req *CreatePaymentRequest) (string, bool) {
+	if user, ok := middleware.CurrentPrincipal(c); ok {
+		if req.UserID != "" && req.UserID != user.UserID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "user_id does not match the caller"})
+			return "", false
+		}
+		req.UserID = user.UserID
+		customerID, err := h.custSvc.EnsureCustomer(c.Request.Context(), user.UserID, user.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot ensure customer: " + err.Error()})
+			return "", false
+		}
+		return customerID, true
+	}
+	if req.UserID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required without " + middleware.OnBehalfOfHeader})
+		return "", false
+	}
+	customerID, err := h.custSvc.GetCustomer(c.Request.Context(), req.UserID)
+	if errors.Is(err, service.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user has no Stripe customer"})
+		return "", false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot fetch customer: " + err.Error()})
+		return "", false
+	}
+	return customerID, true
+}
+
 // CapturePaymentRequest — payload для /payment-intents/capture
 type CapturePaymentRequest struct {
 	PaymentIntentID string `json:"payment_intent_id" binding:"required"`
 }
 
 // CapturePayment — POST /api/v1/pay/payment-intents/capture
+// Доступно владельцу платежа, хосту объявления и администратору.
 func (h *PaymentHandler) CapturePayment(c *gin.Context) {
 	var req CapturePaymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.svc.Capture(context.Background(), req.PaymentIntentID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if _, ok := h.access.payment(c, req.PaymentIntentID); !ok {
+		return
+	}
+	if err := h.svc.Capture(c.Request.Context(), req.PaymentIntentID); err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.Status(http.StatusOK)
 }
 
 // CancelPayment — POST /api/v1/pay/payment-intents/cancel
+// Доступно владельцу платежа, хосту объявления и администратору.
 func (h *PaymentHandler) CancelPayment(c *gin.Context) {
 	var req CapturePaymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.svc.Cancel(context.Background(), req.PaymentIntentID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if _, ok := h.access.payment(c, req.PaymentIntentID); !ok {
+		return
+	}
+	if err := h.svc.Cancel(c.Request.Context(), req.PaymentIntentID); err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.Status(http.StatusOK)
 }
+
+// StatusChangeResponse — запись истории статусов в API
+type StatusChangeResponse struct {
+	FromStatus string    `json:"from_status,omitempty"`
+	ToStatus   string    `json:"to_status"`
+	Source     string    `json:"source"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func toStatusChangeResponses(list []repository.StatusChange) []StatusChangeResponse {
+	out := make([]StatusChangeResponse, 0, len(list))
+	for _, ch := range list {
+		out = append(out, StatusChangeResponse{
+			FromStatus: ch.FromStatus,
+			ToStatus:   ch.ToStatus,
+			Source:     ch.Source,
+			CreatedAt:  ch.CreatedAt,
+		})
+	}
+	return out
+}
+
+// GetPaymentHistory — GET /api/v1/pay/payment-intents/:id/history
+func (h *PaymentHandler) GetPaymentHistory(c *gin.Context) {
+	pi, ok := h.access.payment(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	list, err := h.svc.History(c.Request.Context(), pi.StripePIID)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toStatusChangeResponses(list))
+}
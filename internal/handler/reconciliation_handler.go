@@ -0,0 +1,105 @@
+// internal/handler/reconciliation_handler.go
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReconciliationHandler — отчёты сверки со Stripe для администраторов
+type ReconciliationHandler struct {
+	svc service.ReconcileService
+}
+
+// NewReconciliationHandler конструктор
+func NewReconciliationHandler(svc service.ReconcileService) *ReconciliationHandler {
+	return &ReconciliationHandler{svc: svc}
+}
+
+// ReconciliationItemResponse — расхождение, найденное сверкой
+type ReconciliationItemResponse struct {
+	PaymentIntentID string `json:"payment_intent_id"`
+	Kind            string `json:"kind,omitempty"`
+	Result          string `json:"result"`
+	LocalStatus     string `json:"local_status,omitempty"`
+	RemoteStatus    string `json:"remote_status,omitempty"`
+	Detail          string `json:"detail,omitempty"`
+}
+
+// ReconciliationReportResponse — итог прогона сверки; items есть только у одного отчёта
+type ReconciliationReportResponse struct {
+	ID              int64                        `json:"id"`
+	Trigger         string                       `json:"trigger"`
+	Since           time.Time                    `json:"since"`
+	StartedAt       time.Time                    `json:"started_at"`
+	FinishedAt      time.Time                    `json:"finished_at"`
+	Matched         int                          `json:"matched"`
+	Repaired        int                          `json:"repaired"`
+	MissingLocally  int                          `json:"missing_locally"`
+	MissingRemotely int                          `json:"missing_remotely"`
+	Unresolved      int                          `json:"unresolved"`
+	Items           []ReconciliationItemResponse `json:"items,omitempty"`
+}
+
+func toReconciliationReportResponse(r repository.ReconciliationReport) ReconciliationReportResponse {
+	resp := ReconciliationReportResponse{
+		ID:              r.ID,
+		Trigger:         r.TriggeredBy,
+		Since:           r.Since,
+		StartedAt:       r.StartedAt,
+		FinishedAt:      r.FinishedAt,
+		Matched:         r.Matched,
+		Repaired:        r.Repaired,
+		MissingLocally:  r.MissingLocally,
+		MissingRemotely: r.MissingRemotely,
+		Unresolved:      r.Unresolved,
+	}
+	for _, it := range r.Items {
+		resp.Items = append(resp.Items, ReconciliationItemResponse{
+			PaymentIntentID: it.StripePIID,
+			Kind:            it.Kind,
+			Result:          it.Result,
+			LocalStatus:     it.LocalStatus,
+			RemoteStatus:    it.RemoteStatus,
+			Detail:          it.Detail,
+		})
+	}
+	return resp
+}
+
+// ListReports — GET /api/v1/pay/admin/reconciliation/reports?limit=50
+func (h *ReconciliationHandler) ListReports(c *gin.Context) {
+	limit, ok := ledgerLimit(c)
+	if !ok {
+		return
+	}
+	list, err := h.svc.Reports(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]ReconciliationReportResponse, 0, len(list))
+	for _, r := range list {
+		out = append(out, toReconciliationReportResponse(r))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetReport — GET /api/v1/pay/admin/reconciliation/reports/:id
+func (h *ReconciliationHandler) GetReport(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	r, err := h.svc.Report(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toReconciliationReportResponse(r))
+}
@@ -0,0 +1,117 @@
+// internal/handler/refund_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefundHandler держит зависимости для возвратов по платежам
+type RefundHandler struct {
+	svc    service.RefundService
+	access *AccessChecker
+}
+
+// NewRefundHandler конструктор
+func NewRefundHandler(svc service.RefundService, access *AccessChecker) *RefundHandler {
+	return &RefundHandler{svc: svc, access: access}
+}
+
+// RefundPaymentRequest — payload для /payment-intents/refund.
+// amount = 0 (или не указан) — вернуть весь остаток.
+type RefundPaymentRequest struct {
+	PaymentIntentID string `json:"payment_intent_id" binding:"required"`
+	Amount          int64  `json:"amount" binding:"gte=0"`
+	Reason          string `json:"reason"`
+}
+
+// RefundResponse — представление возврата в API
+type RefundResponse struct {
+	RefundID        int64     `json:"refund_id"`
+	StripeRefundID  string    `json:"stripe_refund_id,omitempty"`
+	PaymentIntentID string    `json:"payment_intent_id"`
+	Kind            string    `json:"kind"`
+	Amount          int64     `json:"amount"`
+	Currency        string    `json:"currency"`
+	Reason          string    `json:"reason,omitempty"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func toRefundResponse(r repository.Refund) RefundResponse {
+	return RefundResponse{
+		RefundID:        r.ID,
+		StripeRefundID:  r.StripeRefundID,
+		PaymentIntentID: r.StripePIID,
+		Kind:            r.Kind,
+		Amount:          r.Amount,
+		Currency:        r.Currency,
+		Reason:          r.Reason,
+		Status:          r.Status,
+		CreatedAt:       r.CreatedAt,
+		UpdatedAt:       r.UpdatedAt,
+	}
+}
+
+func toRefundResponses(list []repository.Refund) []RefundResponse {
+	out := make([]RefundResponse, 0, len(list))
+	for _, r := range list {
+		out = append(out, toRefundResponse(r))
+	}
+	return out
+}
+
+// paymentErrorStatus сопоставляет ошибки сервисов capture/refund с HTTP-кодами
+func paymentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidAmount), errors.Is(err, service.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNotCaptured), errors.Is(err, service.ErrRefundExceedsCaptured),
+		errors.Is(err, service.ErrInvalidState):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// RefundPayment — POST /api/v1/pay/payment-intents/refund
+// Доступно владельцу платежа, хосту объявления и администратору.
+func (h *RefundHandler) RefundPayment(c *gin.Context) {
+	var req RefundPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := h.access.payment(c, req.PaymentIntentID); !ok {
+		return
+	}
+	r, err := h.svc.RefundPayment(c.Request.Context(), req.PaymentIntentID, req.Amount, req.Reason)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toRefundResponse(r))
+}
+
+// ListPaymentRefunds — GET /api/v1/pay/payment-intents/:id/refunds
+func (h *RefundHandler) ListPaymentRefunds(c *gin.Context) {
+	pi, ok := h.access.payment(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	list, err := h.svc.ListRefunds(c.Request.Context(), pi.StripePIID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toRefundResponses(list))
+}
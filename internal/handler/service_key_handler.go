@@ -0,0 +1,144 @@
+// internal/handler/service_key_handler.go
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServiceKeyHandler — управление ключами внутренних сервисов (только для администраторов)
+type ServiceKeyHandler struct {
+	svc service.ServiceKeyService
+}
+
+// NewServiceKeyHandler конструктор
+func NewServiceKeyHandler(svc service.ServiceKeyService) *ServiceKeyHandler {
+	return &ServiceKeyHandler{svc: svc}
+}
+
+// CreateServiceKeyRequest — payload для POST /admin/service-keys
+type CreateServiceKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes"`
+}
+
+// ServiceKeyResponse — представление ключа в API.
+// Key (сам ключ) отдаётся только при создании.
+type ServiceKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key,omitempty"`
+	KeyPrefix  string     `json:"key_prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func toServiceKeyResponse(k repository.ServiceKey) ServiceKeyResponse {
+	resp := ServiceKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		KeyPrefix:  k.KeyPrefix,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+	if resp.Scopes == nil {
+		resp.Scopes = []string{}
+	}
+	return resp
+}
+
+// ServiceAuditResponse — запись журнала вызовов
+type ServiceAuditResponse struct {
+	ID         int64     `json:"id"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	OnBehalfOf string    `json:"on_behalf_of,omitempty"`
+	StatusCode int       `json:"status_code"`
+	ClientIP   string    `json:"client_ip"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateServiceKey — POST /api/v1/pay/admin/service-keys
+func (h *ServiceKeyHandler) CreateServiceKey(c *gin.Context) {
+	var req CreateServiceKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, plaintext, err := h.svc.CreateKey(c.Request.Context(), req.Name, req.Scopes)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	resp := toServiceKeyResponse(key)
+	resp.Key = plaintext
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListServiceKeys — GET /api/v1/pay/admin/service-keys
+func (h *ServiceKeyHandler) ListServiceKeys(c *gin.Context) {
+	list, err := h.svc.ListKeys(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]ServiceKeyResponse, 0, len(list))
+	for _, k := range list {
+		out = append(out, toServiceKeyResponse(k))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// RevokeServiceKey — DELETE /api/v1/pay/admin/service-keys/:id
+func (h *ServiceKeyHandler) RevokeServiceKey(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.RevokeKey(c.Request.Context(), id); err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListServiceAudit — GET /api/v1/pay/admin/service-keys/:id/audit?limit=50
+func (h *ServiceKeyHandler) ListServiceAudit(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	list, err := h.svc.ListAudit(c.Request.Context(), id, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]ServiceAuditResponse, 0, len(list))
+	for _, e := range list {
+		out = append(out, ServiceAuditResponse{
+			ID:         e.ID,
+			Method:     e.Method,
+			Path:       e.Path,
+			OnBehalfOf: e.OnBehalfOf,
+			StatusCode: e.StatusCode,
+			ClientIP:   e.ClientIP,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,257 @@
+// internal/handler/subscription_handler.go
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubscriptionHandler — управление исходящими webhooks (только для администраторов)
+type SubscriptionHandler struct {
+	svc service.WebhookService
+}
+
+// NewSubscriptionHandler конструктор
+func NewSubscriptionHandler(svc service.WebhookService) *SubscriptionHandler {
+	return &SubscriptionHandler{svc: svc}
+}
+
+// CreateSubscriptionRequest — payload для POST /admin/webhooks.
+// event_types не указан — подписка на все события.
+type CreateSubscriptionRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+}
+
+// UpdateSubscriptionRequest — payload для PATCH /admin/webhooks/:id; отсутствующие поля не меняются
+type UpdateSubscriptionRequest struct {
+	URL         *string   `json:"url"`
+	EventTypes  *[]string `json:"event_types"`
+	Description *string   `json:"description"`
+	Active      *bool     `json:"active"`
+}
+
+// SubscriptionResponse — представление подписки в API.
+// Secret отдаётся только при создании и ротации.
+type SubscriptionResponse struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	EventTypes  []string  `json:"event_types"`
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func toSubscriptionResponse(sub repository.WebhookSubscription, withSecret bool) SubscriptionResponse {
+	resp := SubscriptionResponse{
+		ID:          sub.ID,
+		URL:         sub.URL,
+		EventTypes:  sub.EventTypes,
+		Description: sub.Description,
+		Active:      sub.Active,
+		CreatedAt:   sub.CreatedAt,
+		UpdatedAt:   sub.UpdatedAt,
+	}
+	if resp.EventTypes == nil {
+		resp.EventTypes = []string{}
+	}
+	if withSecret {
+		resp.Secret = sub.Secret
+	}
+	return resp
+}
+
+// WebhookDeliveryResponse — запись журнала доставок
+type WebhookDeliveryResponse struct {
+	ID             int64      `json:"id"`
+	EventID        int64      `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int       `json:"last_status_code,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+func toWebhookDeliveryResponse(d repository.WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:             d.ID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+	if d.Status == repository.WebhookDeliveryPending {
+		next := d.NextAttemptAt
+		resp.NextAttemptAt = &next
+	}
+	return resp
+}
+
+// adminErrorStatus сопоставляет ошибки админских сервисов (подписки, ключи) с HTTP-кодами
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidInput):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// idParam разбирает числовой :id; при ошибке отвечает 400
+func idParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// CreateSubscription — POST /api/v1/pay/admin/webhooks
+func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
+	var req CreateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub, err := h.svc.CreateSubscription(c.Request.Context(), req.URL, req.EventTypes, req.Description)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, toSubscriptionResponse(sub, true))
+}
+
+// ListSubscriptions — GET /api/v1/pay/admin/webhooks
+func (h *SubscriptionHandler) ListSubscriptions(c *gin.Context) {
+	list, err := h.svc.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]SubscriptionResponse, 0, len(list))
+	for _, sub := range list {
+		out = append(out, toSubscriptionResponse(sub, false))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetSubscription — GET /api/v1/pay/admin/webhooks/:id
+func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	sub, err := h.svc.GetSubscription(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toSubscriptionResponse(sub, false))
+}
+
+// UpdateSubscription — PATCH /api/v1/pay/admin/webhooks/:id
+func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req UpdateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub, err := h.svc.UpdateSubscription(c.Request.Context(), id, service.SubscriptionUpdate{
+		URL:         req.URL,
+		EventTypes:  req.EventTypes,
+		Description: req.Description,
+		Active:      req.Active,
+	})
+	if err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toSubscriptionResponse(sub, false))
+}
+
+// DeleteSubscription — DELETE /api/v1/pay/admin/webhooks/:id
+func (h *SubscriptionHandler) DeleteSubscription(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteSubscription(c.Request.Context(), id); err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RotateSecret — POST /api/v1/pay/admin/webhooks/:id/rotate-secret
+func (h *SubscriptionHandler) RotateSecret(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	sub, err := h.svc.RotateSecret(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toSubscriptionResponse(sub, true))
+}
+
+// ListDeliveries — GET /api/v1/pay/admin/webhooks/:id/deliveries?limit=50
+func (h *SubscriptionHandler) ListDeliveries(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	list, err := h.svc.ListDeliveries(c.Request.Context(), id, limit)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]WebhookDeliveryResponse, 0, len(list))
+	for _, d := range list {
+		out = append(out, toWebhookDeliveryResponse(d))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// RetryDelivery — POST /api/v1/pay/admin/webhook-deliveries/:id/retry
+func (h *SubscriptionHandler) RetryDelivery(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.RetryDelivery(c.Request.Context(), id); err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,282 @@
+// internal/jwks/jwks.go
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// minRefetchInterval limits how often an unknown kid triggers a refetch, so
+// tokens with made-up kids cannot be used to hammer the JWKS endpoint.
+const minRefetchInterval = 30 * time.Second
+
+var (
+	// ErrKeyNotFound is returned when no key matches the token's kid.
+	ErrKeyNotFound = errors.New("jwks: signing key not found")
+	// ErrKeyMismatch is returned when the key found cannot verify the token's alg.
+	ErrKeyMismatch = errors.New("jwks: key does not match algorithm")
+)
+
+// KeySet caches the public keys of a JWKS document and reloads it when the
+// cache gets stale or a token is signed with a kid that is not cached yet
+// (key rotation).
+type KeySet struct {
+	load    func(ctx context.Context) ([]byte, error)
+	refresh time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]jwk
+	fetchedAt time.Time
+	triedAt   time.Time
+}
+
+// NewRemote returns a KeySet that downloads the document from url.
+func NewRemote(url string, refresh time.Duration) *KeySet {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return &KeySet{
+		refresh: refresh,
+		load: func(ctx context.Context) ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+			}
+			return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		},
+	}
+}
+
+// NewFile returns a KeySet that reads the document from a local file.
+// The file is re-read on refresh, so keys can be rotated without a restart.
+func NewFile(path string, refresh time.Duration) *KeySet {
+	return &KeySet{
+		refresh: refresh,
+		load: func(context.Context) ([]byte, error) {
+			return os.ReadFile(path)
+		},
+	}
+}
+
+// Load fetches the document. Call it on startup to fail fast on a bad source.
+func (k *KeySet) Load(ctx context.Context) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.fetchLocked(ctx)
+}
+
+// Key returns the key to verify a token signed with alg by key kid. An empty
+// kid is accepted only when the set has exactly one key.
+func (k *KeySet) Key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	k.mu.RLock()
+	key, ok := k.lookup(kid)
+	stale := time.Since(k.fetchedAt) > k.refresh
+	k.mu.RUnlock()
+
+	if !ok || stale {
+		k.mu.Lock()
+		// Другая горутина могла обновить ключи, пока мы ждали блокировку
+		key, ok = k.lookup(kid)
+		stale = time.Since(k.fetchedAt) > k.refresh
+		if (!ok || stale) && time.Since(k.triedAt) >= minRefetchInterval {
+			if err := k.fetchLocked(ctx); err != nil {
+				// Старые ключи лучше, чем отказ всем пользователям
+				slog.WarnContext(ctx, "JWKS refresh failed, keeping previous keys", "error", err)
+			}
+			key, ok = k.lookup(kid)
+		}
+		k.mu.Unlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: kid %q", ErrKeyNotFound, kid)
+	}
+	return key.forAlg(alg)
+}
+
+func (k *KeySet) lookup(kid string) (jwk, bool) {
+	if kid == "" {
+		if len(k.keys) != 1 {
+			return jwk{}, false
+		}
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+func (k *KeySet) fetchLocked(ctx context.Context) error {
+	k.triedAt = time.Now()
+	raw, err := k.load(ctx)
+	if err != nil {
+		return fmt.Errorf("jwks: load: %w", err)
+	}
+	keys, err := parse(raw)
+	if err != nil {
+		return err
+	}
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	return nil
+}
+
+// jwk is a parsed signing key from the document.
+type jwk struct {
+	alg string // пусто — подходит любой алгоритм своего типа
+	key crypto.PublicKey
+}
+
+// forAlg checks that the key can verify alg, so a token cannot pick an
+// algorithm the key was not meant for.
+func (j jwk) forAlg(alg string) (crypto.PublicKey, error) {
+	if j.alg != "" && j.alg != alg {
+		return nil, fmt.Errorf("%w: key is for %s, token uses %s", ErrKeyMismatch, j.alg, alg)
+	}
+	switch key := j.key.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+			return key, nil
+		}
+	case *ecdsa.PublicKey:
+		if curveFor[alg] == key.Curve {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrKeyMismatch, alg)
+}
+
+var curveFor = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+type rawKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parse reads the signing keys of a JWKS document. Keys of unsupported
+// types and encryption keys are skipped. A malformed key is logged and
+// skipped too, so that it does not block the rotation of the other keys.
+func parse(raw []byte) (map[string]jwk, error) {
+	var doc struct {
+		Keys []rawKey `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("jwks: decode: %w", err)
+	}
+	keys := make(map[string]jwk, len(doc.Keys))
+	for _, rk := range doc.Keys {
+		if rk.Use != "" && rk.Use != "sig" {
+			continue
+		}
+		var (
+			key crypto.PublicKey
+			err error
+		)
+		switch rk.Kty {
+		case "RSA":
+			key, err = parseRSA(rk)
+		case "EC":
+			key, err = parseEC(rk)
+		default:
+			continue
+		}
+		if err != nil {
+			slog.Warn("skipping invalid JWKS key", "kid", rk.Kid, "kty", rk.Kty, "error", err)
+			continue
+		}
+		keys[rk.Kid] = jwk{alg: rk.Alg, key: key}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks: no signing keys in document")
+	}
+	return keys, nil
+}
+
+func parseRSA(rk rawKey) (*rsa.PublicKey, error) {
+	n, err := decodeInt(rk.N)
+	if err != nil {
+		return nil, fmt.Errorf("n: %w", err)
+	}
+	e, err := decodeInt(rk.E)
+	if err != nil {
+		return nil, fmt.Errorf("e: %w", err)
+	}
+	if n.BitLen() < 2048 {
+		return nil, fmt.Errorf("RSA key is too short: %d bits", n.BitLen())
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func parseEC(rk rawKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch rk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", rk.Crv)
+	}
+	x, err := decodeInt(rk.X)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	y, err := decodeInt(rk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+	key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	// ECDH() проверяет, что точка лежит на кривой
+	if _, err := key.ECDH(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,181 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func rsaKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func ecKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func rsaJWK(kid, alg string, key *rsa.PublicKey) rawKey {
+	return rawKey{Kid: kid, Kty: "RSA", Alg: alg, Use: "sig", N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid, crv string, key *ecdsa.PublicKey) rawKey {
+	return rawKey{Kid: kid, Kty: "EC", Crv: crv, X: b64(key.X.Bytes()), Y: b64(key.Y.Bytes())}
+}
+
+func document(t *testing.T, keys ...rawKey) []byte {
+	t.Helper()
+	raw, err := json.Marshal(map[string][]rawKey{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// stubSet отдаёт текущий документ из *doc и считает загрузки
+func stubSet(doc *[]byte, loads *int) *KeySet {
+	return &KeySet{
+		refresh: time.Hour,
+		load: func(context.Context) ([]byte, error) {
+			*loads++
+			return *doc, nil
+		},
+	}
+}
+
+func TestParse_SkipsInvalidKeys(t *testing.T) {
+	good := rsaKey(t, 2048)
+	weak := rsaKey(t, 1024)
+	offCurve := ecJWK("off-curve", "P-256", &ecKey(t, elliptic.P256()).PublicKey)
+	offCurve.Y = b64([]byte{1})
+
+	keys, err := parse(document(t,
+		rsaJWK("good", "RS256", &good.PublicKey),
+		rsaJWK("weak", "RS256", &weak.PublicKey),
+		rawKey{Kid: "bad-n", Kty: "RSA", N: "!!", E: "AQAB"},
+		offCurve,
+		rawKey{Kid: "enc", Kty: "RSA", Use: "enc", N: b64(good.N.Bytes()), E: "AQAB"},
+		rawKey{Kid: "oct", Kty: "oct"},
+	))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("keys = %v, want only the valid signing key", keys)
+	}
+	if _, ok := keys["good"]; !ok {
+		t.Error("valid key was dropped")
+	}
+
+	if _, err := parse(document(t, rsaJWK("weak", "RS256", &weak.PublicKey))); err == nil {
+		t.Error("document with only an RSA key under 2048 bits was accepted")
+	}
+}
+
+func TestJWK_ForAlg(t *testing.T) {
+	rsaPub := &rsaKey(t, 2048).PublicKey
+	p256 := &ecKey(t, elliptic.P256()).PublicKey
+	tests := []struct {
+		name string
+		key  jwk
+		alg  string
+		ok   bool
+	}{
+		{"RSA for RS256", jwk{key: rsaPub}, "RS256", true},
+		{"RSA for PS512", jwk{key: rsaPub}, "PS512", true},
+		{"RSA pinned to RS256 for RS512", jwk{alg: "RS256", key: rsaPub}, "RS512", false},
+		{"RSA for ES256", jwk{key: rsaPub}, "ES256", false},
+		{"RSA for HS256", jwk{key: rsaPub}, "HS256", false},
+		{"P-256 for ES256", jwk{key: p256}, "ES256", true},
+		{"P-256 for ES384", jwk{key: p256}, "ES384", false},
+		{"P-256 for RS256", jwk{key: p256}, "RS256", false},
+		{"P-256 for none", jwk{key: p256}, "none", false},
+	}
+	for _, tt := range tests {
+		_, err := tt.key.forAlg(tt.alg)
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrKeyMismatch) {
+			t.Errorf("%s: err = %v, want ErrKeyMismatch", tt.name, err)
+		}
+	}
+}
+
+func TestKeySet_UnknownKidRefetchesOnceAndFollowsRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := rsaKey(t, 2048), rsaKey(t, 2048)
+	doc := document(t, rsaJWK("old", "RS256", &oldKey.PublicKey))
+	var loads int
+	set := stubSet(&doc, &loads)
+	if err := set.Load(ctx); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// Придуманные kid не долбят endpoint: одна попытка на minRefetchInterval
+	for i := 0; i < 3; i++ {
+		if _, err := set.Key(ctx, "made-up", "RS256"); !errors.Is(err, ErrKeyNotFound) {
+			t.Fatalf("unknown kid: err = %v, want ErrKeyNotFound", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("loads = %d, want no refetch within the rate limit", loads)
+	}
+
+	// Ротация: новый kid появляется в документе после интервала
+	doc = document(t, rsaJWK("old", "RS256", &oldKey.PublicKey), rsaJWK("new", "RS256", &newKey.PublicKey))
+	set.triedAt = time.Now().Add(-minRefetchInterval)
+	key, err := set.Key(ctx, "new", "RS256")
+	if err != nil {
+		t.Fatalf("rotated kid: %v", err)
+	}
+	if !key.(*rsa.PublicKey).Equal(&newKey.PublicKey) {
+		t.Error("rotated kid returned the wrong key")
+	}
+	if loads != 2 {
+		t.Errorf("loads = %d, want exactly one refetch for the rotated kid", loads)
+	}
+	if _, err := set.Key(ctx, "old", "RS256"); err != nil {
+		t.Errorf("old kid after rotation: %v", err)
+	}
+	if loads != 2 {
+		t.Errorf("loads = %d, want cached keys used for known kids", loads)
+	}
+}
+
+func TestKeySet_EmptyKid(t *testing.T) {
+	ctx := context.Background()
+	a, b := rsaKey(t, 2048), rsaKey(t, 2048)
+	var loads int
+
+	single := document(t, rsaJWK("a", "RS256", &a.PublicKey))
+	set := stubSet(&single, &loads)
+	if _, err := set.Key(ctx, "", "RS256"); err != nil {
+		t.Errorf("empty kid with one key: %v", err)
+	}
+
+	several := document(t, rsaJWK("a", "RS256", &a.PublicKey), rsaJWK("b", "RS256", &b.PublicKey))
+	set = stubSet(&several, &loads)
+	if _, err := set.Key(ctx, "", "RS256"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("empty kid with several keys: err = %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,69 @@
+package listingclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// ErrNotFound — объявления с таким ID нет
+var ErrNotFound = errors.New("listing not found")
+
+// Listing — структура, ожидаемая от Listing-service
+type Listing struct {
+	ID     string `json:"id"`
+	HostID string `json:"hostId"`
+}
+
+// Client — HTTP клиент для связи с Listing-service
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// New создаёт новый клиент
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: 5 * time.Second,
+			// Спан на каждый запрос и W3C traceparent в заголовках
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+// GetByID — отправляет GET-запрос на /api/listings/{id}
+func (c *Client) GetByID(ctx context.Context, listingID string) (Listing, error) {
+	u := fmt.Sprintf("%s/api/listings/%s", c.BaseURL, url.PathEscape(listingID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Listing{}, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return Listing{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Listing{}, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Listing{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var listing Listing
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return Listing{}, fmt.Errorf("decode response: %w", err)
+	}
+	return listing, nil
+}
@@ -0,0 +1,113 @@
+// Package logging собирает структурный логгер (log/slog) сервиса: уровень и
+// формат из конфига, поля запроса из контекста и маскирование секретов и PII.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Допустимые значения Options.Format
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Options — настройки логгера
+type Options struct {
+	Level  slog.Level
+	Format string // json или text
+}
+
+// New создаёт логгер, который маскирует секреты, email и данные карт
+// и добавляет к записи поля из контекста (см. With) и trace_id.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	hopts := &slog.HandlerOptions{Level: opts.Level}
+	var h slog.Handler
+	switch opts.Format {
+	case "", FormatJSON:
+		h = slog.NewJSONHandler(w, hopts)
+	case FormatText:
+		h = slog.NewTextHandler(w, hopts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+	return slog.New(&handler{next: h}), nil
+}
+
+// ParseLevel разбирает debug, info, warn или error.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return l, nil
+}
+
+type ctxKey struct{}
+
+// With возвращает контекст, записи по которому получат дополнительные поля:
+// request_id, user_id и т.п. Повторный ключ перекрывает прежнее значение.
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	add := slog.Group("", args...).Value.Group()
+	attrs := make([]slog.Attr, 0, len(prev)+len(add))
+	for _, a := range prev {
+		if !hasKey(add, a.Key) {
+			attrs = append(attrs, a)
+		}
+	}
+	return context.WithValue(ctx, ctxKey{}, append(attrs, add...))
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// handler маскирует чувствительные данные и дописывает поля из контекста.
+type handler struct {
+	next slog.Handler
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, redactString(r.Message), r.PC)
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		for _, a := range attrs {
+			out.AddAttrs(redactAttr(a))
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		out.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	red := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		red[i] = redactAttr(a)
+	}
+	return &handler{next: h.next.WithAttrs(red)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,177 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys — поля, значение которых не пишется никогда. Ключ сравнивается
+// без учёта регистра, "_" и "-".
+var secretKeys = map[string]bool{
+	"secret":        true,
+	"clientsecret":  true,
+	"webhooksecret": true,
+	"password":      true,
+	"token":         true,
+	"accesstoken":   true,
+	"authorization": true,
+	"apikey":        true,
+	"cardnumber":    true,
+	"number":        true,
+	"cvc":           true,
+	"cvv":           true,
+	"payload":       true,
+}
+
+var (
+	// pi_..._secret_... и seti_..._secret_...: ID объекта оставляем
+	clientSecretRe = regexp.MustCompile(`\b((?:pi|seti)_[A-Za-z0-9]+)_secret_[A-Za-z0-9]+`)
+	// ключи Stripe, секреты webhooks и сервисные API-ключи
+	apiKeyRe = regexp.MustCompile(`\b(sk_live|sk_test|rk_live|rk_test|whsec|psk)_[A-Za-z0-9]+`)
+	bearerRe = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`)
+	jwtRe    = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+	emailRe  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// кандидаты в номер карты: 13–19 цифр, возможно через пробел или дефис
+	panRe = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+)
+
+// redactAttr маскирует значение по ключу поля и по содержимому.
+func redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		group := v.Group()
+		out := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			out[i] = redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}
+	}
+
+	if isSecretKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactString(v.String()))
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, redactString(x.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, redactString(x.String()))
+		case []byte:
+			return slog.String(a.Key, redactString(string(x)))
+		default:
+			return slog.Any(a.Key, redactAny(x))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// isSecretKey сравнивает ключ с secretKeys без учёта регистра, "_" и "-"
+func isSecretKey(key string) bool {
+	return secretKeys[strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))]
+}
+
+// redactAny маскирует структуру, map или slice: значение переводится в
+// JSON-вид и маскируется по ключам и содержимому так же, как атрибуты.
+// Значение, которое не сериализуется в JSON, не пишется вовсе.
+func redactAny(x any) any {
+	b, err := json.Marshal(x)
+	if err != nil {
+		return redacted
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // большие числа (номер карты) не теряют точность
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return redacted
+	}
+	return redactJSON(v)
+}
+
+func redactJSON(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			if isSecretKey(k) {
+				x[k] = redacted
+			} else {
+				x[k] = redactJSON(val)
+			}
+		}
+		return x
+	case []any:
+		for i := range x {
+			x[i] = redactJSON(x[i])
+		}
+		return x
+	case string:
+		return redactString(x)
+	case json.Number:
+		if s := redactString(x.String()); s != x.String() {
+			return s
+		}
+		return x
+	}
+	return v
+}
+
+// redactString маскирует секреты, токены, email и номера карт внутри строки.
+func redactString(s string) string {
+	s = clientSecretRe.ReplaceAllString(s, "${1}_secret_"+redacted)
+	s = apiKeyRe.ReplaceAllString(s, "${1}_"+redacted)
+	s = bearerRe.ReplaceAllString(s, "Bearer "+redacted)
+	s = jwtRe.ReplaceAllString(s, redacted)
+	s = emailRe.ReplaceAllStringFunc(s, maskEmail)
+	s = panRe.ReplaceAllStringFunc(s, maskPAN)
+	return s
+}
+
+// maskEmail оставляет первую букву и домен: j***@example.com
+func maskEmail(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 {
+		return redacted
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// maskPAN заменяет номер карты на ****1234. Последовательности цифр, не
+// проходящие проверку Луна (суммы, timestamps), остаются как есть.
+func maskPAN(s string) string {
+	digits := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i])
+		}
+	}
+	if !luhn(digits) {
+		return s
+	}
+	return "****" + string(digits[len(digits)-4:])
+}
+
+func luhn(digits []byte) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,10 @@
+// internal/middleware/admin.go
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// RequireAdmin пропускает только администраторов.
+// Должен стоять после ResolvePrincipal.
+func RequireAdmin() gin.HandlerFunc {
+	return RequireRole(RoleAdmin)
+}
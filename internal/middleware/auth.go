@@ -2,14 +2,41 @@
 package middleware
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
+
+	"Payment-service/internal/jwks"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func JWTAuth(secret string) gin.HandlerFunc {
+// JWTOptions — настройки проверки access-токенов
+type JWTOptions struct {
+	Secret     string       // общий секрет для HS256; пусто — HMAC-токены не принимаются
+	Keys       *jwks.KeySet // публичные ключи для RS*/ES*; nil — такие токены не принимаются
+	Algorithms []string     // допустимые значения alg
+	Issuer     string       // ожидаемый iss; пусто — не проверяется
+	Audience   []string     // токен должен быть выдан хотя бы для одного из них
+	Leeway     time.Duration
+
+	// UserIDClaim — claim с ID пользователя; без него ID берётся из User-service
+	UserIDClaim string
+}
+
+// JWTAuth проверяет Bearer-токен и кладёт в контекст Principal: ID пользователя
+// из UserIDClaim, email из claim email (или sub) и роли.
+// alg токена должен быть в списке допустимых, exp обязателен.
+func JWTAuth(opts JWTOptions) gin.HandlerFunc {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(opts.Algorithms),
+		jwt.WithJSONNumber(),
+		jwt.WithoutClaimsValidation(), // exp/nbf проверяем сами — с учётом Leeway
+	)
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
 		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
@@ -17,20 +44,92 @@ func JWTAuth(secret string) gin.HandlerFunc {
 			return
 		}
 		tokenStr := strings.TrimPrefix(auth, "Bearer ")
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
+		claims := jwt.MapClaims{}
+		token, err := parser.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+				if opts.Secret == "" {
+					return nil, errors.New("HMAC tokens are not accepted")
+				}
+				return []byte(opts.Secret), nil
+			}
+			if opts.Keys == nil {
+				return nil, errors.New("asymmetric tokens are not accepted")
+			}
+			kid, _ := t.Header["kid"].(string)
+			return opts.Keys.Key(c.Request.Context(), kid, t.Method.Alg())
 		})
 		if err != nil || !token.Valid {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
-		claims := token.Claims.(jwt.MapClaims)
-		email, ok := claims["sub"].(string)
-		if !ok {
+		if err := validateClaims(claims, opts, time.Now()); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		p := &Principal{}
+		if opts.UserIDClaim != "" {
+			p.UserID, _ = claims[opts.UserIDClaim].(string)
+		}
+		p.Email, _ = claims["email"].(string)
+		if p.Email == "" && opts.UserIDClaim != "sub" {
+			p.Email, _ = claims["sub"].(string)
+		}
+		if p.UserID == "" && p.Email == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "no sub claim"})
 			return
 		}
-		c.Set("userEmail", email)
+		p.addRoles(rolesFromClaims(claims)...)
+		c.Set(principalKey, p)
 		c.Next()
 	}
 }
+
+// validateClaims проверяет exp, nbf, iss и aud.
+func validateClaims(claims jwt.MapClaims, opts JWTOptions, now time.Time) error {
+	exp, ok, err := timeClaim(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("no exp claim")
+	}
+	if now.After(exp.Add(opts.Leeway)) {
+		return errors.New("token expired")
+	}
+	nbf, ok, err := timeClaim(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(opts.Leeway).Before(nbf) {
+		return errors.New("token not valid yet")
+	}
+	if opts.Issuer != "" && !claims.VerifyIssuer(opts.Issuer, true) {
+		return errors.New("invalid token issuer")
+	}
+	if len(opts.Audience) > 0 {
+		for _, aud := range opts.Audience {
+			if claims.VerifyAudience(aud, true) {
+				return nil
+			}
+		}
+		return errors.New("invalid token audience")
+	}
+	return nil
+}
+
+// timeClaim читает NumericDate-claim (секунды Unix).
+func timeClaim(claims jwt.MapClaims, name string) (time.Time, bool, error) {
+	v, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("invalid %s claim", name)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s claim", name)
+	}
+	return time.Unix(int64(f), 0), true, nil
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Payment-service/internal/jwks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testIssuer   = "https://auth.example.com"
+	testAudience = "payment-service"
+)
+
+// jwksOptions — конфигурация только с JWKS, как в проде без JWT_SECRET
+func jwksOptions(t *testing.T, key *rsa.PublicKey) JWTOptions {
+	t.Helper()
+	doc, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kid": "k1", "kty": "RSA", "alg": "RS256", "use": "sig",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, doc, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys := jwks.NewFile(path, time.Hour)
+	if err := keys.Load(context.Background()); err != nil {
+		t.Fatalf("load JWKS: %v", err)
+	}
+	return JWTOptions{
+		Keys:        keys,
+		Algorithms:  []string{"RS256", "ES256"},
+		Issuer:      testIssuer,
+		Audience:    []string{testAudience},
+		UserIDClaim: "user_id",
+	}
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":     "guest@example.com",
+		"user_id": "user-1",
+		"iss":     testIssuer,
+		"aud":     testAudience,
+		"iat":     now.Unix(),
+		"exp":     now.Add(time.Hour).Unix(),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign %s: %v", method.Alg(), err)
+	}
+	return s
+}
+
+func authStatus(opts JWTOptions, token string) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/me", JWTAuth(opts), func(c *gin.Context) { c.Status(http.StatusOK) })
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestJWTAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := jwksOptions(t, &key.PublicKey)
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: must(x509.MarshalPKIXPublicKey(&key.PublicKey))})
+
+	// Разрешённый HS256 без секрета тоже не должен открывать дорогу подмене alg
+	hmacAllowed := opts
+	hmacAllowed.Algorithms = append([]string{"HS256"}, opts.Algorithms...)
+
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		c := validClaims()
+		change(c)
+		return c
+	}
+	tests := []struct {
+		name  string
+		opts  JWTOptions
+		token string
+		want  int
+	}{
+		{"valid RS256", opts, sign(t, jwt.SigningMethodRS256, "k1", validClaims(), key), http.StatusOK},
+		{"HS256 signed with the public key", opts, sign(t, jwt.SigningMethodHS256, "k1", validClaims(), pubPEM), http.StatusUnauthorized},
+		{"HS256 allowed but no secret", hmacAllowed, sign(t, jwt.SigningMethodHS256, "k1", validClaims(), pubPEM), http.StatusUnauthorized},
+		{"alg none", opts, sign(t, jwt.SigningMethodNone, "k1", validClaims(), jwt.UnsafeAllowNoneSignatureType), http.StatusUnauthorized},
+		{"unknown kid", opts, sign(t, jwt.SigningMethodRS256, "k2", validClaims(), key), http.StatusUnauthorized},
+		{"alg not allowed", opts, sign(t, jwt.SigningMethodRS512, "k1", validClaims(), key), http.StatusUnauthorized},
+		{"expired", opts, sign(t, jwt.SigningMethodRS256, "k1", with(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-time.Minute).Unix()
+		}), key), http.StatusUnauthorized},
+		{"no exp", opts, sign(t, jwt.SigningMethodRS256, "k1", with(func(c jwt.MapClaims) { delete(c, "exp") }), key), http.StatusUnauthorized},
+		{"not valid yet", opts, sign(t, jwt.SigningMethodRS256, "k1", with(func(c jwt.MapClaims) {
+			c["nbf"] = time.Now().Add(time.Hour).Unix()
+		}), key), http.StatusUnauthorized},
+		{"wrong issuer", opts, sign(t, jwt.SigningMethodRS256, "k1", with(func(c jwt.MapClaims) {
+			c["iss"] = "https://evil.example.com"
+		}), key), http.StatusUnauthorized},
+		{"wrong audience", opts, sign(t, jwt.SigningMethodRS256, "k1", with(func(c jwt.MapClaims) {
+			c["aud"] = []string{"other-service"}
+		}), key), http.StatusUnauthorized},
+		{"no user", opts, sign(t, jwt.SigningMethodRS256, "k1", with(func(c jwt.MapClaims) {
+			delete(c, "sub")
+			delete(c, "user_id")
+		}), key), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if got := authStatus(tt.opts, tt.token); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func must(b []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,139 @@
+// internal/middleware/idempotency.go
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader — заголовок, которым клиент помечает повторяемый запрос
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyTTL — сколько храним ответ для повторов
+const idempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLen — ограничение как у Stripe
+const maxIdempotencyKeyLen = 255
+
+// Idempotency возвращает сохранённый ответ на повтор запроса с тем же
+// Idempotency-Key (в рамках пользователя или ключа сервиса и маршрута). Повтор с другим телом
+// отклоняется с 422, параллельный повтор — с 409. Ключ пробрасывается в
+// платёжный шлюз через контекст. Должен стоять после ResolvePrincipal.
+func Idempotency(repo repository.IdempotencyRepo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "read error: " + err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := principal(c)
+		route := c.Request.Method + " " + c.FullPath()
+		sum := sha256.Sum256(body)
+		rec := repository.IdempotencyRecord{
+			UserID:      userID,
+			Key:         key,
+			Route:       route,
+			RequestHash: hex.EncodeToString(sum[:]),
+		}
+
+		existing, err := repo.StartIdempotentRequest(c.Request.Context(), rec, idempotencyTTL)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "idempotency store: " + err.Error()})
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.RequestHash != rec.RequestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request body"})
+			case existing.ResponseCode == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.ResponseCode, existing.ContentType, existing.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		// Ключ для Stripe должен различаться у разных пользователей и маршрутов
+		scoped := sha256.Sum256([]byte(userID + "\x00" + route + "\x00" + key))
+		ctx := gateway.WithIdempotencyKey(c.Request.Context(), hex.EncodeToString(scoped[:16]))
+		c.Request = c.Request.WithContext(ctx)
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		completed := false
+		defer func() {
+			// Ответы 5xx (и паники) не кэшируем: клиент должен иметь возможность повторить запрос
+			if completed {
+				return
+			}
+			if err := repo.ReleaseIdempotentRequest(context.Background(), userID, key, route); err != nil {
+				slog.ErrorContext(c.Request.Context(), "failed to release idempotency key", "error", err)
+			}
+		}()
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		rec.ResponseCode = c.Writer.Status()
+		rec.ResponseBody = w.body.Bytes()
+		rec.ContentType = c.Writer.Header().Get("Content-Type")
+		if err := repo.CompleteIdempotentRequest(c.Request.Context(), rec); err != nil {
+			// Ключ освобождается: иначе он так и остался бы «в обработке» и все
+			// повторы до истечения TTL получали бы 409. Повтор выполнит запрос
+			// заново с тем же ключом для Stripe
+			slog.ErrorContext(c.Request.Context(), "failed to store idempotent response", "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// recordingWriter дублирует тело ответа в буфер
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
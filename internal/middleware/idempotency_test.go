@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Payment-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memIdempotency — IdempotencyRepo в памяти; failComplete ломает сохранение ответа
+type memIdempotency struct {
+	mu           sync.Mutex
+	records      map[string]repository.IdempotencyRecord
+	failComplete bool
+}
+
+func newMemIdempotency() *memIdempotency {
+	return &memIdempotency{records: make(map[string]repository.IdempotencyRecord)}
+}
+
+func idemKey(userID, key, route string) string { return userID + "|" + key + "|" + route }
+
+func (m *memIdempotency) StartIdempotentRequest(ctx context.Context, rec repository.IdempotencyRecord, ttl time.Duration) (*repository.IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := idemKey(rec.UserID, rec.Key, rec.Route)
+	if existing, ok := m.records[k]; ok {
+		return &existing, nil
+	}
+	m.records[k] = rec
+	return nil, nil
+}
+
+func (m *memIdempotency) CompleteIdempotentRequest(ctx context.Context, rec repository.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failComplete {
+		return errors.New("connection reset")
+	}
+	m.records[idemKey(rec.UserID, rec.Key, rec.Route)] = rec
+	return nil
+}
+
+func (m *memIdempotency) ReleaseIdempotentRequest(ctx context.Context, userID, key, route string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, idemKey(userID, key, route))
+	return nil
+}
+
+func idempotentRouter(repo repository.IdempotencyRepo, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/refunds", Idempotency(repo), func(c *gin.Context) {
+		*calls++
+		c.JSON(http.StatusOK, gin.H{"call": *calls})
+	})
+	return r
+}
+
+func postRefund(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/refunds", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	var calls int
+	r := idempotentRouter(newMemIdempotency(), &calls)
+
+	first := postRefund(r, "k1", `{"amount":100}`)
+	second := postRefund(r, "k1", `{"amount":100}`)
+	if calls != 1 || second.Code != http.StatusOK || second.Body.String() != first.Body.String() {
+		t.Errorf("calls = %d, replay = %d %s; want one call and the first response", calls, second.Code, second.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay is not marked with Idempotent-Replayed")
+	}
+	if w := postRefund(r, "k1", `{"amount":200}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("other body with the same key = %d, want 422", w.Code)
+	}
+}
+
+func TestIdempotency_FailedCompletionReleasesKey(t *testing.T) {
+	var calls int
+	repo := newMemIdempotency()
+	repo.failComplete = true
+	r := idempotentRouter(repo, &calls)
+
+	if w := postRefund(r, "k1", `{"amount":100}`); w.Code != http.StatusOK {
+		t.Fatalf("first request = %d, want 200", w.Code)
+	}
+	if len(repo.records) != 0 {
+		t.Fatalf("key left in progress after a failed completion: %+v", repo.records)
+	}
+
+	// Повтор не упирается в 409 до истечения TTL, а выполняется снова
+	repo.failComplete = false
+	if w := postRefund(r, "k1", `{"amount":100}`); w.Code != http.StatusOK || calls != 2 {
+		t.Errorf("retry = %d after %d calls, want 200 from a second call", w.Code, calls)
+	}
+}
@@ -0,0 +1,142 @@
+// internal/middleware/principal.go
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"Payment-service/internal/logging"
+	"Payment-service/internal/userclient"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleAdmin — роль администратора
+const RoleAdmin = "admin"
+
+// principalKey — ключ gin-контекста с *Principal
+const principalKey = "principal"
+
+// Principal — пользователь, от имени которого выполняется запрос
+type Principal struct {
+	UserID string
+	Email  string
+	Roles  []string
+}
+
+// HasRole проверяет, есть ли у пользователя роль
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) addRoles(roles ...string) {
+	for _, r := range roles {
+		if r = strings.TrimSpace(r); r != "" && !p.HasRole(r) {
+			p.Roles = append(p.Roles, r)
+		}
+	}
+}
+
+// CurrentPrincipal возвращает пользователя запроса. Его нет, если сервис
+// вызывает /internal/v1/pay от своего имени.
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok
+}
+
+// HasRole проверяет, есть ли у пользователя запроса роль
+func HasRole(c *gin.Context, role string) bool {
+	p, ok := CurrentPrincipal(c)
+	return ok && p.HasRole(role)
+}
+
+// ResolvePrincipal дополняет Principal, собранный из токена: если в токене нет
+// ID пользователя, находит пользователя по email в User-service и берёт оттуда
+// ID (и роли, если их в токене нет). ID из токена никогда не заменяется.
+// Без ID и email запрос отклоняется с 401. Пользователям из adminEmails
+// выдаёт RoleAdmin. Должен стоять после JWTAuth или ServiceAuth.
+func ResolvePrincipal(uc *userclient.Client, adminEmails []string) gin.HandlerFunc {
+	admins := make(map[string]struct{}, len(adminEmails))
+	for _, e := range adminEmails {
+		admins[strings.ToLower(e)] = struct{}{}
+	}
+	return func(c *gin.Context) {
+		p, ok := CurrentPrincipal(c)
+		if !ok {
+			// Сервис действует от своего имени
+			c.Next()
+			return
+		}
+		if p.UserID == "" && p.Email == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has neither user ID nor email"})
+			return
+		}
+		if p.UserID == "" {
+			user, err := uc.GetByEmail(c.Request.Context(), p.Email)
+			if err != nil {
+				status := http.StatusInternalServerError
+				switch {
+				case errors.Is(err, userclient.ErrNotFound):
+					status = http.StatusNotFound
+				case errors.Is(err, userclient.ErrUnavailable):
+					status = http.StatusServiceUnavailable
+				}
+				c.AbortWithStatusJSON(status, gin.H{"error": "cannot fetch user: " + err.Error()})
+				return
+			}
+			p.UserID = user.ID
+			if len(p.Roles) == 0 {
+				p.addRoles(user.Roles...)
+			}
+		}
+		if _, ok := admins[strings.ToLower(p.Email)]; ok {
+			p.addRoles(RoleAdmin)
+		}
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", p.UserID))
+		c.Next()
+	}
+}
+
+// RequireRole пропускает пользователей, у которых есть хотя бы одна из ролей.
+// Должен стоять после ResolvePrincipal.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, r := range roles {
+			if HasRole(c, r) {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "role " + strings.Join(roles, " or ") + " required"})
+	}
+}
+
+// rolesFromClaims читает claim "roles" (массив или строка через пробел/запятую)
+// и одиночный "role".
+func rolesFromClaims(claims map[string]interface{}) []string {
+	var out []string
+	switch v := claims["roles"].(type) {
+	case []interface{}:
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				out = append(out, s)
+			}
+		}
+	case string:
+		out = append(out, strings.FieldsFunc(v, func(r rune) bool { return r == ' ' || r == ',' })...)
+	}
+	if r, ok := claims["role"].(string); ok && r != "" {
+		out = append(out, r)
+	}
+	return out
+}
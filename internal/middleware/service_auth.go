@@ -0,0 +1,128 @@
+// internal/middleware/service_auth.go
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Payment-service/internal/logging"
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Заголовки внутренних вызовов
+const (
+	APIKeyHeader     = "X-Api-Key"
+	OnBehalfOfHeader = "X-On-Behalf-Of" // email пользователя, от имени которого действует сервис
+)
+
+// serviceKeyCtx — ключ gin-контекста с ключом сервиса
+const serviceKeyCtx = "serviceKey"
+
+// ServiceKey возвращает ключ сервиса, которым аутентифицирован запрос
+func ServiceKey(c *gin.Context) (repository.ServiceKey, bool) {
+	v, ok := c.Get(serviceKeyCtx)
+	if !ok {
+		return repository.ServiceKey{}, false
+	}
+	key, ok := v.(repository.ServiceKey)
+	return key, ok
+}
+
+// ActingService — запрос сделан сервисом от своего имени, а не от имени пользователя
+func ActingService(c *gin.Context) bool {
+	_, isService := ServiceKey(c)
+	_, isUser := CurrentPrincipal(c)
+	return isService && !isUser
+}
+
+// ServiceAuth проверяет ключ сервиса из X-Api-Key и пишет каждый вызов в журнал.
+// С X-On-Behalf-Of (нужен scope on_behalf_of) запрос выполняется от имени
+// пользователя: дальше он проверяется так же, как запрос с его токеном.
+func ServiceAuth(keys service.ServiceKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plaintext := c.GetHeader(APIKeyHeader)
+		if plaintext == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing API key"})
+			return
+		}
+		key, err := keys.Authenticate(c.Request.Context(), plaintext)
+		if errors.Is(err, service.ErrNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "api key store: " + err.Error()})
+			return
+		}
+		c.Set(serviceKeyCtx, key)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "service_key_id", key.ID))
+
+		onBehalfOf := strings.TrimSpace(c.GetHeader(OnBehalfOfHeader))
+		defer func() {
+			// Журнал пишем и для отклонённых запросов; отмена запроса клиентом не должна его терять
+			entry := repository.ServiceAuditEntry{
+				KeyID:      key.ID,
+				Method:     c.Request.Method,
+				Path:       c.Request.URL.Path,
+				OnBehalfOf: onBehalfOf,
+				StatusCode: c.Writer.Status(),
+				ClientIP:   c.ClientIP(),
+			}
+			if err := keys.RecordCall(context.WithoutCancel(c.Request.Context()), entry); err != nil {
+				slog.ErrorContext(c.Request.Context(), "failed to audit service call", "error", err)
+			}
+		}()
+
+		if onBehalfOf != "" {
+			if !hasScope(key, service.ScopeOnBehalfOf) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "scope " + service.ScopeOnBehalfOf + " required"})
+				return
+			}
+			c.Set(principalKey, &Principal{Email: onBehalfOf})
+		}
+		c.Next()
+	}
+}
+
+// RequireScope пропускает ключи, у которых есть хотя бы один из scopes.
+// Должен стоять после ServiceAuth.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, _ := ServiceKey(c)
+		for _, sc := range scopes {
+			if hasScope(key, sc) {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "scope " + strings.Join(scopes, " or ") + " required"})
+	}
+}
+
+func hasScope(key repository.ServiceKey, scope string) bool {
+	for _, sc := range key.Scopes {
+		if sc == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// principal — от чьего имени выполняется запрос: пользователь или ключ сервиса
+func principal(c *gin.Context) string {
+	var userID string
+	if p, ok := CurrentPrincipal(c); ok {
+		userID = p.UserID
+	}
+	if key, ok := ServiceKey(c); ok {
+		return "service:" + strconv.FormatInt(key.ID, 10) + ":" + userID
+	}
+	return userID
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS deposits;
+DROP TABLE IF EXISTS payment_intents;
+DROP TABLE IF EXISTS payment_methods;
+DROP TABLE IF EXISTS customers;
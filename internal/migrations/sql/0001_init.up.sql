@@ -0,0 +1,52 @@
+-- Базовая схема Payment-service: клиенты, карты, платежи и депозиты.
+
+CREATE TABLE IF NOT EXISTS customers (
+    user_id    TEXT        PRIMARY KEY,
+    email      TEXT        NOT NULL,
+    stripe_id  TEXT        NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS payment_methods (
+    id           BIGSERIAL   PRIMARY KEY,
+    user_id      TEXT        NOT NULL,
+    stripe_pm_id TEXT        NOT NULL UNIQUE,
+    card_brand   TEXT        NOT NULL DEFAULT '',
+    card_last4   TEXT        NOT NULL DEFAULT '',
+    exp_month    INTEGER     NOT NULL DEFAULT 0,
+    exp_year     INTEGER     NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_methods_user_id ON payment_methods (user_id);
+
+CREATE TABLE IF NOT EXISTS payment_intents (
+    id           BIGSERIAL   PRIMARY KEY,
+    stripe_pi_id TEXT        NOT NULL UNIQUE,
+    booking_id   TEXT        NOT NULL,
+    user_id      TEXT        NOT NULL,
+    amount       BIGINT      NOT NULL CHECK (amount > 0),
+    currency     TEXT        NOT NULL,
+    status       TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_intents_booking_id ON payment_intents (booking_id);
+CREATE INDEX IF NOT EXISTS idx_payment_intents_user_id ON payment_intents (user_id);
+
+CREATE TABLE IF NOT EXISTS deposits (
+    id           BIGSERIAL   PRIMARY KEY,
+    stripe_pi_id TEXT        NOT NULL UNIQUE,
+    booking_id   TEXT        NOT NULL,
+    listing_id   TEXT        NOT NULL,
+    user_id      TEXT        NOT NULL,
+    amount       BIGINT      NOT NULL CHECK (amount > 0),
+    currency     TEXT        NOT NULL,
+    status       TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposits_booking_id ON deposits (booking_id);
+CREATE INDEX IF NOT EXISTS idx_deposits_user_id ON deposits (user_id);
@@ -0,0 +1,16 @@
+-- Возвраты по захваченным платежам и депозитам.
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id               BIGSERIAL   PRIMARY KEY,
+    stripe_refund_id TEXT        UNIQUE,
+    stripe_pi_id     TEXT        NOT NULL,
+    kind             TEXT        NOT NULL CHECK (kind IN ('payment', 'deposit')),
+    amount           BIGINT      NOT NULL CHECK (amount > 0),
+    currency         TEXT        NOT NULL,
+    reason           TEXT        NOT NULL DEFAULT '',
+    status           TEXT        NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_stripe_pi_id ON refunds (stripe_pi_id);
@@ -0,0 +1,4 @@
+ALTER TABLE deposits DROP CONSTRAINT IF EXISTS deposits_captured_amount_check;
+ALTER TABLE deposits
+    DROP COLUMN IF EXISTS capture_reason,
+    DROP COLUMN IF EXISTS captured_amount;
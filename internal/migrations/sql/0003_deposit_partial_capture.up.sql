@@ -0,0 +1,11 @@
+-- Частичный захват депозита: сколько фактически списано и почему.
+
+ALTER TABLE deposits
+    ADD COLUMN IF NOT EXISTS captured_amount BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS capture_reason  TEXT   NOT NULL DEFAULT '';
+
+-- Депозиты, захваченные до миграции, списаны полностью
+UPDATE deposits SET captured_amount = amount WHERE status = 'succeeded' AND captured_amount = 0;
+
+ALTER TABLE deposits
+    ADD CONSTRAINT deposits_captured_amount_check CHECK (captured_amount >= 0 AND captured_amount <= amount);
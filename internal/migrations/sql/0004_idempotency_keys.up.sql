@@ -0,0 +1,16 @@
+-- Сохранённые ответы для запросов с заголовком Idempotency-Key.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id       TEXT        NOT NULL,
+    idem_key      TEXT        NOT NULL,
+    route         TEXT        NOT NULL,
+    request_hash  TEXT        NOT NULL,
+    response_code INTEGER,
+    response_body BYTEA,
+    content_type  TEXT        NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at  TIMESTAMPTZ,
+    PRIMARY KEY (user_id, idem_key, route)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys (created_at);
@@ -0,0 +1,16 @@
+-- Журнал входящих событий Stripe: дедупликация, повторы и ручной replay.
+
+CREATE TABLE IF NOT EXISTS stripe_events (
+    id              TEXT        PRIMARY KEY,
+    type            TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    received_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    processed_at    TIMESTAMPTZ,
+    error           TEXT,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_stripe_events_pending
+    ON stripe_events (next_attempt_at)
+    WHERE processed_at IS NULL;
@@ -0,0 +1,13 @@
+-- История статусов платежей и депозитов: откуда пришёл переход и когда.
+
+CREATE TABLE IF NOT EXISTS status_history (
+    id           BIGSERIAL   PRIMARY KEY,
+    stripe_pi_id TEXT        NOT NULL,
+    kind         TEXT        NOT NULL CHECK (kind IN ('payment', 'deposit')),
+    from_status  TEXT        NOT NULL DEFAULT '',
+    to_status    TEXT        NOT NULL,
+    source       TEXT        NOT NULL CHECK (source IN ('api', 'webhook', 'reconciler')),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_history_pi ON status_history (stripe_pi_id, id);
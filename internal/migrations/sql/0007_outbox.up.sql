@@ -0,0 +1,24 @@
+-- Transactional outbox: доменные события для других сервисов.
+-- Пишутся в одной транзакции с изменением данных, публикуются relay-воркером.
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id              BIGSERIAL   PRIMARY KEY,
+    aggregate_type  TEXT        NOT NULL,
+    aggregate_id    TEXT        NOT NULL,
+    event_type      TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at    TIMESTAMPTZ,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Поиск более раннего неопубликованного события того же агрегата
+CREATE INDEX IF NOT EXISTS idx_outbox_events_aggregate
+    ON outbox_events (aggregate_type, aggregate_id, id)
+    WHERE published_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending
+    ON outbox_events (next_attempt_at)
+    WHERE published_at IS NULL;
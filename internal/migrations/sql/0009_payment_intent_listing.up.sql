@@ -0,0 +1,4 @@
+-- Объявление, к которому относится платёж: хост объявления может управлять платежом.
+
+ALTER TABLE payment_intents
+    ADD COLUMN IF NOT EXISTS listing_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS service_audit_log;
+DROP TABLE IF EXISTS service_api_keys;
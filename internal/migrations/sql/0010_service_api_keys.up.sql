@@ -0,0 +1,25 @@
+-- Ключи внутренних сервисов для /internal/v1/pay и журнал их вызовов.
+
+CREATE TABLE IF NOT EXISTS service_api_keys (
+    id           BIGSERIAL   PRIMARY KEY,
+    name         TEXT        NOT NULL,
+    key_prefix   TEXT        NOT NULL,        -- начало ключа, чтобы узнать его в списке
+    key_hash     TEXT        NOT NULL UNIQUE, -- hex(SHA-256) ключа; сам ключ не храним
+    scopes       TEXT[]      NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS service_audit_log (
+    id           BIGSERIAL   PRIMARY KEY,
+    key_id       BIGINT      NOT NULL REFERENCES service_api_keys (id),
+    method       TEXT        NOT NULL,
+    path         TEXT        NOT NULL,
+    on_behalf_of TEXT        NOT NULL DEFAULT '',
+    status_code  INTEGER     NOT NULL,
+    client_ip    TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_audit_log_key_id ON service_audit_log (key_id, created_at);
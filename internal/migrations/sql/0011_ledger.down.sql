@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP FUNCTION IF EXISTS ledger_check_balanced();
+DROP FUNCTION IF EXISTS ledger_immutable();
+
+ALTER TABLE deposits DROP COLUMN IF EXISTS host_id;
+ALTER TABLE payment_intents DROP COLUMN IF EXISTS host_id;
@@ -0,0 +1,80 @@
+-- Двойная запись для всех движений денег: счета, проводки и их строки.
+-- Сумма строки положительная для дебета и отрицательная для кредита;
+-- сумма строк каждой проводки равна нулю, проводки не меняются и не удаляются.
+
+-- Хост объявления фиксируется при авторизации: на него начисляется host_payable
+ALTER TABLE payment_intents
+    ADD COLUMN IF NOT EXISTS host_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE deposits
+    ADD COLUMN IF NOT EXISTS host_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id         BIGSERIAL   PRIMARY KEY,
+    type       TEXT        NOT NULL CHECK (type IN
+        ('customer', 'platform', 'host_payable', 'payments_held', 'deposits_held', 'fees', 'refunds')),
+    owner_id   TEXT        NOT NULL DEFAULT '',
+    currency   TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (type, owner_id, currency)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id              BIGSERIAL   PRIMARY KEY,
+    idempotency_key TEXT        NOT NULL UNIQUE,
+    kind            TEXT        NOT NULL,
+    reference       TEXT        NOT NULL,
+    description     TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_reference ON ledger_entries (reference);
+
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id         BIGSERIAL PRIMARY KEY,
+    entry_id   BIGINT    NOT NULL REFERENCES ledger_entries (id),
+    account_id BIGINT    NOT NULL REFERENCES ledger_accounts (id),
+    amount     BIGINT    NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_entry ON ledger_postings (entry_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_account ON ledger_postings (account_id, entry_id);
+
+CREATE OR REPLACE FUNCTION ledger_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger % rows are immutable', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_immutable
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_immutable();
+
+CREATE TRIGGER ledger_postings_immutable
+    BEFORE UPDATE OR DELETE ON ledger_postings
+    FOR EACH ROW EXECUTE FUNCTION ledger_immutable();
+
+-- Баланс проверяется при коммите, когда все строки проводки уже вставлены
+CREATE OR REPLACE FUNCTION ledger_check_balanced() RETURNS trigger AS $$
+DECLARE
+    total      BIGINT;
+    currencies INT;
+BEGIN
+    SELECT COALESCE(SUM(p.amount), 0), COUNT(DISTINCT a.currency)
+      INTO total, currencies
+      FROM ledger_postings p
+      JOIN ledger_accounts a ON a.id = p.account_id
+     WHERE p.entry_id = NEW.entry_id;
+    IF total <> 0 THEN
+        RAISE EXCEPTION 'ledger entry % is not balanced: %', NEW.entry_id, total;
+    END IF;
+    IF currencies > 1 THEN
+        RAISE EXCEPTION 'ledger entry % mixes currencies', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced
+    AFTER INSERT ON ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_check_balanced();
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS host_transfers;
+DROP TABLE IF EXISTS host_accounts;
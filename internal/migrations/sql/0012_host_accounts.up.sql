@@ -0,0 +1,34 @@
+-- Stripe Connect: аккаунты хостов для выплат и переводы им списанных денег.
+
+CREATE TABLE IF NOT EXISTS host_accounts (
+    host_id           TEXT        PRIMARY KEY,
+    stripe_account_id TEXT        NOT NULL UNIQUE,
+    charges_enabled   BOOLEAN     NOT NULL DEFAULT false,
+    payouts_enabled   BOOLEAN     NOT NULL DEFAULT false,
+    details_submitted BOOLEAN     NOT NULL DEFAULT false,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Перевод ставится в очередь вместе со списанием платежа или депозита и
+-- выполняется, когда аккаунт хоста может принимать выплаты
+CREATE TABLE IF NOT EXISTS host_transfers (
+    id                 BIGSERIAL   PRIMARY KEY,
+    stripe_pi_id       TEXT        NOT NULL UNIQUE,
+    kind               TEXT        NOT NULL CHECK (kind IN ('payment', 'deposit')),
+    host_id            TEXT        NOT NULL,
+    amount             BIGINT      NOT NULL CHECK (amount > 0),
+    currency           TEXT        NOT NULL,
+    status             TEXT        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'failed')),
+    stripe_transfer_id TEXT        NOT NULL DEFAULT '',
+    attempts           INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error         TEXT,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_host_transfers_host ON host_transfers (host_id, id);
+CREATE INDEX IF NOT EXISTS idx_host_transfers_pending
+    ON host_transfers (next_attempt_at)
+    WHERE status = 'pending';
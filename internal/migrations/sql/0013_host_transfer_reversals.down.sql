@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS host_transfer_reversals;
+ALTER TABLE host_transfers
+    DROP COLUMN IF EXISTS reversed_amount,
+    DROP COLUMN IF EXISTS paid_amount;
+
+DELETE FROM host_transfers WHERE status = 'canceled' OR amount = 0;
+ALTER TABLE host_transfers DROP CONSTRAINT IF EXISTS host_transfers_status_check;
+ALTER TABLE host_transfers
+    ADD CONSTRAINT host_transfers_status_check CHECK (status IN ('pending', 'paid', 'failed'));
+ALTER TABLE host_transfers DROP CONSTRAINT IF EXISTS host_transfers_amount_check;
+ALTER TABLE host_transfers ADD CONSTRAINT host_transfers_amount_check CHECK (amount > 0);
@@ -0,0 +1,34 @@
+-- Перевод хосту следует за его долей с учётом возвратов: ожидающий перевод
+-- пересчитывается (и отменяется, если доля стала нулевой), а излишек уже
+-- выполненного перевода возвращается transfer reversal'ом.
+
+ALTER TABLE host_transfers DROP CONSTRAINT IF EXISTS host_transfers_amount_check;
+ALTER TABLE host_transfers ADD CONSTRAINT host_transfers_amount_check CHECK (amount >= 0);
+ALTER TABLE host_transfers DROP CONSTRAINT IF EXISTS host_transfers_status_check;
+ALTER TABLE host_transfers
+    ADD CONSTRAINT host_transfers_status_check CHECK (status IN ('pending', 'paid', 'failed', 'canceled'));
+
+-- amount — сколько причитается хосту сейчас, paid_amount — сколько ему
+-- перевели, reversed_amount — сколько поставлено на возврат с его аккаунта
+ALTER TABLE host_transfers
+    ADD COLUMN IF NOT EXISTS paid_amount     BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS reversed_amount BIGINT NOT NULL DEFAULT 0;
+UPDATE host_transfers SET paid_amount = amount WHERE status = 'paid';
+
+CREATE TABLE IF NOT EXISTS host_transfer_reversals (
+    id                 BIGSERIAL   PRIMARY KEY,
+    host_transfer_id   BIGINT      NOT NULL REFERENCES host_transfers (id),
+    amount             BIGINT      NOT NULL CHECK (amount > 0),
+    status             TEXT        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'failed')),
+    stripe_reversal_id TEXT        NOT NULL DEFAULT '',
+    attempts           INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error         TEXT,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_host_transfer_reversals_transfer ON host_transfer_reversals (host_transfer_id);
+CREATE INDEX IF NOT EXISTS idx_host_transfer_reversals_pending
+    ON host_transfer_reversals (next_attempt_at)
+    WHERE status = 'pending';
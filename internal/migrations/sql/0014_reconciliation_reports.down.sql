@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_deposits_created_at;
+DROP INDEX IF EXISTS idx_payment_intents_created_at;
+DROP TABLE IF EXISTS reconciliation_items;
+DROP TABLE IF EXISTS reconciliation_reports;
@@ -0,0 +1,33 @@
+-- Отчёты сверки платежей и депозитов со Stripe. Совпавшие PaymentIntents
+-- только считаются, расхождения сохраняются построчно.
+
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id               BIGSERIAL   PRIMARY KEY,
+    triggered_by     TEXT        NOT NULL CHECK (triggered_by IN ('schedule', 'manual')),
+    since            TIMESTAMPTZ NOT NULL,
+    started_at       TIMESTAMPTZ NOT NULL,
+    finished_at      TIMESTAMPTZ NOT NULL,
+    matched          INTEGER     NOT NULL DEFAULT 0,
+    repaired         INTEGER     NOT NULL DEFAULT 0,
+    missing_locally  INTEGER     NOT NULL DEFAULT 0,
+    missing_remotely INTEGER     NOT NULL DEFAULT 0,
+    unresolved       INTEGER     NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS reconciliation_items (
+    id            BIGSERIAL PRIMARY KEY,
+    report_id     BIGINT    NOT NULL REFERENCES reconciliation_reports (id) ON DELETE CASCADE,
+    stripe_pi_id  TEXT      NOT NULL,
+    kind          TEXT      NOT NULL DEFAULT '',
+    result        TEXT      NOT NULL
+        CHECK (result IN ('repaired', 'missing_locally', 'missing_remotely', 'unresolved')),
+    local_status  TEXT      NOT NULL DEFAULT '',
+    remote_status TEXT      NOT NULL DEFAULT '',
+    detail        TEXT      NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_items_report ON reconciliation_items (report_id, id);
+
+-- Сверка выбирает платежи и депозиты за последние дни
+CREATE INDEX IF NOT EXISTS idx_payment_intents_created_at ON payment_intents (created_at);
+CREATE INDEX IF NOT EXISTS idx_deposits_created_at ON deposits (created_at);
@@ -0,0 +1,10 @@
+UPDATE status_history SET source = 'api' WHERE source = 'scheduler';
+ALTER TABLE status_history DROP CONSTRAINT IF EXISTS status_history_source_check;
+ALTER TABLE status_history
+    ADD CONSTRAINT status_history_source_check CHECK (source IN ('api', 'webhook', 'reconciler'));
+
+DROP TABLE IF EXISTS deposit_hold_policies;
+DROP INDEX IF EXISTS idx_deposits_hold_expires_at;
+ALTER TABLE deposits
+    DROP COLUMN IF EXISTS expiry_notified_at,
+    DROP COLUMN IF EXISTS hold_expires_at;
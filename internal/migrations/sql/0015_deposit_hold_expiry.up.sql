@@ -0,0 +1,32 @@
+-- Истечение авторизации депозитов: срок hold, уведомление о скором
+-- истечении и политика, что делать с депозитом перед истечением.
+
+ALTER TABLE deposits
+    ADD COLUMN IF NOT EXISTS hold_expires_at    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMPTZ;
+
+-- Депозиты, авторизованные до миграции: capture_before в БД нет, поэтому срок
+-- считается, как и в сервисе без capture_before, от создания PaymentIntent.
+-- 7 дней — значение DEPOSIT_HOLD_TTL по умолчанию и срок Stripe для онлайн-
+-- платежей картой; миграция не видит конфигурацию, при другом DEPOSIT_HOLD_TTL
+-- срок старых депозитов нужно поправить вручную.
+UPDATE deposits
+SET hold_expires_at = created_at + INTERVAL '7 days'
+WHERE status = 'requires_capture' AND hold_expires_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_deposits_hold_expires_at
+    ON deposits (hold_expires_at, stripe_pi_id)
+    WHERE status = 'requires_capture';
+
+CREATE TABLE IF NOT EXISTS deposit_hold_policies (
+    scope      TEXT        NOT NULL CHECK (scope IN ('listing', 'booking')),
+    scope_id   TEXT        NOT NULL,
+    action     TEXT        NOT NULL CHECK (action IN ('none', 'capture', 'release')),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (scope, scope_id)
+);
+
+-- Истёкшие депозиты отмечает планировщик
+ALTER TABLE status_history DROP CONSTRAINT IF EXISTS status_history_source_check;
+ALTER TABLE status_history
+    ADD CONSTRAINT status_history_source_check CHECK (source IN ('api', 'webhook', 'reconciler', 'scheduler'));
@@ -0,0 +1,70 @@
+// internal/paymentstate/paymentstate.go
+package paymentstate
+
+import "Payment-service/internal/gateway"
+
+// StatusExpired is the final status of a deposit hold that lapsed before it was
+// captured or released. It is ours, not Stripe's: Stripe reports such an
+// intent as canceled with cancellation_reason "automatic".
+const StatusExpired = "expired"
+
+// transitions lists the statuses a PaymentIntent (payment or deposit) may move
+// to from each status. succeeded, canceled and expired are final: refunds are
+// tracked separately and never change the payment status.
+var transitions = map[string][]string{
+	gateway.StatusRequiresPaymentMethod: {
+		gateway.StatusRequiresConfirmation,
+		gateway.StatusRequiresAction,
+		gateway.StatusProcessing,
+		gateway.StatusRequiresCapture,
+		gateway.StatusSucceeded,
+		gateway.StatusCanceled,
+	},
+	gateway.StatusRequiresConfirmation: {
+		gateway.StatusRequiresPaymentMethod,
+		gateway.StatusRequiresAction,
+		gateway.StatusProcessing,
+		gateway.StatusRequiresCapture,
+		gateway.StatusSucceeded,
+		gateway.StatusCanceled,
+	},
+	gateway.StatusRequiresAction: {
+		gateway.StatusRequiresPaymentMethod,
+		gateway.StatusRequiresConfirmation,
+		gateway.StatusProcessing,
+		gateway.StatusRequiresCapture,
+		gateway.StatusSucceeded,
+		gateway.StatusCanceled,
+	},
+	gateway.StatusProcessing: {
+		gateway.StatusRequiresPaymentMethod,
+		gateway.StatusRequiresCapture,
+		gateway.StatusSucceeded,
+		gateway.StatusCanceled,
+	},
+	gateway.StatusRequiresCapture: {
+		gateway.StatusProcessing,
+		gateway.StatusSucceeded,
+		gateway.StatusCanceled,
+		StatusExpired,
+	},
+}
+
+// CanTransition reports whether a payment in status from may move to status to.
+// Staying in the same status is always allowed.
+func CanTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no further transitions are possible from status.
+func IsFinal(status string) bool {
+	return len(transitions[status]) == 0
+}
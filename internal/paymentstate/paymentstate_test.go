@@ -0,0 +1,91 @@
+package paymentstate
+
+import (
+	"testing"
+
+	"Payment-service/internal/gateway"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		// Подтверждение и авторизация
+		{gateway.StatusRequiresPaymentMethod, gateway.StatusRequiresAction, true},
+		{gateway.StatusRequiresPaymentMethod, gateway.StatusRequiresCapture, true},
+		{gateway.StatusRequiresPaymentMethod, gateway.StatusSucceeded, true},
+		{gateway.StatusRequiresAction, gateway.StatusRequiresPaymentMethod, true},
+		{gateway.StatusRequiresConfirmation, gateway.StatusProcessing, true},
+		{gateway.StatusProcessing, gateway.StatusSucceeded, true},
+		{gateway.StatusProcessing, gateway.StatusRequiresPaymentMethod, true},
+
+		// Hold
+		{gateway.StatusRequiresCapture, gateway.StatusSucceeded, true},
+		{gateway.StatusRequiresCapture, gateway.StatusCanceled, true},
+		{gateway.StatusRequiresCapture, StatusExpired, true},
+		{gateway.StatusRequiresCapture, gateway.StatusRequiresPaymentMethod, false},
+		{gateway.StatusProcessing, gateway.StatusRequiresAction, false},
+
+		// Истекает только авторизованный hold
+		{gateway.StatusRequiresPaymentMethod, StatusExpired, false},
+		{gateway.StatusProcessing, StatusExpired, false},
+
+		// Финальные статусы
+		{gateway.StatusSucceeded, gateway.StatusCanceled, false},
+		{gateway.StatusSucceeded, gateway.StatusRequiresCapture, false},
+		{gateway.StatusCanceled, gateway.StatusSucceeded, false},
+		{gateway.StatusCanceled, gateway.StatusRequiresPaymentMethod, false},
+		{StatusExpired, gateway.StatusCanceled, false},
+		{StatusExpired, gateway.StatusSucceeded, false},
+
+		// Повтор того же статуса (повторный webhook) допустим
+		{gateway.StatusSucceeded, gateway.StatusSucceeded, true},
+		{StatusExpired, StatusExpired, true},
+
+		// Неизвестные статусы
+		{"unknown", gateway.StatusSucceeded, false},
+		{gateway.StatusRequiresCapture, "unknown", false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	final := map[string]bool{
+		gateway.StatusRequiresPaymentMethod: false,
+		gateway.StatusRequiresConfirmation:  false,
+		gateway.StatusRequiresAction:        false,
+		gateway.StatusProcessing:            false,
+		gateway.StatusRequiresCapture:       false,
+		gateway.StatusSucceeded:             true,
+		gateway.StatusCanceled:              true,
+		StatusExpired:                       true,
+	}
+	for status, want := range final {
+		if got := IsFinal(status); got != want {
+			t.Errorf("IsFinal(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+// Из любого нефинального статуса можно уйти в canceled, а из финального — никуда
+func TestTransitionTableIsConsistent(t *testing.T) {
+	for from, next := range transitions {
+		if !CanTransition(from, gateway.StatusCanceled) {
+			t.Errorf("%s cannot be canceled", from)
+		}
+		for _, to := range next {
+			if to == from {
+				t.Errorf("%s lists itself as a transition", from)
+			}
+			_, open := transitions[to]
+			if !open && to != gateway.StatusSucceeded && to != gateway.StatusCanceled && to != StatusExpired {
+				t.Errorf("%s -> %s leads to an unknown status", from, to)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Payment-service/internal/events"
+)
+
+// HTTP posts each event as JSON to a single endpoint. Any 2xx response is an ack.
+type HTTP struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTP конструктор
+func NewHTTP(url string, timeout time.Duration) *HTTP {
+	return &HTTP{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (p *HTTP) Publish(ctx context.Context, e events.Envelope) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", strconv.FormatInt(e.ID, 10))
+	req.Header.Set("X-Event-Type", e.Type)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("event endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *HTTP) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,46 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"Payment-service/internal/events"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka writes events to a topic keyed by aggregate, so all events of one
+// payment or deposit land in the same partition and keep their order.
+type Kafka struct {
+	w *kafka.Writer
+}
+
+// NewKafka конструктор; соединение с брокерами устанавливается при первой записи.
+func NewKafka(brokers []string, topic string) *Kafka {
+	return &Kafka{w: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}}
+}
+
+func (p *Kafka) Publish(ctx context.Context, e events.Envelope) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return p.w.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(e.AggregateType + ":" + e.AggregateID),
+		Value: body,
+		Headers: []kafka.Header{
+			{Key: "event-id", Value: []byte(strconv.FormatInt(e.ID, 10))},
+			{Key: "event-type", Value: []byte(e.Type)},
+		},
+	})
+}
+
+func (p *Kafka) Close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,31 @@
+package publisher
+
+import (
+	"context"
+	"log/slog"
+
+	"Payment-service/internal/events"
+)
+
+// Log prints events instead of publishing them; for local development.
+type Log struct{}
+
+// NewLog конструктор
+func NewLog() *Log {
+	return &Log{}
+}
+
+func (p *Log) Publish(ctx context.Context, e events.Envelope) error {
+	slog.InfoContext(ctx, "outbox event",
+		"event_id", e.ID,
+		"event_type", e.Type,
+		"aggregate_type", e.AggregateType,
+		"aggregate_id", e.AggregateID,
+		"data", string(e.Data),
+	)
+	return nil
+}
+
+func (p *Log) Close() error {
+	return nil
+}
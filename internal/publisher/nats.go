@@ -0,0 +1,56 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"Payment-service/internal/events"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATS publishes events to JetStream on subject "<prefix>.<event type>",
+// e.g. payments.deposit.status_changed. A stream must cover these subjects.
+// The event ID is sent as Nats-Msg-Id, so JetStream drops redeliveries
+// within its duplicate window.
+type NATS struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	prefix string
+}
+
+// NewNATS подключается к NATS; при недоступности сервера переподключается в фоне.
+func NewNATS(url, subjectPrefix string) (*NATS, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("payment-service"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NATS{conn: conn, js: js, prefix: subjectPrefix}, nil
+}
+
+func (p *NATS) Publish(ctx context.Context, e events.Envelope) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(p.prefix + "." + e.Type)
+	msg.Data = body
+	msg.Header.Set("Content-Type", "application/json")
+	msg.Header.Set("Event-Type", e.Type)
+	_, err = p.js.PublishMsg(msg, nats.Context(ctx), nats.MsgId(strconv.FormatInt(e.ID, 10)))
+	return err
+}
+
+func (p *NATS) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,17 @@
+// internal/publisher/publisher.go
+package publisher
+
+import (
+	"context"
+
+	"Payment-service/internal/events"
+)
+
+// Publisher delivers outbox events to other services. Publish must return nil
+// only once the broker or endpoint has accepted the event; the relay retries
+// otherwise, so delivery is at-least-once and consumers dedupe by Envelope.ID.
+type Publisher interface {
+	Publish(ctx context.Context, e events.Envelope) error
+	// Close flushes and releases connections.
+	Close() error
+}
@@ -0,0 +1,41 @@
+// internal/repository/deposit_hold_policy_repo.go
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// К чему относится политика истечения hold; политика брони важнее политики объявления
+const (
+	HoldPolicyScopeListing = "listing"
+	HoldPolicyScopeBooking = "booking"
+)
+
+// Что сделать с депозитом незадолго до истечения авторизации
+const (
+	HoldActionNone    = "none"    // дождаться истечения и отметить депозит expired
+	HoldActionCapture = "capture" // списать всю сумму
+	HoldActionRelease = "release" // отпустить hold
+)
+
+// DepositHoldPolicy описывает запись из таблицы deposit_hold_policies
+type DepositHoldPolicy struct {
+	Scope     string    `db:"scope"`
+	ScopeID   string    `db:"scope_id"`
+	Action    string    `db:"action"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// DepositHoldPolicyRepo описывает политики истечения hold
+type DepositHoldPolicyRepo interface {
+	// SaveDepositHoldPolicy создаёт или заменяет политику
+	SaveDepositHoldPolicy(ctx context.Context, p DepositHoldPolicy) (DepositHoldPolicy, error)
+	// GetDepositHoldPolicy возвращает политику; sql.ErrNoRows, если её нет
+	GetDepositHoldPolicy(ctx context.Context, scope, scopeID string) (DepositHoldPolicy, error)
+	// DeleteDepositHoldPolicy удаляет политику; sql.ErrNoRows, если её не было
+	DeleteDepositHoldPolicy(ctx context.Context, scope, scopeID string) error
+	// ResolveDepositHoldPolicy возвращает политику брони, а без неё — объявления;
+	// sql.ErrNoRows, если нет ни той, ни другой
+	ResolveDepositHoldPolicy(ctx context.Context, bookingID, listingID string) (DepositHoldPolicy, error)
+}
@@ -12,12 +12,27 @@ type Deposit struct {
 	StripePIID string    `db:"stripe_pi_id"`
 	BookingID  string    `db:"booking_id"`
 	ListingID  string    `db:"listing_id"`
+	HostID     string    `db:"host_id"` // хост объявления на момент авторизации
 	UserID     string    `db:"user_id"`
-	Amount     int64     `db:"amount"`
+	Amount     int64     `db:"amount"` // авторизованная сумма (hold)
 	Currency   string    `db:"currency"`
 	Status     string    `db:"status"`
 	CreatedAt  time.Time `db:"created_at"`
 	UpdatedAt  time.Time `db:"updated_at"`
+	// CapturedAmount — фактически списанная сумма, может быть меньше Amount
+	CapturedAmount int64  `db:"captured_amount"`
+	CaptureReason  string `db:"capture_reason"`
+	// HoldExpiresAt — когда истечёт авторизация: capture_before карты из Stripe,
+	// без него — создание PaymentIntent + DEPOSIT_HOLD_TTL. Задаётся при переходе в requires_capture
+	HoldExpiresAt *time.Time `db:"hold_expires_at"`
+	// ExpiryNotifiedAt — когда опубликовано событие deposit.hold_expiring
+	ExpiryNotifiedAt *time.Time `db:"expiry_notified_at"`
+}
+
+// DepositCursor — позиция в списке истекающих депозитов
+type DepositCursor struct {
+	HoldExpiresAt time.Time
+	StripePIID    string
 }
 
 // DepositRepo описывает операции над таблицей deposits
@@ -26,12 +41,24 @@ type Deposit struct {
 type DepositRepo interface {
 	// CreateDeposit сохраняет новый депозит (PaymentIntent) с ручным захватом
 	CreateDeposit(ctx context.Context, d Deposit) error
-	// UpdateDepositStatus обновляет статус существующего депозита
-	UpdateDepositStatus(ctx context.Context, stripePIID, status string) error
+	// UpdateDepositStatus меняет статус fromStatus → toStatus и пишет переход в историю.
+	// Если текущий статус уже не fromStatus — ErrStatusChanged
+	UpdateDepositStatus(ctx context.Context, stripePIID, fromStatus, toStatus, source string) error
+	// UpdateDepositCapture как UpdateDepositStatus, но также сохраняет фактически списанную сумму.
+	// Пустой reason не затирает сохранённую причину
+	UpdateDepositCapture(ctx context.Context, stripePIID, fromStatus, toStatus string, capturedAmount int64, reason, source string) error
 	// GetDepositByID возвращает депозит по Stripe PaymentIntent ID
 	GetDepositByID(ctx context.Context, stripePIID string) (Deposit, error)
 	// ListDepositsByBookingID возвращает все депозиты, связанные с конкретной бронью
 	ListDepositsByBookingID(ctx context.Context, bookingID string) ([]Deposit, error)
 	// ListDepositsByUserID возвращает все депозиты данного пользователя
 	ListDepositsByUserID(ctx context.Context, userID string) ([]Deposit, error)
+	// SetDepositHoldExpiry запоминает срок авторизации, если он ещё не задан
+	SetDepositHoldExpiry(ctx context.Context, stripePIID string, expiresAt time.Time) error
+	// ListExpiringDeposits возвращает депозиты в requires_capture, чья авторизация
+	// истекает до before, по возрастанию срока, начиная после after (nil — с начала)
+	ListExpiringDeposits(ctx context.Context, before time.Time, after *DepositCursor, limit int) ([]Deposit, error)
+	// MarkDepositExpiryNotified отмечает уведомление об истечении и пишет событие
+	// deposit.hold_expiring. false — уведомление уже было отправлено
+	MarkDepositExpiryNotified(ctx context.Context, stripePIID string) (bool, error)
 }
@@ -0,0 +1,115 @@
+// internal/repository/host_repo.go
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Статусы перевода хосту
+const (
+	HostTransferPending  = "pending" // ждёт выполнения или аккаунта хоста
+	HostTransferPaid     = "paid"
+	HostTransferFailed   = "failed"   // попытки исчерпаны
+	HostTransferCanceled = "canceled" // доля хоста полностью возвращена до перевода
+)
+
+// HostAccount — Stripe Connect аккаунт хоста
+type HostAccount struct {
+	HostID           string    `db:"host_id"`
+	StripeAccountID  string    `db:"stripe_account_id"`
+	ChargesEnabled   bool      `db:"charges_enabled"`
+	PayoutsEnabled   bool      `db:"payouts_enabled"`
+	DetailsSubmitted bool      `db:"details_submitted"`
+	CreatedAt        time.Time `db:"created_at"`
+	UpdatedAt        time.Time `db:"updated_at"`
+}
+
+// HostTransfer — перевод хосту его доли списанного платежа или депозита.
+// Amount следует за долей хоста с учётом возвратов; если уже переведено
+// больше, излишек возвращается через HostTransferReversal.
+type HostTransfer struct {
+	ID               int64     `db:"id"`
+	StripePIID       string    `db:"stripe_pi_id"`
+	Kind             string    `db:"kind"` // RefundKindPayment или RefundKindDeposit
+	HostID           string    `db:"host_id"`
+	Amount           int64     `db:"amount"`
+	PaidAmount       int64     `db:"paid_amount"`     // сколько переведено
+	ReversedAmount   int64     `db:"reversed_amount"` // сколько поставлено на возврат с аккаунта хоста
+	Currency         string    `db:"currency"`
+	Status           string    `db:"status"`
+	StripeTransferID string    `db:"stripe_transfer_id"`
+	Attempts         int       `db:"attempts"`
+	NextAttemptAt    time.Time `db:"next_attempt_at"`
+	LastError        *string   `db:"last_error"`
+	CreatedAt        time.Time `db:"created_at"`
+	UpdatedAt        time.Time `db:"updated_at"`
+
+	// StripeAccountID заполняется только ClaimHostTransfers
+	StripeAccountID string `db:"stripe_account_id"`
+}
+
+// HostTransferReversal — возврат части выполненного перевода с аккаунта
+// хоста, когда платёж или депозит вернули покупателю уже после перевода.
+// Статусы те же, что у HostTransfer, кроме canceled.
+type HostTransferReversal struct {
+	ID               int64     `db:"id"`
+	HostTransferID   int64     `db:"host_transfer_id"`
+	Amount           int64     `db:"amount"`
+	Status           string    `db:"status"`
+	StripeReversalID string    `db:"stripe_reversal_id"`
+	Attempts         int       `db:"attempts"`
+	NextAttemptAt    time.Time `db:"next_attempt_at"`
+	LastError        *string   `db:"last_error"`
+	CreatedAt        time.Time `db:"created_at"`
+	UpdatedAt        time.Time `db:"updated_at"`
+
+	// Поля перевода заполняются только ClaimHostTransferReversals
+	StripePIID       string `db:"stripe_pi_id"`
+	Kind             string `db:"kind"`
+	HostID           string `db:"host_id"`
+	Currency         string `db:"currency"`
+	StripeTransferID string `db:"stripe_transfer_id"`
+}
+
+// HostAccountRepo описывает аккаунты хостов
+type HostAccountRepo interface {
+	// CreateHostAccount сохраняет аккаунт. Если у хоста аккаунт уже есть,
+	// возвращает существующий.
+	CreateHostAccount(ctx context.Context, a HostAccount) (HostAccount, error)
+	// GetHostAccount возвращает аккаунт хоста или sql.ErrNoRows
+	GetHostAccount(ctx context.Context, hostID string) (HostAccount, error)
+	// UpdateHostAccountStatus сохраняет состояние онбординга по Stripe Account ID;
+	// sql.ErrNoRows — аккаунт не наш
+	UpdateHostAccountStatus(ctx context.Context, a HostAccount) (HostAccount, error)
+}
+
+// HostTransferRepo описывает очередь переводов хостам
+type HostTransferRepo interface {
+	// UpsertHostTransfer ставит перевод в очередь или меняет сумму уже
+	// поставленного перевода по тому же PaymentIntent. Ожидающий перевод с
+	// нулевой суммой отменяется; выполненный остаётся paid. Возвращает перевод.
+	UpsertHostTransfer(ctx context.Context, t HostTransfer) (HostTransfer, error)
+	// ClaimHostTransfers занимает на lease до limit ожидающих переводов хостам,
+	// чьи аккаунты могут принимать выплаты
+	ClaimHostTransfers(ctx context.Context, limit int, lease time.Duration) ([]HostTransfer, error)
+	// MarkHostTransferPaid сохраняет ID перевода в Stripe и переведённую сумму.
+	// Возвращает перевод: его Amount мог уменьшиться, пока шёл вызов Stripe.
+	MarkHostTransferPaid(ctx context.Context, id int64, stripeTransferID string, paidAmount int64) (HostTransfer, error)
+	// MarkHostTransferFailed сохраняет ошибку и время следующей попытки;
+	// final — больше не пытаться
+	MarkHostTransferFailed(ctx context.Context, id int64, errMsg string, nextAttemptAt time.Time, final bool) error
+	// ListHostTransfers возвращает переводы хоста, от новых к старым
+	ListHostTransfers(ctx context.Context, hostID string, limit int) ([]HostTransfer, error)
+
+	// EnqueueHostTransferReversal ставит в очередь возврат amount с аккаунта
+	// хоста и увеличивает ReversedAmount перевода
+	EnqueueHostTransferReversal(ctx context.Context, hostTransferID, amount int64) error
+	// ClaimHostTransferReversals занимает на lease до limit ожидающих возвратов
+	ClaimHostTransferReversals(ctx context.Context, limit int, lease time.Duration) ([]HostTransferReversal, error)
+	// MarkHostTransferReversalPaid сохраняет ID reversal'а в Stripe
+	MarkHostTransferReversalPaid(ctx context.Context, id int64, stripeReversalID string) error
+	// MarkHostTransferReversalFailed сохраняет ошибку и время следующей попытки;
+	// final — больше не пытаться
+	MarkHostTransferReversalFailed(ctx context.Context, id int64, errMsg string, nextAttemptAt time.Time, final bool) error
+}
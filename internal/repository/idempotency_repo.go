@@ -0,0 +1,31 @@
+// internal/repository/idempotency_repo.go
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord описывает запись из таблицы idempotency_keys.
+// Пока запрос выполняется, ResponseCode == 0.
+type IdempotencyRecord struct {
+	UserID       string    `db:"user_id"`
+	Key          string    `db:"idem_key"`
+	Route        string    `db:"route"`
+	RequestHash  string    `db:"request_hash"`
+	ResponseCode int       `db:"response_code"`
+	ResponseBody []byte    `db:"response_body"`
+	ContentType  string    `db:"content_type"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// IdempotencyRepo хранит ответы на запросы с Idempotency-Key
+type IdempotencyRepo interface {
+	// StartIdempotentRequest занимает ключ (user, key, route). Если ключ уже занят
+	// и не старше ttl, возвращает существующую запись; nil — ключ захвачен нами.
+	StartIdempotentRequest(ctx context.Context, rec IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error)
+	// CompleteIdempotentRequest сохраняет ответ для повторов
+	CompleteIdempotentRequest(ctx context.Context, rec IdempotencyRecord) error
+	// ReleaseIdempotentRequest освобождает ключ, чтобы запрос можно было повторить
+	ReleaseIdempotentRequest(ctx context.Context, userID, key, route string) error
+}
@@ -0,0 +1,102 @@
+// internal/repository/ledger_repo.go
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Типы счетов главной книги
+const (
+	LedgerCustomer     = "customer"      // владелец — пользователь: суммы, заблокированные на его карте
+	LedgerPlatform     = "platform"      // деньги платформы на балансе Stripe
+	LedgerHostPayable  = "host_payable"  // владелец — хост: сколько мы ему должны
+	LedgerPaymentsHeld = "payments_held" // авторизованные, но не списанные платежи
+	LedgerDepositsHeld = "deposits_held" // авторизованные, но не списанные депозиты
+	LedgerFees         = "fees"          // комиссия платформы
+	LedgerRefunds      = "refunds"       // возвраты, ещё не завершённые в Stripe
+)
+
+// LedgerAccount — счёт главной книги вместе с текущим балансом.
+// Balance — сумма всех строк: дебет положительный, кредит отрицательный.
+type LedgerAccount struct {
+	ID        int64     `db:"id"`
+	Type      string    `db:"type"`
+	OwnerID   string    `db:"owner_id"`
+	Currency  string    `db:"currency"`
+	Balance   int64     `db:"balance"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// LedgerAccountRef определяет счёт по типу, владельцу и валюте;
+// счёт создаётся при первой проводке по нему.
+type LedgerAccountRef struct {
+	Type     string
+	OwnerID  string
+	Currency string
+}
+
+// LedgerLine — строка новой проводки: Amount > 0 — дебет, < 0 — кредит
+type LedgerLine struct {
+	Account LedgerAccountRef
+	Amount  int64
+}
+
+// NewLedgerEntry — проводка к записи. IdempotencyKey не даёт провести одно
+// и то же движение денег дважды.
+type NewLedgerEntry struct {
+	IdempotencyKey string
+	Kind           string
+	Reference      string // Stripe PaymentIntent ID
+	Description    string
+	Lines          []LedgerLine
+}
+
+// LedgerEntry — сохранённая проводка
+type LedgerEntry struct {
+	ID             int64           `db:"id"`
+	IdempotencyKey string          `db:"idempotency_key"`
+	Kind           string          `db:"kind"`
+	Reference      string          `db:"reference"`
+	Description    string          `db:"description"`
+	CreatedAt      time.Time       `db:"created_at"`
+	Postings       []LedgerPosting `db:"-"`
+}
+
+// LedgerPosting — строка сохранённой проводки вместе со счётом
+type LedgerPosting struct {
+	ID          int64  `db:"id"`
+	EntryID     int64  `db:"entry_id"`
+	AccountID   int64  `db:"account_id"`
+	AccountType string `db:"account_type"`
+	OwnerID     string `db:"owner_id"`
+	Currency    string `db:"currency"`
+	Amount      int64  `db:"amount"`
+}
+
+// LedgerAccountFilter — пустые поля не фильтруют
+type LedgerAccountFilter struct {
+	Type     string
+	OwnerID  string
+	Currency string
+}
+
+// LedgerEntryFilter — проводки по счёту и/или по PaymentIntent, от новых к старым
+type LedgerEntryFilter struct {
+	AccountID int64  // 0 — любой счёт
+	Reference string // пусто — любой
+	Limit     int
+}
+
+// LedgerRepo описывает главную книгу. Проводки только добавляются.
+type LedgerRepo interface {
+	// PostLedgerEntry сохраняет проводку со строками. Если проводка с таким
+	// IdempotencyKey уже есть, ничего не делает и возвращает false.
+	PostLedgerEntry(ctx context.Context, e NewLedgerEntry) (bool, error)
+	// ListLedgerEntries возвращает проводки со строками
+	ListLedgerEntries(ctx context.Context, f LedgerEntryFilter) ([]LedgerEntry, error)
+	// ListLedgerAccounts возвращает счета с балансами
+	ListLedgerAccounts(ctx context.Context, f LedgerAccountFilter) ([]LedgerAccount, error)
+	// GetLedgerAccount возвращает счёт с балансом или sql.ErrNoRows
+	GetLedgerAccount(ctx context.Context, id int64) (LedgerAccount, error)
+}
@@ -0,0 +1,33 @@
+// internal/repository/outbox_repo.go
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxEvent описывает запись из таблицы outbox_events.
+// События пишутся хранилищем в той же транзакции, что и изменение данных.
+type OutboxEvent struct {
+	ID            int64      `db:"id"`
+	AggregateType string     `db:"aggregate_type"`
+	AggregateID   string     `db:"aggregate_id"`
+	EventType     string     `db:"event_type"`
+	Payload       []byte     `db:"payload"`
+	CreatedAt     time.Time  `db:"created_at"`
+	PublishedAt   *time.Time `db:"published_at"`
+	Attempts      int        `db:"attempts"`
+	LastError     *string    `db:"last_error"`
+	NextAttemptAt time.Time  `db:"next_attempt_at"`
+}
+
+// OutboxRepo описывает операции relay над outbox
+type OutboxRepo interface {
+	// ClaimOutboxEvents занимает на lease до limit неопубликованных событий —
+	// только самое раннее событие каждого агрегата, чтобы сохранить порядок
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]OutboxEvent, error)
+	// MarkOutboxEventPublished отмечает событие опубликованным
+	MarkOutboxEventPublished(ctx context.Context, id int64) error
+	// MarkOutboxEventFailed сохраняет ошибку и откладывает следующую попытку
+	MarkOutboxEventFailed(ctx context.Context, id int64, errMsg string, nextAttemptAt time.Time) error
+}
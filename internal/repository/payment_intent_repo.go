@@ -3,20 +3,24 @@ package repository
 import "context"
 
 type PaymentIntent struct {
-	StripePIID string
-	BookingID  string
-	UserID     string
-	Amount     int64
-	Currency   string
-	Status     string
-	CreatedAt  string
-	UpdatedAt  string
+	StripePIID string `db:"stripe_pi_id"`
+	BookingID  string `db:"booking_id"`
+	ListingID  string `db:"listing_id"` // может быть пустым
+	HostID     string `db:"host_id"`    // хост объявления; пусто, если объявления нет
+	UserID     string `db:"user_id"`
+	Amount     int64  `db:"amount"`
+	Currency   string `db:"currency"`
+	Status     string `db:"status"`
+	CreatedAt  string `db:"created_at"`
+	UpdatedAt  string `db:"updated_at"`
 }
 
 // PaymentIntentRepo описывает операции над payment_intents
 
 type PaymentIntentRepo interface {
 	CreatePaymentIntent(ctx context.Context, pi PaymentIntent) error
-	UpdatePaymentIntentStatus(ctx context.Context, stripePIID, status string) error
+	// UpdatePaymentIntentStatus меняет статус fromStatus → toStatus и пишет переход в историю.
+	// Если текущий статус уже не fromStatus — ErrStatusChanged
+	UpdatePaymentIntentStatus(ctx context.Context, stripePIID, fromStatus, toStatus, source string) error
 	GetPaymentIntentByID(ctx context.Context, stripePIID string) (PaymentIntent, error)
 }
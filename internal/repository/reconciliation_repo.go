@@ -0,0 +1,74 @@
+// internal/repository/reconciliation_repo.go
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Что запустило сверку
+const (
+	ReconcileTriggerSchedule = "schedule" // фоновый воркер
+	ReconcileTriggerManual   = "manual"   // подкоманда reconcile
+)
+
+// Результаты сверки одного PaymentIntent. Совпавшие записи только считаются,
+// остальные сохраняются в отчёт.
+const (
+	ReconcileMatched         = "matched"
+	ReconcileRepaired        = "repaired"         // статус или сумма исправлены по данным шлюза
+	ReconcileMissingLocally  = "missing_locally"  // есть в шлюзе, нет ни платежа, ни депозита
+	ReconcileMissingRemotely = "missing_remotely" // есть у нас, шлюз его не знает
+	ReconcileUnresolved      = "unresolved"       // расхождение не удалось исправить автоматически
+)
+
+// ReconcileRecord — платёж или депозит в том виде, в каком его сверяют со шлюзом
+type ReconcileRecord struct {
+	StripePIID     string `db:"stripe_pi_id"`
+	Kind           string `db:"kind"` // RefundKindPayment или RefundKindDeposit
+	Status         string `db:"status"`
+	Amount         int64  `db:"amount"`
+	CapturedAmount int64  `db:"captured_amount"` // у платежей всегда 0
+}
+
+// ReconciliationItem — расхождение, найденное сверкой
+type ReconciliationItem struct {
+	ID           int64  `db:"id"`
+	ReportID     int64  `db:"report_id"`
+	StripePIID   string `db:"stripe_pi_id"`
+	Kind         string `db:"kind"` // пусто для missing_locally
+	Result       string `db:"result"`
+	LocalStatus  string `db:"local_status"`
+	RemoteStatus string `db:"remote_status"`
+	Detail       string `db:"detail"`
+}
+
+// ReconciliationReport — итог одного прогона сверки
+type ReconciliationReport struct {
+	ID              int64     `db:"id"`
+	TriggeredBy     string    `db:"triggered_by"` // ReconcileTriggerSchedule или ReconcileTriggerManual
+	Since           time.Time `db:"since"`        // сверялись PaymentIntents, созданные начиная с этого момента
+	StartedAt       time.Time `db:"started_at"`
+	FinishedAt      time.Time `db:"finished_at"`
+	Matched         int       `db:"matched"`
+	Repaired        int       `db:"repaired"`
+	MissingLocally  int       `db:"missing_locally"`
+	MissingRemotely int       `db:"missing_remotely"`
+	Unresolved      int       `db:"unresolved"`
+	// Items заполняет только GetReconciliationReport
+	Items []ReconciliationItem `db:"-"`
+}
+
+// ReconciliationRepo описывает данные для сверки и её отчёты
+type ReconciliationRepo interface {
+	// ListReconcileRecords возвращает платежи и депозиты, созданные начиная с since
+	ListReconcileRecords(ctx context.Context, since time.Time) ([]ReconcileRecord, error)
+	// GetReconcileRecord ищет платёж или депозит по PaymentIntent ID; sql.ErrNoRows, если нет ни того, ни другого
+	GetReconcileRecord(ctx context.Context, stripePIID string) (ReconcileRecord, error)
+	// CreateReconciliationReport сохраняет отчёт вместе с Items и возвращает его ID
+	CreateReconciliationReport(ctx context.Context, r ReconciliationReport) (int64, error)
+	// ListReconciliationReports возвращает отчёты без Items, от новых к старым
+	ListReconciliationReports(ctx context.Context, limit int) ([]ReconciliationReport, error)
+	// GetReconciliationReport возвращает отчёт вместе с Items; sql.ErrNoRows, если его нет
+	GetReconciliationReport(ctx context.Context, id int64) (ReconciliationReport, error)
+}
@@ -0,0 +1,46 @@
+// internal/repository/refund_repo.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Виды платежей, к которым относится возврат
+const (
+	RefundKindPayment = "payment"
+	RefundKindDeposit = "deposit"
+)
+
+// ErrRefundLimitExceeded — сумма возвратов превысила захваченную сумму
+var ErrRefundLimitExceeded = errors.New("refunds exceed captured amount")
+
+// Refund описывает запись из таблицы refunds
+type Refund struct {
+	ID             int64     `db:"id"`
+	StripeRefundID string    `db:"stripe_refund_id"` // пусто, пока Stripe не ответил
+	StripePIID     string    `db:"stripe_pi_id"`
+	Kind           string    `db:"kind"`
+	Amount         int64     `db:"amount"`
+	Currency       string    `db:"currency"`
+	Reason         string    `db:"reason"`
+	Status         string    `db:"status"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+// RefundRepo описывает операции над таблицей refunds
+type RefundRepo interface {
+	// ReserveRefund атомарно проверяет, что сумма активных возвратов вместе с r.Amount
+	// не превышает captured, и сохраняет возврат. Иначе — ErrRefundLimitExceeded.
+	ReserveRefund(ctx context.Context, r Refund, captured int64) (Refund, error)
+	// CompleteRefund проставляет Stripe Refund ID и статус зарезервированному возврату
+	CompleteRefund(ctx context.Context, id int64, stripeRefundID, status string) error
+	// UpsertRefundByStripeID сохраняет возврат, пришедший из webhook
+	UpsertRefundByStripeID(ctx context.Context, r Refund) error
+	// SumActiveRefunds возвращает сумму возвратов, кроме failed/canceled
+	SumActiveRefunds(ctx context.Context, stripePIID string) (int64, error)
+	// ListRefundsByPaymentIntent возвращает возвраты по PaymentIntent
+	ListRefundsByPaymentIntent(ctx context.Context, stripePIID string) ([]Refund, error)
+}
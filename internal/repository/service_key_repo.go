@@ -0,0 +1,45 @@
+// internal/repository/service_key_repo.go
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// ServiceKey описывает ключ внутреннего сервиса (таблица service_api_keys).
+// Хранится только хэш ключа.
+type ServiceKey struct {
+	ID         int64
+	Name       string
+	KeyPrefix  string
+	KeyHash    string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// ServiceAuditEntry — запись журнала вызовов по ключу
+type ServiceAuditEntry struct {
+	ID         int64     `db:"id"`
+	KeyID      int64     `db:"key_id"`
+	Method     string    `db:"method"`
+	Path       string    `db:"path"`
+	OnBehalfOf string    `db:"on_behalf_of"`
+	StatusCode int       `db:"status_code"`
+	ClientIP   string    `db:"client_ip"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// ServiceKeyRepo описывает операции над ключами сервисов и журналом вызовов
+type ServiceKeyRepo interface {
+	CreateServiceKey(ctx context.Context, key ServiceKey) (ServiceKey, error)
+	// GetActiveServiceKeyByHash ищет неотозванный ключ; sql.ErrNoRows, если такого нет
+	GetActiveServiceKeyByHash(ctx context.Context, keyHash string) (ServiceKey, error)
+	ListServiceKeys(ctx context.Context) ([]ServiceKey, error)
+	// RevokeServiceKey отзывает ключ; sql.ErrNoRows, если ключа нет
+	RevokeServiceKey(ctx context.Context, id int64) error
+	// InsertServiceAudit пишет вызов в журнал и обновляет last_used_at ключа
+	InsertServiceAudit(ctx context.Context, entry ServiceAuditEntry) error
+	ListServiceAudit(ctx context.Context, keyID int64, limit int) ([]ServiceAuditEntry, error)
+}
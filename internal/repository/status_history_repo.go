@@ -0,0 +1,38 @@
+// internal/repository/status_history_repo.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Источники смены статуса
+const (
+	StatusSourceAPI        = "api"
+	StatusSourceWebhook    = "webhook"
+	StatusSourceReconciler = "reconciler"
+	StatusSourceScheduler  = "scheduler" // истечение авторизации депозита
+)
+
+// ErrStatusChanged — статус изменился между чтением и записью, переход нужно проверить заново
+var ErrStatusChanged = errors.New("status changed concurrently")
+
+// StatusChange описывает запись из таблицы status_history.
+// FromStatus пустой у записи о создании платежа.
+type StatusChange struct {
+	ID         int64     `db:"id"`
+	StripePIID string    `db:"stripe_pi_id"`
+	Kind       string    `db:"kind"`
+	FromStatus string    `db:"from_status"`
+	ToStatus   string    `db:"to_status"`
+	Source     string    `db:"source"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// StatusHistoryRepo описывает чтение истории статусов.
+// Записи добавляются самим хранилищем при смене статуса.
+type StatusHistoryRepo interface {
+	// ListStatusHistory возвращает переходы по PaymentIntent в хронологическом порядке
+	ListStatusHistory(ctx context.Context, stripePIID, kind string) ([]StatusChange, error)
+}
@@ -0,0 +1,36 @@
+// internal/repository/stripe_event_repo.go
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// StripeEvent описывает запись из таблицы stripe_events
+type StripeEvent struct {
+	ID            string     `db:"id"`
+	Type          string     `db:"type"`
+	Payload       []byte     `db:"payload"`
+	ReceivedAt    time.Time  `db:"received_at"`
+	ProcessedAt   *time.Time `db:"processed_at"`
+	Error         *string    `db:"error"`
+	Attempts      int        `db:"attempts"`
+	NextAttemptAt *time.Time `db:"next_attempt_at"`
+}
+
+// StripeEventRepo описывает операции над журналом событий Stripe
+type StripeEventRepo interface {
+	// SaveStripeEvent сохраняет событие и сразу занимает его на lease для обработки.
+	// inserted == false — событие с таким ID уже было получено.
+	SaveStripeEvent(ctx context.Context, e StripeEvent, lease time.Duration) (inserted bool, err error)
+	// GetStripeEvent возвращает событие по ID
+	GetStripeEvent(ctx context.Context, id string) (StripeEvent, error)
+	// ListStripeEvents возвращает последние события; failedOnly — только необработанные с ошибкой
+	ListStripeEvents(ctx context.Context, failedOnly bool, limit int) ([]StripeEvent, error)
+	// ClaimDueStripeEvents занимает на lease необработанные события, чей срок повтора наступил
+	ClaimDueStripeEvents(ctx context.Context, limit int, lease time.Duration) ([]StripeEvent, error)
+	// MarkStripeEventProcessed отмечает событие успешно обработанным
+	MarkStripeEventProcessed(ctx context.Context, id string) error
+	// MarkStripeEventFailed сохраняет ошибку; nextAttemptAt == nil — больше не повторять
+	MarkStripeEventFailed(ctx context.Context, id, errMsg string, nextAttemptAt *time.Time) error
+}
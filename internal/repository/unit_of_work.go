@@ -0,0 +1,12 @@
+// internal/repository/unit_of_work.go
+package repository
+
+import "context"
+
+// UnitOfWork объединяет вызовы репозиториев в одну транзакцию БД.
+// Все репозитории, вызванные с ctx, переданным в fn, работают внутри неё:
+// данные, история статусов и outbox фиксируются вместе или не фиксируются вовсе.
+// Вложенный WithTx присоединяется к уже открытой транзакции.
+type UnitOfWork interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
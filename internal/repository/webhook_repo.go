@@ -0,0 +1,79 @@
+// internal/repository/webhook_repo.go
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Статусы доставки исходящего webhook
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookSubscription описывает запись из таблицы webhook_subscriptions.
+// Пустой EventTypes — подписка на все события.
+type WebhookSubscription struct {
+	ID          int64
+	URL         string
+	Secret      string
+	EventTypes  []string
+	Description string
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// WebhookDelivery описывает запись журнала доставок
+type WebhookDelivery struct {
+	ID             int64      `db:"id"`
+	SubscriptionID int64      `db:"subscription_id"`
+	EventID        int64      `db:"event_id"`
+	EventType      string     `db:"event_type"`
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at"`
+	LastStatusCode *int       `db:"last_status_code"`
+	LastError      *string    `db:"last_error"`
+	CreatedAt      time.Time  `db:"created_at"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+}
+
+// WebhookDispatch — занятая для отправки доставка вместе с адресом и событием
+type WebhookDispatch struct {
+	DeliveryID    int64     `db:"delivery_id"`
+	Attempts      int       `db:"attempts"`
+	URL           string    `db:"url"`
+	Secret        string    `db:"secret"`
+	EventID       int64     `db:"event_id"`
+	EventType     string    `db:"event_type"`
+	AggregateType string    `db:"aggregate_type"`
+	AggregateID   string    `db:"aggregate_id"`
+	Payload       []byte    `db:"payload"`
+	OccurredAt    time.Time `db:"occurred_at"`
+}
+
+// WebhookRepo описывает операции над подписками и доставками.
+// Доставки создаёт хранилище вместе с событием outbox для всех активных подписок.
+type WebhookRepo interface {
+	CreateWebhookSubscription(ctx context.Context, sub WebhookSubscription) (WebhookSubscription, error)
+	GetWebhookSubscription(ctx context.Context, id int64) (WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error)
+	// UpdateWebhookSubscription сохраняет url, secret, event_types, description и active
+	UpdateWebhookSubscription(ctx context.Context, sub WebhookSubscription) (WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id int64) error
+
+	// ListWebhookDeliveries возвращает последние доставки подписки
+	ListWebhookDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]WebhookDelivery, error)
+	// ClaimWebhookDeliveries занимает на lease доставки, чей срок наступил (только активные подписки)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDispatch, error)
+	// MarkWebhookDelivered отмечает доставку успешной
+	MarkWebhookDelivered(ctx context.Context, id int64, statusCode int) error
+	// MarkWebhookDeliveryFailed сохраняет ошибку; nextAttemptAt == nil — больше не повторять.
+	// statusCode = 0 — ответа не было
+	MarkWebhookDeliveryFailed(ctx context.Context, id int64, statusCode int, errMsg string, nextAttemptAt *time.Time) error
+	// RetryWebhookDelivery ставит доставку в очередь заново со сброшенным счётчиком попыток
+	RetryWebhookDelivery(ctx context.Context, id int64) error
+}
@@ -1,51 +1,127 @@
 package routes
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"Payment-service/internal/bookingclient"
+	"Payment-service/internal/config"
+	"Payment-service/internal/fakegateway"
+	"Payment-service/internal/gateway"
 	"Payment-service/internal/handler"
+	"Payment-service/internal/jwks"
+	"Payment-service/internal/listingclient"
 	"Payment-service/internal/middleware"
+	"Payment-service/internal/publisher"
 	"Payment-service/internal/service"
 	"Payment-service/internal/storage"
 	"Payment-service/internal/stripeadapter"
+	"Payment-service/internal/tracing"
 	"Payment-service/internal/userclient"
+	"Payment-service/internal/worker"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-// RegisterAll инициализирует все маршруты и зависимости.
-// Теперь принимает jwtSecret и userServiceURL для middleware и userclient.
-func RegisterAll(
-	r *gin.Engine,
-	db *storage.Store,
-	webhookSecret,
-	stripeAPIKey,
-	jwtSecret,
-	userServiceURL string,
-) {
-	// 1) Stripe client
-	stripeClient := stripeadapter.NewClient(stripeAPIKey)
-
-	// 2) Репозитории
+// RegisterAll инициализирует все маршруты и зависимости
+// и запускает фоновые воркеры, которые работают до отмены ctx.
+func RegisterAll(ctx context.Context, r *gin.Engine, db *storage.Store, cfg *config.Config, logger *slog.Logger) error {
+	// 0) Трассировка, метрики и лог запросов пишутся для всех маршрутов, включая fake-gateway.
+	// Входящий traceparent продолжает трассировку вызывающего сервиса.
+	r.Use(
+		otelgin.Middleware(tracing.ServiceName),
+		middleware.RequestLog(logger),
+		middleware.Metrics(),
+	)
+
+	// 1) Платёжный шлюз: Stripe или in-memory fake для локальной разработки
+	var payGateway gateway.PaymentGateway
+	var connectGateway gateway.ConnectGateway
+	if cfg.PaymentGateway == config.GatewayFake {
+		fakeGW := fakegateway.New(cfg.FakeWebhookURL, cfg.StripeWebhookSecret)
+		fakeH := handler.NewFakeGatewayHandler(fakeGW)
+		r.POST("/fake-gateway/setup-intents/:id/confirm", fakeH.ConfirmSetupIntent)
+		r.POST("/fake-gateway/payment-intents/:id/confirm", fakeH.ConfirmPaymentIntent)
+		r.POST("/fake-gateway/accounts/:id/onboard", fakeH.CompleteOnboarding)
+		payGateway, connectGateway = fakeGW, fakeGW
+	} else {
+		stripeClient := stripeadapter.NewClient(cfg.StripeSecretKey, logger)
+		payGateway, connectGateway = stripeClient, stripeClient
+	}
+
+	// 1.1) Куда публикуются события из outbox
+	pub, err := newPublisher(cfg)
+	if err != nil {
+		return fmt.Errorf("outbox publisher: %w", err)
+	}
+
+	// 1.2) Ключи для проверки токенов
+	authOpts, err := jwtOptions(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("jwt keys: %w", err)
+	}
+
+	// 2) Репозитории; платёжные собирает newPaymentServices
 	custRepo := db // Store реализует repository.CustomerRepo
 	pmRepo := db   // Store реализует repository.PaymentMethodRepo
-	piRepo := db   // Store реализует repository.PaymentIntentRepo
+	evtRepo := db  // Store реализует repository.StripeEventRepo
+	outRepo := db  // Store реализует repository.OutboxRepo
+	whRepo := db   // Store реализует repository.WebhookRepo
+	keyRepo := db  // Store реализует repository.ServiceKeyRepo
 
-	// 3) User-client
-	userClient := userclient.New(userServiceURL)
+	// 3) Клиенты соседних сервисов
+	userClient := userclient.New(cfg.UserServiceURL, userclient.Options{CacheTTL: cfg.UserCacheTTL})
+	var listingClient *listingclient.Client
+	if cfg.ListingServiceURL != "" {
+		listingClient = listingclient.New(cfg.ListingServiceURL)
+	}
 
 	// 4) Сервисы
-	custSvc := service.NewCustomerService(custRepo, stripeClient, userClient)
-	pmSvc := service.NewPaymentMethodService(pmRepo, stripeClient)
-	paySvc := service.NewPaymentService(piRepo, stripeClient)
+	custSvc := service.NewCustomerService(custRepo, db, payGateway, userClient, logger)
+	pmSvc := service.NewPaymentMethodService(pmRepo, payGateway, logger)
+	var hosts service.HostResolver
+	if listingClient != nil {
+		hosts = listingClient
+	}
+	if cfg.BookingServiceURL == "" {
+		logger.Warn("BOOKING_SERVICE_URL is not set, listing_id of new payments and deposits is not checked against the booking")
+	}
+	core := newPaymentServices(db, cfg, payGateway, connectGateway, hosts, logger)
+	ledgerSvc, connectSvc, paySvc, refSvc, depSvc := core.ledger, core.connect, core.payments, core.refunds, core.deposits
+	evtSvc := service.NewStripeEventService(evtRepo, db, pmSvc, paySvc, depSvc, refSvc, connectSvc, logger)
+	outSvc := service.NewOutboxService(outRepo, pub, logger)
+	whSvc := service.NewWebhookService(whRepo, logger)
+	keySvc := service.NewServiceKeyService(keyRepo)
 
 	// 5) Хендлеры
-	custH := handler.NewCustomerHandler(custSvc, userClient)
-	pmH := handler.NewPaymentMethodHandler(pmSvc, custSvc, userClient)
-	payH := handler.NewPaymentHandler(paySvc)
-	whH := handler.NewWebhookHandler(webhookSecret, pmSvc, paySvc)
+	access := handler.NewAccessChecker(paySvc, depSvc, listingClient)
+	custH := handler.NewCustomerHandler(custSvc)
+	pmH := handler.NewPaymentMethodHandler(pmSvc, custSvc)
+	payH := handler.NewPaymentHandler(paySvc, custSvc, access)
+	refH := handler.NewRefundHandler(refSvc, access)
+	depH := handler.NewDepositHandler(depSvc, custSvc, refSvc, access)
+	whH := handler.NewWebhookHandler(cfg.StripeWebhookSecret, evtSvc, logger)
+	adminH := handler.NewAdminHandler(evtSvc)
+	subH := handler.NewSubscriptionHandler(whSvc)
+	keyH := handler.NewServiceKeyHandler(keySvc)
+	ledgerH := handler.NewLedgerHandler(ledgerSvc)
+	hostH := handler.NewHostHandler(connectSvc)
+	reconH := handler.NewReconciliationHandler(core.reconcile)
+	holdH := handler.NewDepositHoldPolicyHandler(core.holds)
 
 	// 6) Группа с JWT-мидлвэром
 	api := r.Group("/api/v1/pay")
-	api.Use(middleware.JWTAuth(jwtSecret))
+	api.Use(
+		middleware.JWTAuth(authOpts),
+		middleware.ResolvePrincipal(userClient, cfg.AdminEmails),
+		middleware.Idempotency(db),
+	)
 	{
 		api.POST("/customers", custH.CreateCustomer)
 		api.POST("/setup-intents", pmH.CreateSetupIntent)
@@ -53,8 +129,248 @@ func RegisterAll(
 		api.POST("/payment-intents", payH.CreatePaymentIntent)
 		api.POST("/payment-intents/capture", payH.CapturePayment)
 		api.POST("/payment-intents/cancel", payH.CancelPayment)
+		api.POST("/payment-intents/refund", refH.RefundPayment)
+		api.GET("/payment-intents/:id/refunds", refH.ListPaymentRefunds)
+		api.GET("/payment-intents/:id/history", payH.GetPaymentHistory)
+
+		api.POST("/deposits", depH.CreateDeposit)
+		api.GET("/deposits", depH.ListDepositsByBooking)
+		api.GET("/deposits/:id", depH.GetDeposit)
+		api.POST("/deposits/capture", depH.CaptureDeposit)
+		api.POST("/deposits/refund", depH.RefundDeposit)
+		api.GET("/deposits/:id/refunds", depH.ListDepositRefunds)
+		api.GET("/deposits/:id/history", depH.GetDepositHistory)
+
+		api.POST("/host/account/onboarding", hostH.Onboard)
+		api.GET("/host/account", hostH.GetAccount)
+		api.GET("/host/balance", hostH.GetBalance)
+		api.GET("/host/transfers", hostH.ListTransfers)
+		api.GET("/host/payouts", hostH.ListPayouts)
+		api.GET("/me/deposits", depH.ListMyDeposits)
+	}
+
+	// 7) Админские операции
+	admin := api.Group("/admin")
+	admin.Use(middleware.RequireAdmin())
+	{
+		admin.GET("/stripe-events", adminH.ListStripeEvents)
+		admin.POST("/stripe-events/:id/replay", adminH.ReplayStripeEvent)
+
+		admin.POST("/webhooks", subH.CreateSubscription)
+		admin.GET("/webhooks", subH.ListSubscriptions)
+		admin.GET("/webhooks/:id", subH.GetSubscription)
+		admin.PATCH("/webhooks/:id", subH.UpdateSubscription)
+		admin.DELETE("/webhooks/:id", subH.DeleteSubscription)
+		admin.POST("/webhooks/:id/rotate-secret", subH.RotateSecret)
+		admin.GET("/webhooks/:id/deliveries", subH.ListDeliveries)
+		admin.POST("/webhook-deliveries/:id/retry", subH.RetryDelivery)
+
+		admin.POST("/service-keys", keyH.CreateServiceKey)
+		admin.GET("/service-keys", keyH.ListServiceKeys)
+		admin.DELETE("/service-keys/:id", keyH.RevokeServiceKey)
+		admin.GET("/service-keys/:id/audit", keyH.ListServiceAudit)
+
+		admin.GET("/ledger/accounts", ledgerH.ListAccounts)
+		admin.GET("/ledger/accounts/:id", ledgerH.GetAccount)
+		admin.GET("/ledger/accounts/:id/entries", ledgerH.ListAccountEntries)
+		admin.GET("/ledger/entries", ledgerH.ListEntries)
+
+		admin.GET("/reconciliation/reports", reconH.ListReports)
+		admin.GET("/reconciliation/reports/:id", reconH.GetReport)
+
+		admin.GET("/deposit-hold-policies/:scope/:id", holdH.GetPolicy)
+		admin.PUT("/deposit-hold-policies/:scope/:id", holdH.SetPolicy)
+		admin.DELETE("/deposit-hold-policies/:scope/:id", holdH.DeletePolicy)
+	}
+
+	// 7.1) Внутренние сервисы: ключ из X-Api-Key вместо пользовательского JWT
+	internal := r.Group("/internal/v1/pay")
+	internal.Use(
+		middleware.ServiceAuth(keySvc),
+		middleware.ResolvePrincipal(userClient, cfg.AdminEmails),
+		middleware.Idempotency(db),
+	)
+	{
+		paymentsRead := middleware.RequireScope(service.ScopePaymentsRead)
+		paymentsWrite := middleware.RequireScope(service.ScopePaymentsWrite)
+		refundsWrite := middleware.RequireScope(service.ScopeRefundsWrite)
+		depositsRead := middleware.RequireScope(service.ScopeDepositsRead)
+		depositsWrite := middleware.RequireScope(service.ScopeDepositsWrite)
+
+		internal.POST("/payment-intents", paymentsWrite, payH.CreatePaymentIntent)
+		internal.POST("/payment-intents/capture", paymentsWrite, payH.CapturePayment)
+		internal.POST("/payment-intents/cancel", paymentsWrite, payH.CancelPayment)
+		internal.POST("/payment-intents/refund", refundsWrite, refH.RefundPayment)
+		internal.GET("/payment-intents/:id/refunds", paymentsRead, refH.ListPaymentRefunds)
+		internal.GET("/payment-intents/:id/history", paymentsRead, payH.GetPaymentHistory)
+
+		internal.GET("/deposits/:id", depositsRead, depH.GetDeposit)
+		internal.POST("/deposits/capture", depositsWrite, depH.CaptureDeposit)
+		internal.POST("/deposits/refund", depositsWrite, depH.RefundDeposit)
+		internal.GET("/deposits/:id/refunds", depositsRead, depH.ListDepositRefunds)
+		internal.GET("/deposits/:id/history", depositsRead, depH.GetDepositHistory)
+
+		internal.GET("/deposit-hold-policies/:scope/:id", depositsRead, holdH.GetPolicy)
+		internal.PUT("/deposit-hold-policies/:scope/:id", depositsWrite, holdH.SetPolicy)
+		internal.DELETE("/deposit-hold-policies/:scope/:id", depositsWrite, holdH.DeletePolicy)
 	}
 
 	// Webhook
 	r.POST("/stripe/webhook", whH.HandleWebhook)
+	// События подключённых аккаунтов Stripe подписывает секретом Connect-endpoint'а
+	if cfg.StripeConnectWebhookSecret != "" {
+		connectWhH := handler.NewWebhookHandler(cfg.StripeConnectWebhookSecret, evtSvc, logger)
+		r.POST("/stripe/connect/webhook", connectWhH.HandleWebhook)
+	}
+
+	// Метрики Prometheus: статистика из базы и пула соединений считается при scrape.
+	// На публичном роутере их нет, main отдаёт их на METRICS_PORT.
+	prometheus.MustRegister(
+		storage.NewStatsCollector(db, cfg.MetricsStatsTTL),
+		collectors.NewDBStatsCollector(db.DB.DB, "payment_service"),
+	)
+
+	// 8) Фоновые воркеры
+	workers := []worker.Worker{
+		worker.NewStripeEventRetrier(evtSvc, cfg.EventRetryInterval),
+		worker.NewOutboxRelay(outSvc, cfg.OutboxRelayInterval),
+		worker.NewWebhookDispatcher(whSvc, cfg.WebhookDispatchInterval),
+		worker.NewHostTransferWorker(connectSvc, cfg.HostTransferInterval),
+		worker.NewHoldExpiryWorker(core.holds, cfg.HoldExpiryInterval),
+	}
+	// Как и подкоманда reconcile, сверка по расписанию работает только со Stripe:
+	// после рестарта fake-шлюз пуст, и все платежи оказались бы «missing remotely»
+	if cfg.PaymentGateway == config.GatewayStripe {
+		workers = append(workers, worker.NewReconciler(core.reconcile, cfg.ReconcileInterval, cfg.ReconcileWindow))
+	} else {
+		logger.Info("scheduled reconciliation is disabled", "payment_gateway", cfg.PaymentGateway)
+	}
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w worker.Worker) {
+			defer wg.Done()
+			w.Run(ctx)
+		}(w)
+	}
+	// Publisher закрываем, когда relay гарантированно остановлен
+	go func() {
+		wg.Wait()
+		if err := pub.Close(); err != nil {
+			logger.Error("close outbox publisher", "error", err)
+		}
+	}()
+	return nil
+}
+
+// paymentServices — сервисы, которые меняют статусы платежей и депозитов.
+// Их собирают и HTTP-сервер, и подкоманда reconcile.
+type paymentServices struct {
+	ledger    service.LedgerService
+	connect   service.ConnectService
+	payments  service.PaymentService
+	refunds   service.RefundService
+	deposits  service.DepositService
+	reconcile service.ReconcileService
+	holds     service.HoldExpiryService
+}
+
+// newPaymentServices собирает paymentServices. hosts может быть nil.
+func newPaymentServices(
+	db *storage.Store,
+	cfg *config.Config,
+	payGateway gateway.PaymentGateway,
+	connectGateway gateway.ConnectGateway,
+	hosts service.HostResolver,
+	logger *slog.Logger,
+) paymentServices {
+	var bookings service.BookingResolver
+	if cfg.BookingServiceURL != "" {
+		bookings = bookingclient.New(cfg.BookingServiceURL)
+	}
+
+	piRepo := db     // Store реализует repository.PaymentIntentRepo
+	depRepo := db    // Store реализует repository.DepositRepo
+	refRepo := db    // Store реализует repository.RefundRepo
+	histRepo := db   // Store реализует repository.StatusHistoryRepo
+	ledgerRepo := db // Store реализует repository.LedgerRepo
+	hostRepo := db   // Store реализует repository.HostAccountRepo и HostTransferRepo
+	reconRepo := db  // Store реализует repository.ReconciliationRepo
+	policyRepo := db // Store реализует repository.DepositHoldPolicyRepo
+
+	ledgerSvc := service.NewLedgerService(ledgerRepo, cfg.PlatformFeeBPS, logger)
+	connectSvc := service.NewConnectService(hostRepo, hostRepo, db, ledgerSvc, connectGateway, service.ConnectOptions{
+		RefreshURL: cfg.ConnectRefreshURL,
+		ReturnURL:  cfg.ConnectReturnURL,
+	}, logger)
+	paySvc := service.NewPaymentService(piRepo, histRepo, db, ledgerSvc, connectSvc, hosts, bookings, payGateway, logger)
+	refSvc := service.NewRefundService(refRepo, piRepo, depRepo, db, ledgerSvc, connectSvc, payGateway, logger)
+	depSvc := service.NewDepositService(depRepo, histRepo, db, ledgerSvc, connectSvc, hosts, bookings, payGateway, refSvc, cfg.DepositHoldTTL, logger)
+	return paymentServices{
+		ledger:    ledgerSvc,
+		connect:   connectSvc,
+		payments:  paySvc,
+		refunds:   refSvc,
+		deposits:  depSvc,
+		reconcile: service.NewReconcileService(reconRepo, paySvc, depSvc, payGateway, logger),
+		holds: service.NewHoldExpiryService(depRepo, policyRepo, depSvc, service.HoldExpiryOptions{
+			Notice:        cfg.DepositHoldNotice,
+			ActionLead:    cfg.DepositHoldActionLead,
+			DefaultAction: cfg.DepositExpiryAction,
+		}, logger),
+	}
+}
+
+// NewReconcileService собирает сервис сверки для подкоманды reconcile.
+// Fake-шлюз хранит платежи в памяти сервера, поэтому сверка работает только со Stripe.
+func NewReconcileService(db *storage.Store, cfg *config.Config, logger *slog.Logger) (service.ReconcileService, error) {
+	if cfg.PaymentGateway == config.GatewayFake {
+		return nil, fmt.Errorf("reconcile requires PAYMENT_GATEWAY=%s", config.GatewayStripe)
+	}
+	stripeClient := stripeadapter.NewClient(cfg.StripeSecretKey, logger)
+	var hosts service.HostResolver
+	if cfg.ListingServiceURL != "" {
+		hosts = listingclient.New(cfg.ListingServiceURL)
+	}
+	return newPaymentServices(db, cfg, stripeClient, stripeClient, hosts, logger).reconcile, nil
+}
+
+// jwtOptions собирает настройки JWTAuth; JWKS загружается сразу, чтобы
+// ошибка в источнике ключей была видна при старте.
+func jwtOptions(ctx context.Context, cfg *config.Config) (middleware.JWTOptions, error) {
+	opts := middleware.JWTOptions{
+		Secret:     cfg.JWTSecret,
+		Algorithms: cfg.JWTAlgorithms,
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		Leeway:     cfg.JWTLeeway,
+
+		UserIDClaim: cfg.JWTUserIDClaim,
+	}
+	switch {
+	case cfg.JWKSURL != "":
+		opts.Keys = jwks.NewRemote(cfg.JWKSURL, cfg.JWKSRefresh)
+	case cfg.JWKSFile != "":
+		opts.Keys = jwks.NewFile(cfg.JWKSFile, cfg.JWKSRefresh)
+	default:
+		return opts, nil
+	}
+	if err := opts.Keys.Load(ctx); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// newPublisher выбирает реализацию публикации по OUTBOX_PUBLISHER.
+func newPublisher(cfg *config.Config) (publisher.Publisher, error) {
+	switch cfg.OutboxPublisher {
+	case config.PublisherHTTP:
+		return publisher.NewHTTP(cfg.OutboxHTTPURL, 10*time.Second), nil
+	case config.PublisherNATS:
+		return publisher.NewNATS(cfg.NATSURL, cfg.NATSSubjectPrefix)
+	case config.PublisherKafka:
+		return publisher.NewKafka(cfg.KafkaBrokers, cfg.KafkaTopic), nil
+	default:
+		return publisher.NewLog(), nil
+	}
 }
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+)
+
+const (
+	// hostTransferLease keeps other workers away from a transfer being made.
+	hostTransferLease = time.Minute
+	// hostTransferRetryBase is the delay before the first retry; it doubles each attempt.
+	hostTransferRetryBase = time.Minute
+	// hostTransferRetryMaxDelay caps the backoff.
+	hostTransferRetryMaxDelay = 6 * time.Hour
+	// hostTransferMaxAttempts is how many times a transfer is tried before it is marked failed.
+	hostTransferMaxAttempts = 10
+)
+
+// TransferScheduler keeps the host's transfer in line with their share of a
+// payment or deposit. It is called in the transaction that books a capture or
+// a refund, after the ledger.
+type TransferScheduler interface {
+	ScheduleTransfer(ctx context.Context, subj LedgerSubject) error
+}
+
+// HostBalance is what a host has earned and what sits on their Stripe account.
+type HostBalance struct {
+	// Owed is captured money due to the host that has not been transferred yet.
+	Owed []gateway.Money
+	// Account is the balance of the connected account; nil without an account.
+	Account *gateway.AccountBalance
+}
+
+// ConnectService onboards hosts to Stripe Connect and pays them their share
+// of captured payments and deposits with separate transfers.
+type ConnectService interface {
+	TransferScheduler
+	// Onboard creates the host's connected account on first use and returns
+	// a fresh onboarding link.
+	Onboard(ctx context.Context, hostID, email string) (repository.HostAccount, gateway.AccountLink, error)
+	// Account returns the host's account, refreshing an unfinished onboarding
+	// from the gateway. ErrNotFound if the host has not started onboarding.
+	Account(ctx context.Context, hostID string) (repository.HostAccount, error)
+	// SyncAccount stores the onboarding state reported by an account.updated webhook.
+	SyncAccount(ctx context.Context, a gateway.ConnectedAccount) error
+	// TransferDue makes up to limit queued transfers and returns how many were claimed.
+	TransferDue(ctx context.Context, limit int) (int, error)
+	// ReverseDue makes up to limit queued transfer reversals and returns how many were claimed.
+	ReverseDue(ctx context.Context, limit int) (int, error)
+	// Balance returns what the host is owed and their connected account balance.
+	Balance(ctx context.Context, hostID string) (HostBalance, error)
+	// Transfers returns transfers to the host, newest first.
+	Transfers(ctx context.Context, hostID string, limit int) ([]repository.HostTransfer, error)
+	// Payouts returns payouts from the host's account to their bank, newest first.
+	Payouts(ctx context.Context, hostID string, limit int) ([]gateway.Payout, error)
+}
+
+// ConnectOptions configures ConnectService.
+type ConnectOptions struct {
+	// RefreshURL and ReturnURL are where Stripe sends the host from onboarding.
+	// Onboarding returns ErrNotConfigured without them.
+	RefreshURL string
+	ReturnURL  string
+}
+
+// connectService is a concrete implementation of ConnectService.
+type connectService struct {
+	accounts  repository.HostAccountRepo
+	transfers repository.HostTransferRepo
+	uow       repository.UnitOfWork
+	ledger    LedgerService
+	connect   gateway.ConnectGateway
+	opts      ConnectOptions
+	log       *slog.Logger
+}
+
+// NewConnectService constructs a ConnectService.
+func NewConnectService(
+	accounts repository.HostAccountRepo,
+	transfers repository.HostTransferRepo,
+	uow repository.UnitOfWork,
+	ledger LedgerService,
+	connect gateway.ConnectGateway,
+	opts ConnectOptions,
+	logger *slog.Logger,
+) ConnectService {
+	return &connectService{
+		accounts:  accounts,
+		transfers: transfers,
+		uow:       uow,
+		ledger:    ledger,
+		connect:   connect,
+		opts:      opts,
+		log:       logger,
+	}
+}
+
+func (s *connectService) Onboard(ctx context.Context, hostID, email string) (repository.HostAccount, gateway.AccountLink, error) {
+	if s.opts.RefreshURL == "" || s.opts.ReturnURL == "" {
+		return repository.HostAccount{}, gateway.AccountLink{}, fmt.Errorf("%w: host onboarding URLs", ErrNotConfigured)
+	}
+	account, err := s.accounts.GetHostAccount(ctx, hostID)
+	if errors.Is(err, sql.ErrNoRows) {
+		account, err = s.createAccount(ctx, hostID, email)
+	}
+	if err != nil {
+		return repository.HostAccount{}, gateway.AccountLink{}, err
+	}
+	link, err := s.connect.CreateAccountLink(ctx, account.StripeAccountID, s.opts.RefreshURL, s.opts.ReturnURL)
+	if err != nil {
+		return repository.HostAccount{}, gateway.AccountLink{}, err
+	}
+	return account, *link, nil
+}
+
+func (s *connectService) createAccount(ctx context.Context, hostID, email string) (repository.HostAccount, error) {
+	a, err := s.connect.CreateConnectedAccount(ctx, hostID, email)
+	if err != nil {
+		return repository.HostAccount{}, err
+	}
+	// A concurrent request may have stored another account first; that one wins
+	account, err := s.accounts.CreateHostAccount(ctx, toHostAccount(hostID, *a))
+	if err != nil {
+		return repository.HostAccount{}, err
+	}
+	if account.StripeAccountID != a.ID {
+		s.log.WarnContext(ctx, "host already has a connected account",
+			"host_id", hostID, "stripe_account_id", account.StripeAccountID, "unused_stripe_account_id", a.ID)
+	} else {
+		s.log.InfoContext(ctx, "host connected account created", "host_id", hostID, "stripe_account_id", a.ID)
+	}
+	return account, nil
+}
+
+func (s *connectService) Account(ctx context.Context, hostID string) (repository.HostAccount, error) {
+	account, err := s.accounts.GetHostAccount(ctx, hostID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.HostAccount{}, ErrNotFound
+	}
+	if err != nil || account.PayoutsEnabled {
+		return account, err
+	}
+	// Onboarding is unfinished: the webhook may still be on its way or lost
+	a, err := s.connect.RetrieveConnectedAccount(ctx, account.StripeAccountID)
+	if err != nil {
+		s.log.WarnContext(ctx, "cannot refresh host account", "host_id", hostID, "error", err)
+		return account, nil
+	}
+	return s.accounts.UpdateHostAccountStatus(ctx, toHostAccount(hostID, *a))
+}
+
+func (s *connectService) SyncAccount(ctx context.Context, a gateway.ConnectedAccount) error {
+	account, err := s.accounts.UpdateHostAccountStatus(ctx, toHostAccount(a.HostID, a))
+	if errors.Is(err, sql.ErrNoRows) {
+		s.log.InfoContext(ctx, "ignoring unknown connected account", "stripe_account_id", a.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.log.InfoContext(ctx, "host account updated",
+		"host_id", account.HostID, "stripe_account_id", a.ID,
+		"charges_enabled", a.ChargesEnabled, "payouts_enabled", a.PayoutsEnabled)
+	return nil
+}
+
+// ScheduleTransfer sets the transfer to the host's share booked in the ledger:
+// the captured amount less the platform fee and refunds. A pending transfer is
+// adjusted or canceled; if more has already been transferred, the excess is
+// queued for a transfer reversal.
+func (s *connectService) ScheduleTransfer(ctx context.Context, subj LedgerSubject) error {
+	if subj.HostID == "" {
+		return nil
+	}
+	share, err := s.ledger.HostShare(ctx, subj.PaymentIntentID)
+	if err != nil {
+		return err
+	}
+	t, err := s.transfers.UpsertHostTransfer(ctx, repository.HostTransfer{
+		StripePIID: subj.PaymentIntentID,
+		Kind:       subj.Kind,
+		HostID:     subj.HostID,
+		Amount:     max(share, 0),
+		Currency:   subj.Currency,
+	})
+	if err != nil {
+		return err
+	}
+	return s.reverseExcess(ctx, t)
+}
+
+// reverseExcess queues a reversal of what a paid transfer sent above the
+// host's current share. A share that grew after the transfer (a refund failed
+// after it was reversed) is not paid again automatically.
+func (s *connectService) reverseExcess(ctx context.Context, t repository.HostTransfer) error {
+	if t.Status != repository.HostTransferPaid {
+		return nil
+	}
+	excess := t.PaidAmount - t.ReversedAmount - t.Amount
+	if excess < 0 {
+		s.log.WarnContext(ctx, "host transfer is below the host's share",
+			"host_transfer_id", t.ID, "host_id", t.HostID, "payment_intent_id", t.StripePIID, "missing", -excess)
+	}
+	if excess <= 0 {
+		return nil
+	}
+	if err := s.transfers.EnqueueHostTransferReversal(ctx, t.ID, excess); err != nil {
+		return err
+	}
+	s.log.InfoContext(ctx, "host transfer reversal queued",
+		"host_transfer_id", t.ID, "host_id", t.HostID, "payment_intent_id", t.StripePIID,
+		"amount", excess, "currency", t.Currency)
+	return nil
+}
+
+func (s *connectService) TransferDue(ctx context.Context, limit int) (int, error) {
+	claimed, err := s.transfers.ClaimHostTransfers(ctx, limit, hostTransferLease)
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range claimed {
+		s.transfer(ctx, t)
+	}
+	return len(claimed), nil
+}
+
+// transfer makes one claimed transfer. The idempotency key is derived from the
+// row, so a retry after a lost response does not pay the host twice.
+func (s *connectService) transfer(ctx context.Context, t repository.HostTransfer) {
+	tr, err := s.connect.CreateTransfer(ctx, gateway.TransferParams{
+		AccountID:       t.StripeAccountID,
+		Amount:          t.Amount,
+		Currency:        t.Currency,
+		PaymentIntentID: t.StripePIID,
+		IdempotencyKey:  "host_transfer:" + strconv.FormatInt(t.ID, 10),
+	})
+	if err != nil {
+		final := t.Attempts >= hostTransferMaxAttempts
+		s.log.WarnContext(ctx, "host transfer failed",
+			"host_transfer_id", t.ID, "host_id", t.HostID, "payment_intent_id", t.StripePIID,
+			"attempt", t.Attempts, "final", final, "error", err)
+		next := time.Now().Add(backoff(hostTransferRetryBase, hostTransferRetryMaxDelay, t.Attempts))
+		if markErr := s.transfers.MarkHostTransferFailed(ctx, t.ID, err.Error(), next, final); markErr != nil {
+			s.log.ErrorContext(ctx, "failed to mark host transfer", "host_transfer_id", t.ID, "error", markErr)
+		}
+		return
+	}
+
+	err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+		paid, err := s.transfers.MarkHostTransferPaid(ctx, t.ID, tr.ID, t.Amount)
+		if err != nil {
+			return err
+		}
+		if err := s.ledger.RecordTransfer(ctx, t); err != nil {
+			return err
+		}
+		// A refund may have lowered the share while the transfer was in flight
+		return s.reverseExcess(ctx, paid)
+	})
+	if err != nil {
+		// The transfer is retried after the lease with the same idempotency key
+		s.log.ErrorContext(ctx, "failed to record host transfer",
+			"host_transfer_id", t.ID, "stripe_transfer_id", tr.ID, "error", err)
+		return
+	}
+	s.log.InfoContext(ctx, "host transfer paid",
+		"host_transfer_id", t.ID, "host_id", t.HostID, "payment_intent_id", t.StripePIID,
+		"stripe_transfer_id", tr.ID, "amount", t.Amount, "currency", t.Currency)
+}
+
+func (s *connectService) ReverseDue(ctx context.Context, limit int) (int, error) {
+	claimed, err := s.transfers.ClaimHostTransferReversals(ctx, limit, hostTransferLease)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range claimed {
+		s.reverse(ctx, r)
+	}
+	return len(claimed), nil
+}
+
+// reverse makes one claimed transfer reversal, retried like a transfer.
+func (s *connectService) reverse(ctx context.Context, r repository.HostTransferReversal) {
+	rev, err := s.connect.CreateTransferReversal(ctx, gateway.TransferReversalParams{
+		TransferID:     r.StripeTransferID,
+		Amount:         r.Amount,
+		IdempotencyKey: "host_transfer_reversal:" + strconv.FormatInt(r.ID, 10),
+	})
+	if err != nil {
+		// After the final attempt host_payable shows what the host owes back
+		final := r.Attempts >= hostTransferMaxAttempts
+		s.log.WarnContext(ctx, "host transfer reversal failed",
+			"host_transfer_reversal_id", r.ID, "host_id", r.HostID, "payment_intent_id", r.StripePIID,
+			"attempt", r.Attempts, "final", final, "error", err)
+		next := time.Now().Add(backoff(hostTransferRetryBase, hostTransferRetryMaxDelay, r.Attempts))
+		if markErr := s.transfers.MarkHostTransferReversalFailed(ctx, r.ID, err.Error(), next, final); markErr != nil {
+			s.log.ErrorContext(ctx, "failed to mark host transfer reversal", "host_transfer_reversal_id", r.ID, "error", markErr)
+		}
+		return
+	}
+
+	err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.transfers.MarkHostTransferReversalPaid(ctx, r.ID, rev.ID); err != nil {
+			return err
+		}
+		return s.ledger.RecordTransferReversal(ctx, r)
+	})
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to record host transfer reversal",
+			"host_transfer_reversal_id", r.ID, "stripe_reversal_id", rev.ID, "error", err)
+		return
+	}
+	s.log.InfoContext(ctx, "host transfer reversed",
+		"host_transfer_reversal_id", r.ID, "host_id", r.HostID, "payment_intent_id", r.StripePIID,
+		"stripe_reversal_id", rev.ID, "amount", r.Amount, "currency", r.Currency)
+}
+
+func (s *connectService) Balance(ctx context.Context, hostID string) (HostBalance, error) {
+	accounts, err := s.ledger.Accounts(ctx, repository.LedgerAccountFilter{
+		Type:    repository.LedgerHostPayable,
+		OwnerID: hostID,
+	})
+	if err != nil {
+		return HostBalance{}, err
+	}
+	b := HostBalance{Owed: make([]gateway.Money, 0, len(accounts))}
+	for _, a := range accounts {
+		// host_payable is a liability: credit balance is negative in the ledger
+		b.Owed = append(b.Owed, gateway.Money{Amount: -a.Balance, Currency: a.Currency})
+	}
+
+	account, err := s.accounts.GetHostAccount(ctx, hostID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return b, nil
+	}
+	if err != nil {
+		return HostBalance{}, err
+	}
+	b.Account, err = s.connect.GetAccountBalance(ctx, account.StripeAccountID)
+	if err != nil {
+		return HostBalance{}, err
+	}
+	return b, nil
+}
+
+func (s *connectService) Transfers(ctx context.Context, hostID string, limit int) ([]repository.HostTransfer, error) {
+	return s.transfers.ListHostTransfers(ctx, hostID, limit)
+}
+
+func (s *connectService) Payouts(ctx context.Context, hostID string, limit int) ([]gateway.Payout, error) {
+	account, err := s.accounts.GetHostAccount(ctx, hostID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return []gateway.Payout{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.connect.ListPayouts(ctx, account.StripeAccountID, limit)
+}
+
+func toHostAccount(hostID string, a gateway.ConnectedAccount) repository.HostAccount {
+	return repository.HostAccount{
+		HostID:           hostID,
+		StripeAccountID:  a.ID,
+		ChargesEnabled:   a.ChargesEnabled,
+		PayoutsEnabled:   a.PayoutsEnabled,
+		DetailsSubmitted: a.DetailsSubmitted,
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"Payment-service/internal/fakegateway"
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+)
+
+type connectFixture struct {
+	svc     ConnectService
+	ledger  LedgerService
+	hosts   *memHosts
+	gw      *fakegateway.Gateway
+	account string
+}
+
+// newConnectFixture готовит хоста с подключённым аккаунтом и списанный
+// в Stripe платёж на amount
+func newConnectFixture(t *testing.T, amount int64) (connectFixture, LedgerSubject) {
+	t.Helper()
+	ctx := context.Background()
+	gw := fakegateway.New("", "")
+	customerID, err := gw.CreateCustomer(ctx, "guest@example.com", "user-1")
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	pi, err := gw.CreatePaymentIntent(ctx, gateway.CreatePaymentIntentParams{
+		CustomerID: customerID, Amount: amount, Currency: "usd", BookingID: "booking-1", UserID: "user-1",
+	})
+	if err != nil {
+		t.Fatalf("CreatePaymentIntent: %v", err)
+	}
+	if _, err := gw.ConfirmPaymentIntent(ctx, pi.ID, fakegateway.CardVisa); err != nil {
+		t.Fatalf("ConfirmPaymentIntent: %v", err)
+	}
+	account, err := gw.CreateConnectedAccount(ctx, "host-1", "host@example.com")
+	if err != nil {
+		t.Fatalf("CreateConnectedAccount: %v", err)
+	}
+	enabled, err := gw.CompleteOnboarding(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("CompleteOnboarding: %v", err)
+	}
+
+	hosts := newMemHosts()
+	hosts.accounts["host-1"] = toHostAccount("host-1", *enabled)
+	ledger := NewLedgerService(newMemLedger(), 1000, discardLogger()) // комиссия 10%
+	f := connectFixture{
+		svc:     NewConnectService(hosts, hosts, newMemStore(), ledger, gw, ConnectOptions{}, discardLogger()),
+		ledger:  ledger,
+		hosts:   hosts,
+		gw:      gw,
+		account: account.ID,
+	}
+	subj := LedgerSubject{
+		Kind: repository.RefundKindPayment, PaymentIntentID: pi.ID, UserID: "user-1", HostID: "host-1",
+		Currency: "usd", Amount: amount, Captured: amount,
+	}
+	if err := ledger.RecordStatus(ctx, subj, gateway.StatusSucceeded); err != nil {
+		t.Fatalf("RecordStatus: %v", err)
+	}
+	if err := f.svc.ScheduleTransfer(ctx, subj); err != nil {
+		t.Fatalf("ScheduleTransfer: %v", err)
+	}
+	return f, subj
+}
+
+// refund проводит успешный возврат так же, как RefundService
+func (f connectFixture) refund(t *testing.T, subj LedgerSubject, id string, amount int64) {
+	t.Helper()
+	ctx := context.Background()
+	err := f.ledger.RecordRefund(ctx, subj, repository.Refund{
+		StripeRefundID: id, StripePIID: subj.PaymentIntentID, Amount: amount, Currency: subj.Currency,
+		Status: gateway.RefundStatusSucceeded,
+	})
+	if err != nil {
+		t.Fatalf("RecordRefund %s: %v", id, err)
+	}
+	if err := f.svc.ScheduleTransfer(ctx, subj); err != nil {
+		t.Fatalf("ScheduleTransfer after %s: %v", id, err)
+	}
+}
+
+func TestConnectService_RefundsAdjustAndReverseHostTransfer(t *testing.T) {
+	ctx := context.Background()
+	f, subj := newConnectFixture(t, 10000)
+	if got := f.hosts.transfers[0]; got.Amount != 9000 || got.Status != repository.HostTransferPending {
+		t.Fatalf("transfer after capture = %d %s, want 9000 pending", got.Amount, got.Status)
+	}
+
+	// Возврат до перевода уменьшает ожидающий перевод
+	f.refund(t, subj, "re_1", 2000)
+	if got := f.hosts.transfers[0].Amount; got != 7200 {
+		t.Fatalf("transfer after refund = %d, want 7200", got)
+	}
+	if n, err := f.svc.TransferDue(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("TransferDue = %d, %v; want 1 transfer", n, err)
+	}
+	if got := f.hosts.transfers[0]; got.Status != repository.HostTransferPaid || got.PaidAmount != 7200 {
+		t.Fatalf("transfer = %d %s, want 7200 paid", got.PaidAmount, got.Status)
+	}
+
+	// Возврат после перевода забирает излишек у хоста, повтор не ставит второй возврат
+	f.refund(t, subj, "re_2", 3000)
+	if err := f.svc.ScheduleTransfer(ctx, subj); err != nil {
+		t.Fatalf("repeated ScheduleTransfer: %v", err)
+	}
+	if len(f.hosts.reversals) != 1 || f.hosts.reversals[0].Amount != 2700 {
+		t.Fatalf("reversals = %+v, want one of 2700", f.hosts.reversals)
+	}
+	if n, err := f.svc.ReverseDue(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("ReverseDue = %d, %v; want 1 reversal", n, err)
+	}
+	if got := f.hosts.reversals[0].Status; got != repository.HostTransferPaid {
+		t.Fatalf("reversal status = %s, want paid", got)
+	}
+
+	b, err := f.svc.Balance(ctx, "host-1")
+	if err != nil {
+		t.Fatalf("Balance: %v", err)
+	}
+	if len(b.Owed) != 1 || b.Owed[0].Amount != 0 {
+		t.Errorf("owed = %+v, want nothing left to transfer", b.Owed)
+	}
+	if b.Account == nil || len(b.Account.Available) != 1 || b.Account.Available[0].Amount != 4500 {
+		t.Errorf("account balance = %+v, want 4500 after the reversal", b.Account)
+	}
+}
+
+func TestConnectService_FullRefundCancelsPendingTransfer(t *testing.T) {
+	ctx := context.Background()
+	f, subj := newConnectFixture(t, 10000)
+
+	f.refund(t, subj, "re_1", 10000)
+	if got := f.hosts.transfers[0]; got.Amount != 0 || got.Status != repository.HostTransferCanceled {
+		t.Fatalf("transfer after full refund = %d %s, want 0 canceled", got.Amount, got.Status)
+	}
+	if n, err := f.svc.TransferDue(ctx, 10); err != nil || n != 0 {
+		t.Errorf("TransferDue = %d, %v; want nothing to transfer", n, err)
+	}
+}
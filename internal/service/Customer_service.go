@@ -4,27 +4,34 @@ package service
 import (
 	"Payment-service/internal/userclient"
 	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
 
+	"Payment-service/internal/gateway"
 	"Payment-service/internal/repository"
-	"Payment-service/internal/stripeadapter"
 )
 
 // CustomerService defines business logic around Stripe Customers.
 type CustomerService interface {
 	// EnsureCustomer checks if a Stripe Customer exists for userID; if not, creates it.
 	EnsureCustomer(ctx context.Context, userID, email string) (string, error)
+	// GetCustomer returns the stored Stripe Customer of userID or ErrNotFound.
+	GetCustomer(ctx context.Context, userID string) (string, error)
 }
 
 // customerService is a concrete implementation of CustomerService.
 type customerService struct {
 	repo       repository.CustomerRepo
-	stripe     *stripeadapter.Client
+	uow        repository.UnitOfWork
+	stripe     gateway.PaymentGateway
 	userClient *userclient.Client
+	log        *slog.Logger
 }
 
 // NewCustomerService constructs a CustomerService.
-func NewCustomerService(repo repository.CustomerRepo, client *stripeadapter.Client, userclient *userclient.Client) CustomerService {
-	return &customerService{repo: repo, stripe: client, userClient: userclient}
+func NewCustomerService(repo repository.CustomerRepo, uow repository.UnitOfWork, client gateway.PaymentGateway, userclient *userclient.Client, logger *slog.Logger) CustomerService {
+	return &customerService{repo: repo, uow: uow, stripe: client, userClient: userclient, log: logger}
 }
 
 // EnsureCustomer checks for existing Customer in DB, creates in Stripe if missing.
@@ -41,11 +48,34 @@ func (s *customerService) EnsureCustomer(ctx context.Context, userID, email stri
 		return "", err
 	}
 
-	// 3) Persist mapping in DB
-	if err := s.repo.CreateCustomer(ctx, userID, email, newID); err != nil {
+	// 3) Persist mapping in DB. If a concurrent request stored a customer
+	// first, its ID wins so every caller gets the same one.
+	var stored string
+	err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateCustomer(ctx, userID, email, newID); err != nil {
+			return err
+		}
+		var err error
+		stored, err = s.repo.GetCustomerByUserID(ctx, userID)
+		return err
+	})
+	if err != nil {
 		return "", err
 	}
-	return newID, nil
+	if stored == newID {
+		s.log.InfoContext(ctx, "stripe customer created", "user_id", userID, "stripe_customer_id", newID)
+	}
+	return stored, nil
+}
+
+// GetCustomer looks up the Customer without creating one: callers that only
+// know the user ID have no email to create it with.
+func (s *customerService) GetCustomer(ctx context.Context, userID string) (string, error) {
+	id, err := s.repo.GetCustomerByUserID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return id, err
 }
 
 func (s *customerService) UserClient() *userclient.Client {
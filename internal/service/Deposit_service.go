@@ -1,31 +1,112 @@
 package service
 
 import (
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/paymentstate"
 	"Payment-service/internal/repository"
-	"Payment-service/internal/stripeadapter"
 	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
 )
 
 type DepositService interface {
 	// AuthorizeDeposit ставит hold и сохраняет в deposits
 	AuthorizeDeposit(ctx context.Context, customerID, userID, bookingID, listingID, currency string, amount int64) (clientSecret, depositID string, err error)
-	// CaptureDeposit захватывает hold (списание) и обновляет статус
-	CaptureDeposit(ctx context.Context, depositID string) error
-	// RefundDeposit отменяет hold и обновляет статус
-	RefundDeposit(ctx context.Context, depositID string) error
+	// CaptureDeposit захватывает hold (списание) и обновляет статус.
+	// amountToCapture = 0 — захватить всю сумму; меньшая сумма списывается,
+	// а остаток hold автоматически отпускается
+	CaptureDeposit(ctx context.Context, depositID string, amountToCapture int64, reason string) (repository.Deposit, error)
+	// ReleaseDeposit отменяет незахваченный hold и обновляет статус
+	ReleaseDeposit(ctx context.Context, depositID string) error
+	// RefundDeposit возвращает деньги: незахваченный hold отпускается целиком (refund == nil),
+	// по захваченному депозиту создаётся полный или частичный возврат
+	RefundDeposit(ctx context.Context, depositID string, amount int64, reason string) (*repository.Refund, error)
+	// GetDeposit возвращает депозит по ID или ErrNotFound
+	GetDeposit(ctx context.Context, depositID string) (repository.Deposit, error)
+	// ListByBooking возвращает депозиты брони
+	ListByBooking(ctx context.Context, bookingID string) ([]repository.Deposit, error)
+	// ListByUser возвращает депозиты пользователя
+	ListByUser(ctx context.Context, userID string) ([]repository.Deposit, error)
+	// ApplyGatewayUpdate сохраняет статус, пришедший от шлюза (webhooks, сверка).
+	// Устаревшие переходы, которые запрещает state machine, игнорируются.
+	// ErrNotFound — PaymentIntent не является депозитом
+	ApplyGatewayUpdate(ctx context.Context, pi gateway.PaymentIntent, source string) error
+	// PrepareGatewayUpdate дочитывает из шлюза срок авторизации (capture_before),
+	// если депозиту он нужен, а в pi его нет: webhook не раскрывает latest_charge.
+	// Вызывается до транзакции, в которой выполняется ApplyGatewayUpdate
+	PrepareGatewayUpdate(ctx context.Context, pi gateway.PaymentIntent) gateway.PaymentIntent
+	// History возвращает историю статусов депозита, от старых к новым
+	History(ctx context.Context, depositID string) ([]repository.StatusChange, error)
+	// ExpireHold отмечает депозит с истёкшей авторизацией как expired. Если
+	// Stripe ещё держит hold, он отпускается; если депозит успели списать или
+	// отменить, сохраняется статус из Stripe
+	ExpireHold(ctx context.Context, depositID string) (repository.Deposit, error)
 }
 
 type depositService struct {
-	repo   repository.DepositRepo
-	stripe *stripeadapter.Client
+	repo     repository.DepositRepo
+	history  repository.StatusHistoryRepo
+	uow      repository.UnitOfWork
+	ledger   LedgerService
+	payouts  TransferScheduler
+	hosts    HostResolver
+	bookings BookingResolver
+	stripe   gateway.PaymentGateway
+	refunds  RefundService
+	holdTTL  time.Duration
+	log      *slog.Logger
 }
 
-func NewDepositService(repo repository.DepositRepo, stripe *stripeadapter.Client) DepositService {
-	return &depositService{repo: repo, stripe: stripe}
+// NewDepositService конструктор. hosts может быть nil — тогда хост
+// объявления неизвестен и долг перед ним учитывается на общем счёте.
+// bookings может быть nil — тогда listing_id не сверяется с бронью.
+// holdTTL — сколько действует авторизация с создания PaymentIntent, если
+// Stripe не сообщил capture_before.
+func NewDepositService(
+	repo repository.DepositRepo,
+	history repository.StatusHistoryRepo,
+	uow repository.UnitOfWork,
+	ledger LedgerService,
+	payouts TransferScheduler,
+	hosts HostResolver,
+	bookings BookingResolver,
+	stripe gateway.PaymentGateway,
+	refunds RefundService,
+	holdTTL time.Duration,
+	logger *slog.Logger,
+) DepositService {
+	return &depositService{
+		repo: repo, history: history, uow: uow, ledger: ledger, payouts: payouts, hosts: hosts,
+		bookings: bookings, stripe: stripe, refunds: refunds, holdTTL: holdTTL, log: logger,
+	}
+}
+
+// depositCapture — сумма и причина списания, сохраняемые вместе со статусом
+type depositCapture struct {
+	amount int64
+	reason string
 }
 
 func (s *depositService) AuthorizeDeposit(ctx context.Context, customerID, userID, bookingID, listingID, currency string, amount int64) (string, string, error) {
-	pi, err := s.stripe.CreatePaymentIntent(ctx, customerID, amount, currency, bookingID, userID, listingID)
+	listingID, err := resolveListing(ctx, s.bookings, bookingID, userID, listingID)
+	if err != nil {
+		return "", "", err
+	}
+	hostID, err := resolveHost(ctx, s.hosts, listingID)
+	if err != nil {
+		return "", "", err
+	}
+	pi, err := s.stripe.CreatePaymentIntent(ctx, gateway.CreatePaymentIntentParams{
+		CustomerID:    customerID,
+		Amount:        amount,
+		Currency:      currency,
+		BookingID:     bookingID,
+		UserID:        userID,
+		ListingID:     listingID,
+		ManualCapture: true,
+	})
 	if err != nil {
 		return "", "", err
 	}
@@ -34,28 +115,313 @@ func (s *depositService) AuthorizeDeposit(ctx context.Context, customerID, userI
 		BookingID:  bookingID,
 		ListingID:  listingID,
 		UserID:     userID,
+		HostID:     hostID,
 		Amount:     amount,
 		Currency:   currency,
-		Status:     string(pi.Status),
+		Status:     pi.Status,
 	}
-	if err := s.repo.CreateDeposit(ctx, d); err != nil {
+	if d.Status == gateway.StatusRequiresCapture {
+		d.HoldExpiresAt = s.holdExpiry(s.withCaptureBefore(ctx, *pi))
+	}
+	err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateDeposit(ctx, d); err != nil {
+			return err
+		}
+		return s.record(ctx, depositSubject(d), d.Status)
+	})
+	if err != nil {
 		return "", "", err
 	}
+	s.log.InfoContext(ctx, "deposit authorized",
+		"payment_intent_id", pi.ID, "booking_id", bookingID, "listing_id", listingID, "user_id", userID,
+		"amount", amount, "currency", currency, "status", pi.Status)
 	return pi.ClientSecret, pi.ID, nil
 }
 
-func (s *depositService) CaptureDeposit(ctx context.Context, depositID string) error {
-	pi, err := s.stripe.CapturePaymentIntent(ctx, depositID)
+func (s *depositService) CaptureDeposit(ctx context.Context, depositID string, amountToCapture int64, reason string) (repository.Deposit, error) {
+	d, err := s.GetDeposit(ctx, depositID)
 	if err != nil {
-		return err
+		return repository.Deposit{}, err
+	}
+	if d.Status != gateway.StatusRequiresCapture && !paymentstate.IsFinal(d.Status) {
+		// Подтверждение карты приходит webhook'ом; если он ещё не дошёл,
+		// статус берётся у шлюза, чтобы hold можно было списать сразу
+		if d, err = s.refresh(ctx, depositID); err != nil {
+			return repository.Deposit{}, err
+		}
+	}
+	if d.Status != gateway.StatusRequiresCapture {
+		return repository.Deposit{}, ErrInvalidState
 	}
-	return s.repo.UpdateDepositStatus(ctx, pi.ID, string(pi.Status))
+	if amountToCapture < 0 || amountToCapture > d.Amount {
+		return repository.Deposit{}, ErrInvalidAmount
+	}
+
+	pi, err := s.stripe.CapturePaymentIntent(ctx, depositID, amountToCapture)
+	if err != nil {
+		return repository.Deposit{}, err
+	}
+	captured := pi.AmountReceived
+	if captured == 0 && pi.Status == gateway.StatusSucceeded {
+		captured = d.Amount
+		if amountToCapture > 0 {
+			captured = amountToCapture
+		}
+	}
+	return s.transition(ctx, *pi, pi.Status, repository.StatusSourceAPI, &depositCapture{amount: captured, reason: reason})
 }
 
-func (s *depositService) RefundDeposit(ctx context.Context, depositID string) error {
+func (s *depositService) ReleaseDeposit(ctx context.Context, depositID string) error {
+	d, err := s.GetDeposit(ctx, depositID)
+	if err != nil {
+		return err
+	}
+	if !paymentstate.CanTransition(d.Status, gateway.StatusCanceled) {
+		return invalidTransition(d.Status, gateway.StatusCanceled)
+	}
 	pi, err := s.stripe.CancelPaymentIntent(ctx, depositID)
 	if err != nil {
 		return err
 	}
-	return s.repo.UpdateDepositStatus(ctx, pi.ID, string(pi.Status))
+	_, err = s.transition(ctx, *pi, pi.Status, repository.StatusSourceAPI, nil)
+	return err
+}
+
+func (s *depositService) RefundDeposit(ctx context.Context, depositID string, amount int64, reason string) (*repository.Refund, error) {
+	d, err := s.GetDeposit(ctx, depositID)
+	if err != nil {
+		return nil, err
+	}
+	if d.Status != gateway.StatusSucceeded {
+		// Деньги ещё не списаны — частичный возврат невозможен, отпускаем hold
+		if amount != 0 {
+			return nil, ErrNotCaptured
+		}
+		return nil, s.ReleaseDeposit(ctx, depositID)
+	}
+	r, err := s.refunds.RefundDeposit(ctx, depositID, amount, reason)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *depositService) GetDeposit(ctx context.Context, depositID string) (repository.Deposit, error) {
+	d, err := s.repo.GetDepositByID(ctx, depositID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.Deposit{}, ErrNotFound
+	}
+	return d, err
+}
+
+func (s *depositService) ListByBooking(ctx context.Context, bookingID string) ([]repository.Deposit, error) {
+	return s.repo.ListDepositsByBookingID(ctx, bookingID)
+}
+
+func (s *depositService) ListByUser(ctx context.Context, userID string) ([]repository.Deposit, error) {
+	return s.repo.ListDepositsByUserID(ctx, userID)
+}
+
+func (s *depositService) ApplyGatewayUpdate(ctx context.Context, pi gateway.PaymentIntent, source string) error {
+	var capture *depositCapture
+	if pi.Status == gateway.StatusSucceeded && pi.AmountReceived > 0 {
+		capture = &depositCapture{amount: pi.AmountReceived}
+	}
+	_, err := s.transition(ctx, pi, depositStatus(pi), source, capture)
+	if errors.Is(err, ErrInvalidState) {
+		logStaleUpdate(ctx, s.log, "deposit", pi.ID, source, err)
+		return nil
+	}
+	return err
+}
+
+func (s *depositService) ExpireHold(ctx context.Context, depositID string) (repository.Deposit, error) {
+	d, err := s.GetDeposit(ctx, depositID)
+	if err != nil {
+		return repository.Deposit{}, err
+	}
+	if d.Status != gateway.StatusRequiresCapture {
+		return repository.Deposit{}, invalidTransition(d.Status, paymentstate.StatusExpired)
+	}
+	pi, err := s.stripe.GetPaymentIntent(ctx, depositID)
+	if err != nil {
+		return repository.Deposit{}, err
+	}
+	released := false
+	if pi.Status == gateway.StatusRequiresCapture {
+		// Наш срок наступил раньше, чем Stripe отменил авторизацию: списывать
+		// по ней уже рискованно, поэтому hold отпускаем сами
+		pi, err = s.stripe.CancelPaymentIntent(ctx, depositID)
+		if err != nil {
+			return repository.Deposit{}, err
+		}
+		released = true
+	}
+	var capture *depositCapture
+	if pi.Status == gateway.StatusSucceeded && pi.AmountReceived > 0 {
+		capture = &depositCapture{amount: pi.AmountReceived}
+	}
+	status := depositStatus(*pi)
+	if released && status == gateway.StatusCanceled {
+		status = paymentstate.StatusExpired
+	}
+	return s.transition(ctx, *pi, status, repository.StatusSourceScheduler, capture)
+}
+
+// refresh сохраняет статус депозита, который сейчас сообщает шлюз
+func (s *depositService) refresh(ctx context.Context, depositID string) (repository.Deposit, error) {
+	pi, err := s.stripe.GetPaymentIntent(ctx, depositID)
+	if err != nil {
+		return repository.Deposit{}, err
+	}
+	if err := s.ApplyGatewayUpdate(ctx, *pi, repository.StatusSourceAPI); err != nil {
+		return repository.Deposit{}, err
+	}
+	return s.GetDeposit(ctx, depositID)
+}
+
+// depositStatus — статус депозита по данным шлюза: авторизацию, которую
+// Stripe отменил сам, он отменяет по истечении срока
+func depositStatus(pi gateway.PaymentIntent) string {
+	if pi.Status == gateway.StatusCanceled && pi.CancellationReason == gateway.CancellationAutomatic {
+		return paymentstate.StatusExpired
+	}
+	return pi.Status
+}
+
+func (s *depositService) PrepareGatewayUpdate(ctx context.Context, pi gateway.PaymentIntent) gateway.PaymentIntent {
+	if depositStatus(pi) != gateway.StatusRequiresCapture || !pi.CaptureBefore.IsZero() {
+		return pi
+	}
+	// Срок задаётся один раз; не депозит или ещё не сохранённый депозит — не наше дело
+	d, err := s.repo.GetDepositByID(ctx, pi.ID)
+	if err != nil || d.HoldExpiresAt != nil {
+		return pi
+	}
+	return s.withCaptureBefore(ctx, pi)
+}
+
+// withCaptureBefore перечитывает PaymentIntent с раскрытым latest_charge.
+// Ошибка шлюза не мешает обновить статус: срок будет посчитан от создания.
+func (s *depositService) withCaptureBefore(ctx context.Context, pi gateway.PaymentIntent) gateway.PaymentIntent {
+	if !pi.CaptureBefore.IsZero() {
+		return pi
+	}
+	fresh, err := s.stripe.GetPaymentIntent(ctx, pi.ID)
+	if err != nil {
+		s.log.WarnContext(ctx, "failed to load deposit authorization deadline",
+			"payment_intent_id", pi.ID, "error", err)
+		return pi
+	}
+	pi.CaptureBefore = fresh.CaptureBefore
+	if pi.Created.IsZero() {
+		pi.Created = fresh.Created
+	}
+	return pi
+}
+
+// holdExpiry — срок авторизации по данным Stripe: capture_before карты.
+// Если Stripe его не сообщил, срок отсчитывается от создания PaymentIntent,
+// как и у Stripe, — а не от нашего обновления. Шлюз здесь не вызывается:
+// transition работает в транзакции.
+func (s *depositService) holdExpiry(pi gateway.PaymentIntent) *time.Time {
+	t := pi.CaptureBefore
+	if t.IsZero() {
+		created := pi.Created
+		if created.IsZero() {
+			created = time.Now()
+		}
+		t = created.Add(s.holdTTL)
+	}
+	return &t
+}
+
+func (s *depositService) History(ctx context.Context, depositID string) ([]repository.StatusChange, error) {
+	if _, err := s.GetDeposit(ctx, depositID); err != nil {
+		return nil, err
+	}
+	return s.history.ListStatusHistory(ctx, depositID, repository.RefundKindDeposit)
+}
+
+// transition переводит депозит pi.ID в статус to, если это разрешает state machine.
+// pi — последнее состояние PaymentIntent в шлюзе, по нему задаётся срок hold.
+// capture != nil — заодно сохраняет списанную сумму; при захвате без суммы
+// считается, что списан весь hold. Проигранная гонка с параллельным
+// обновлением (например, webhook) приводит к повторной проверке.
+// Статус, проводки в главной книге и перевод хосту фиксируются в одной транзакции.
+func (s *depositService) transition(ctx context.Context, pi gateway.PaymentIntent, to, source string, capture *depositCapture) (repository.Deposit, error) {
+	depositID := pi.ID
+	for attempt := 0; attempt < maxTransitionAttempts; attempt++ {
+		d, err := s.GetDeposit(ctx, depositID)
+		if err != nil {
+			return repository.Deposit{}, err
+		}
+		if !paymentstate.CanTransition(d.Status, to) {
+			return repository.Deposit{}, invalidTransition(d.Status, to)
+		}
+		if capture == nil && to == gateway.StatusSucceeded && d.CapturedAmount == 0 {
+			capture = &depositCapture{amount: d.Amount}
+		}
+
+		if capture == nil && d.Status == to {
+			return d, nil
+		}
+		if capture != nil && d.Status == to && d.CapturedAmount == capture.amount &&
+			(capture.reason == "" || capture.reason == d.CaptureReason) {
+			return d, nil
+		}
+
+		from := d.Status
+		d.Status = to
+		setHoldExpiry := to == gateway.StatusRequiresCapture && d.HoldExpiresAt == nil
+		if setHoldExpiry {
+			d.HoldExpiresAt = s.holdExpiry(pi)
+		}
+		if capture != nil {
+			d.CapturedAmount = capture.amount
+			if capture.reason != "" {
+				d.CaptureReason = capture.reason
+			}
+		}
+		err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+			if setHoldExpiry {
+				if err := s.repo.SetDepositHoldExpiry(ctx, depositID, *d.HoldExpiresAt); err != nil {
+					return err
+				}
+			}
+			var err error
+			if capture == nil {
+				err = s.repo.UpdateDepositStatus(ctx, depositID, from, to, source)
+			} else {
+				err = s.repo.UpdateDepositCapture(ctx, depositID, from, to, capture.amount, capture.reason, source)
+			}
+			if err != nil {
+				return err
+			}
+			return s.record(ctx, depositSubject(d), to)
+		})
+		if errors.Is(err, repository.ErrStatusChanged) {
+			continue
+		}
+		if err != nil {
+			return repository.Deposit{}, err
+		}
+
+		s.log.InfoContext(ctx, "deposit status changed",
+			"payment_intent_id", depositID, "from", from, "to", to, "source", source)
+		return d, nil
+	}
+	return repository.Deposit{}, repository.ErrStatusChanged
+}
+
+// record проводит смену статуса по главной книге, а списанный депозит
+// ставит в очередь на перевод хосту.
+func (s *depositService) record(ctx context.Context, subj LedgerSubject, status string) error {
+	if err := s.ledger.RecordStatus(ctx, subj, status); err != nil {
+		return err
+	}
+	if status != gateway.StatusSucceeded {
+		return nil
+	}
+	return s.payouts.ScheduleTransfer(ctx, subj)
 }
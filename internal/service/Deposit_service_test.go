@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"Payment-service/internal/fakegateway"
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+
+	"github.com/stripe/stripe-go/v74"
+)
+
+func newTestDepositService(t *testing.T) (DepositService, *memStore, *fakegateway.Gateway, string) {
+	t.Helper()
+	store := newMemStore()
+	gw := fakegateway.New("", "")
+	customerID, err := gw.CreateCustomer(context.Background(), "guest@example.com", "user-1")
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	svc := NewDepositService(store, store, store, nopLedger{}, nopPayouts{}, nil, nil, gw, nil, holdTTLForTests, discardLogger())
+	return svc, store, gw, customerID
+}
+
+// authorizeHold ставит hold на депозит и подтверждает его картой
+func authorizeHold(t *testing.T, svc DepositService, gw *fakegateway.Gateway, customerID string, amount int64) string {
+	t.Helper()
+	ctx := context.Background()
+	_, id, err := svc.AuthorizeDeposit(ctx, customerID, "user-1", "booking-1", "listing-1", "usd", amount)
+	if err != nil {
+		t.Fatalf("AuthorizeDeposit: %v", err)
+	}
+	pi, err := gw.ConfirmPaymentIntent(ctx, id, fakegateway.CardVisa)
+	if err != nil {
+		t.Fatalf("ConfirmPaymentIntent: %v", err)
+	}
+	if pi.Status != gateway.StatusRequiresCapture {
+		t.Fatalf("gateway status = %s, want %s", pi.Status, gateway.StatusRequiresCapture)
+	}
+	if err := svc.ApplyGatewayUpdate(ctx, *pi, repository.StatusSourceWebhook); err != nil {
+		t.Fatalf("ApplyGatewayUpdate: %v", err)
+	}
+	return id
+}
+
+func TestDepositService_PartialCapture(t *testing.T) {
+	ctx := context.Background()
+	svc, _, gw, customerID := newTestDepositService(t)
+	id := authorizeHold(t, svc, gw, customerID, 50000)
+
+	if _, err := svc.CaptureDeposit(ctx, id, 60000, "damage"); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("capture above the hold: err = %v, want ErrInvalidAmount", err)
+	}
+	d, err := svc.CaptureDeposit(ctx, id, 15000, "broken lamp")
+	if err != nil {
+		t.Fatalf("CaptureDeposit: %v", err)
+	}
+	if d.Status != gateway.StatusSucceeded || d.CapturedAmount != 15000 || d.CaptureReason != "broken lamp" {
+		t.Errorf("deposit after capture = %+v", d)
+	}
+	pi, _ := gw.GetPaymentIntent(ctx, id)
+	if pi.AmountReceived != 15000 || pi.AmountCapturable != 0 {
+		t.Errorf("gateway received %d, capturable %d; want 15000 and the rest released", pi.AmountReceived, pi.AmountCapturable)
+	}
+
+	// Webhook о том же списании ничего не меняет
+	if err := svc.ApplyGatewayUpdate(ctx, *pi, repository.StatusSourceWebhook); err != nil {
+		t.Fatalf("ApplyGatewayUpdate: %v", err)
+	}
+	history, _ := svc.History(ctx, id)
+	if len(history) != 3 {
+		t.Errorf("history has %d records, want 3: %+v", len(history), history)
+	}
+}
+
+func TestDepositService_CaptureBeforeWebhook(t *testing.T) {
+	ctx := context.Background()
+	svc, _, gw, customerID := newTestDepositService(t)
+	_, id, err := svc.AuthorizeDeposit(ctx, customerID, "user-1", "booking-1", "listing-1", "usd", 30000)
+	if err != nil {
+		t.Fatalf("AuthorizeDeposit: %v", err)
+	}
+	if _, err := svc.CaptureDeposit(ctx, id, 0, ""); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("capture of an unconfirmed hold: err = %v, want ErrInvalidState", err)
+	}
+
+	// Карта подтверждена, но webhook amount_capturable_updated ещё не дошёл
+	if _, err := gw.ConfirmPaymentIntent(ctx, id, fakegateway.CardVisa); err != nil {
+		t.Fatalf("ConfirmPaymentIntent: %v", err)
+	}
+	d, err := svc.CaptureDeposit(ctx, id, 10000, "late checkout")
+	if err != nil {
+		t.Fatalf("CaptureDeposit: %v", err)
+	}
+	if d.Status != gateway.StatusSucceeded || d.CapturedAmount != 10000 {
+		t.Errorf("deposit after capture = %+v", d)
+	}
+	history, _ := svc.History(ctx, id)
+	if len(history) != 3 || history[1].ToStatus != gateway.StatusRequiresCapture {
+		t.Errorf("history = %+v, want the hold recorded before the capture", history)
+	}
+}
+
+func TestDepositService_Release(t *testing.T) {
+	ctx := context.Background()
+	svc, _, gw, customerID := newTestDepositService(t)
+	id := authorizeHold(t, svc, gw, customerID, 20000)
+
+	if err := svc.ReleaseDeposit(ctx, id); err != nil {
+		t.Fatalf("ReleaseDeposit: %v", err)
+	}
+	d, _ := svc.GetDeposit(ctx, id)
+	if d.Status != gateway.StatusCanceled {
+		t.Errorf("status after release = %s, want %s", d.Status, gateway.StatusCanceled)
+	}
+	if _, err := svc.CaptureDeposit(ctx, id, 0, ""); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("capture after release: err = %v, want ErrInvalidState", err)
+	}
+	if _, err := svc.GetDeposit(ctx, "pi_unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDeposit of an unknown ID: err = %v, want ErrNotFound", err)
+	}
+}
+
+// txGuardGateway запоминает вызовы шлюза, сделанные при открытой транзакции
+type txGuardGateway struct {
+	*fakegateway.Gateway
+	store     *memStore
+	callsInTx []string
+}
+
+func (g *txGuardGateway) check(call string) {
+	if g.store.inTx() {
+		g.callsInTx = append(g.callsInTx, call)
+	}
+}
+
+func (g *txGuardGateway) GetPaymentIntent(ctx context.Context, id string) (*gateway.PaymentIntent, error) {
+	g.check("GetPaymentIntent")
+	return g.Gateway.GetPaymentIntent(ctx, id)
+}
+
+func (g *txGuardGateway) CapturePaymentIntent(ctx context.Context, id string, amount int64) (*gateway.PaymentIntent, error) {
+	g.check("CapturePaymentIntent")
+	return g.Gateway.CapturePaymentIntent(ctx, id, amount)
+}
+
+func (g *txGuardGateway) CancelPaymentIntent(ctx context.Context, id string) (*gateway.PaymentIntent, error) {
+	g.check("CancelPaymentIntent")
+	return g.Gateway.CancelPaymentIntent(ctx, id)
+}
+
+func TestDepositService_HoldExpiryFromWebhookIsLoadedOutsideTransaction(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	gw := &txGuardGateway{Gateway: fakegateway.New("", ""), store: store}
+	customerID, err := gw.CreateCustomer(ctx, "guest@example.com", "user-1")
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	deposits := NewDepositService(store, store, store, nopLedger{}, nopPayouts{}, nil, nil, gw, nil, holdTTLForTests, discardLogger())
+	events := &markedEvents{}
+	s := &stripeEventService{repo: events, uow: store, depositService: deposits, log: discardLogger()}
+
+	_, id, err := deposits.AuthorizeDeposit(ctx, customerID, "user-1", "booking-1", "listing-1", "usd", 30000)
+	if err != nil {
+		t.Fatalf("AuthorizeDeposit: %v", err)
+	}
+	pi, err := gw.ConfirmPaymentIntent(ctx, id, fakegateway.CardVisa)
+	if err != nil {
+		t.Fatalf("ConfirmPaymentIntent: %v", err)
+	}
+	// В webhook latest_charge — только ID, capture_before в нём нет
+	webhook := func() stripe.Event {
+		return stripeEvent(t, "payment_intent.amount_capturable_updated", map[string]interface{}{
+			"id": id, "object": "payment_intent", "amount": 30000, "amount_capturable": 30000,
+			"currency": "usd", "status": "requires_capture", "capture_method": "manual",
+			"created": pi.Created.Unix(), "latest_charge": "ch_1",
+			"metadata": map[string]string{"booking_id": "booking-1", "user_id": "user-1"},
+		})
+	}
+	if err := s.processAndMark(ctx, "evt_1", webhook()); err != nil {
+		t.Fatalf("processAndMark: %v", err)
+	}
+	if len(gw.callsInTx) != 0 {
+		t.Errorf("gateway called inside the transaction: %v", gw.callsInTx)
+	}
+	d, _ := deposits.GetDeposit(ctx, id)
+	if d.HoldExpiresAt == nil || !d.HoldExpiresAt.Equal(pi.CaptureBefore) {
+		t.Fatalf("hold expires at %v, want capture_before %v", d.HoldExpiresAt, pi.CaptureBefore)
+	}
+
+	// Повторный webhook срок не меняет и шлюз не вызывает
+	if err := s.processAndMark(ctx, "evt_2", webhook()); err != nil {
+		t.Fatalf("repeated processAndMark: %v", err)
+	}
+	if err := store.SetDepositHoldExpiry(ctx, id, time.Now()); err != nil {
+		t.Fatalf("SetDepositHoldExpiry: %v", err)
+	}
+	if d, _ := deposits.GetDeposit(ctx, id); !d.HoldExpiresAt.Equal(pi.CaptureBefore) {
+		t.Errorf("hold expiry was overwritten: %v", d.HoldExpiresAt)
+	}
+	if len(gw.callsInTx) != 0 {
+		t.Errorf("gateway called inside the transaction: %v", gw.callsInTx)
+	}
+}
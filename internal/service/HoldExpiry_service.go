@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"Payment-service/internal/repository"
+)
+
+const (
+	// holdExpiryBatch is how many deposits are read per page.
+	holdExpiryBatch = 100
+	// holdExpiryCaptureReason is stored with deposits captured by the capture policy.
+	holdExpiryCaptureReason = "authorization expiring"
+)
+
+// HoldExpiryService watches uncaptured deposit holds: it announces that a hold
+// is about to expire, applies the capture or release policy of the booking or
+// listing shortly before expiry and marks holds that lapsed as expired.
+type HoldExpiryService interface {
+	// ProcessHolds handles every deposit whose hold is due for a notice, an
+	// action or expiry and returns how many deposits were changed or notified.
+	ProcessHolds(ctx context.Context) (int, error)
+	// Policy returns the policy of a listing or booking; ErrNotFound if there is none.
+	Policy(ctx context.Context, scope, scopeID string) (repository.DepositHoldPolicy, error)
+	// SetPolicy creates or replaces the policy of a listing or booking.
+	SetPolicy(ctx context.Context, scope, scopeID, action string) (repository.DepositHoldPolicy, error)
+	// DeletePolicy removes a policy; ErrNotFound if there is none.
+	DeletePolicy(ctx context.Context, scope, scopeID string) error
+}
+
+// HoldExpiryOptions configures HoldExpiryService.
+type HoldExpiryOptions struct {
+	// Notice is how long before expiry deposit.hold_expiring is published.
+	Notice time.Duration
+	// ActionLead is how long before expiry the capture or release policy runs.
+	ActionLead time.Duration
+	// DefaultAction applies when neither the booking nor the listing has a policy.
+	DefaultAction string
+}
+
+// holdExpiryService is a concrete implementation of HoldExpiryService.
+type holdExpiryService struct {
+	repo     repository.DepositRepo
+	policies repository.DepositHoldPolicyRepo
+	deposits DepositService
+	opts     HoldExpiryOptions
+	log      *slog.Logger
+}
+
+// NewHoldExpiryService constructs a HoldExpiryService.
+func NewHoldExpiryService(
+	repo repository.DepositRepo,
+	policies repository.DepositHoldPolicyRepo,
+	deposits DepositService,
+	opts HoldExpiryOptions,
+	logger *slog.Logger,
+) HoldExpiryService {
+	if opts.DefaultAction == "" {
+		opts.DefaultAction = repository.HoldActionNone
+	}
+	return &holdExpiryService{repo: repo, policies: policies, deposits: deposits, opts: opts, log: logger}
+}
+
+func (s *holdExpiryService) ProcessHolds(ctx context.Context) (int, error) {
+	now := time.Now()
+	horizon := max(s.opts.Notice, s.opts.ActionLead)
+	var after *repository.DepositCursor
+	handled := 0
+	for ctx.Err() == nil {
+		list, err := s.repo.ListExpiringDeposits(ctx, now.Add(horizon), after, holdExpiryBatch)
+		if err != nil {
+			return handled, err
+		}
+		for _, d := range list {
+			if s.process(ctx, d, now) {
+				handled++
+			}
+		}
+		if len(list) < holdExpiryBatch {
+			return handled, nil
+		}
+		last := list[len(list)-1]
+		after = &repository.DepositCursor{HoldExpiresAt: *last.HoldExpiresAt, StripePIID: last.StripePIID}
+	}
+	return handled, ctx.Err()
+}
+
+// process handles one deposit. Errors are logged rather than returned so that
+// one failing deposit does not hold up the rest; it is retried on the next run.
+func (s *holdExpiryService) process(ctx context.Context, d repository.Deposit, now time.Time) bool {
+	expiresAt := *d.HoldExpiresAt
+	log := s.log.With("payment_intent_id", d.StripePIID, "booking_id", d.BookingID, "hold_expires_at", expiresAt)
+	changed := false
+
+	if d.ExpiryNotifiedAt == nil && now.After(expiresAt.Add(-s.opts.Notice)) {
+		notified, err := s.repo.MarkDepositExpiryNotified(ctx, d.StripePIID)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to publish deposit hold expiry notice", "error", err)
+		} else if notified {
+			log.InfoContext(ctx, "deposit hold expiring")
+			changed = true
+		}
+	}
+	if now.Before(expiresAt.Add(-s.opts.ActionLead)) {
+		return changed
+	}
+
+	action, err := s.action(ctx, d)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to resolve deposit hold policy", "error", err)
+		return changed
+	}
+	switch action {
+	case repository.HoldActionCapture:
+		_, err = s.deposits.CaptureDeposit(ctx, d.StripePIID, 0, holdExpiryCaptureReason)
+	case repository.HoldActionRelease:
+		err = s.deposits.ReleaseDeposit(ctx, d.StripePIID)
+	}
+	if action != repository.HoldActionNone {
+		if err == nil {
+			log.InfoContext(ctx, "deposit hold policy applied", "action", action)
+			return true
+		}
+		log.WarnContext(ctx, "deposit hold policy failed", "action", action, "error", err)
+	}
+
+	// Without a policy, or if it keeps failing, the hold is left to expire
+	if now.Before(expiresAt) {
+		return changed
+	}
+	after, err := s.deposits.ExpireHold(ctx, d.StripePIID)
+	if err != nil {
+		log.WarnContext(ctx, "failed to expire deposit hold", "error", err)
+		return changed
+	}
+	log.InfoContext(ctx, "deposit hold expired", "status", after.Status)
+	return true
+}
+
+// action returns what the booking's or listing's policy asks for.
+func (s *holdExpiryService) action(ctx context.Context, d repository.Deposit) (string, error) {
+	p, err := s.policies.ResolveDepositHoldPolicy(ctx, d.BookingID, d.ListingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.opts.DefaultAction, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return p.Action, nil
+}
+
+func (s *holdExpiryService) Policy(ctx context.Context, scope, scopeID string) (repository.DepositHoldPolicy, error) {
+	if err := validatePolicyScope(scope, scopeID); err != nil {
+		return repository.DepositHoldPolicy{}, err
+	}
+	p, err := s.policies.GetDepositHoldPolicy(ctx, scope, scopeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.DepositHoldPolicy{}, ErrNotFound
+	}
+	return p, err
+}
+
+func (s *holdExpiryService) SetPolicy(ctx context.Context, scope, scopeID, action string) (repository.DepositHoldPolicy, error) {
+	if err := validatePolicyScope(scope, scopeID); err != nil {
+		return repository.DepositHoldPolicy{}, err
+	}
+	if !IsHoldAction(action) {
+		return repository.DepositHoldPolicy{}, fmt.Errorf("%w: action must be none, capture or release", ErrInvalidInput)
+	}
+	p, err := s.policies.SaveDepositHoldPolicy(ctx, repository.DepositHoldPolicy{Scope: scope, ScopeID: scopeID, Action: action})
+	if err != nil {
+		return repository.DepositHoldPolicy{}, err
+	}
+	s.log.InfoContext(ctx, "deposit hold policy set", "scope", scope, "scope_id", scopeID, "action", action)
+	return p, nil
+}
+
+func (s *holdExpiryService) DeletePolicy(ctx context.Context, scope, scopeID string) error {
+	if err := validatePolicyScope(scope, scopeID); err != nil {
+		return err
+	}
+	err := s.policies.DeleteDepositHoldPolicy(ctx, scope, scopeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// IsHoldAction reports whether action is a valid deposit hold policy action.
+func IsHoldAction(action string) bool {
+	switch action {
+	case repository.HoldActionNone, repository.HoldActionCapture, repository.HoldActionRelease:
+		return true
+	}
+	return false
+}
+
+func validatePolicyScope(scope, scopeID string) error {
+	if scope != repository.HoldPolicyScopeListing && scope != repository.HoldPolicyScopeBooking {
+		return fmt.Errorf("%w: scope must be listing or booking", ErrInvalidInput)
+	}
+	if scopeID == "" {
+		return fmt.Errorf("%w: empty %s id", ErrInvalidInput, scope)
+	}
+	return nil
+}
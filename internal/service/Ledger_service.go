@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/paymentstate"
+	"Payment-service/internal/repository"
+)
+
+// Виды проводок главной книги
+const (
+	ledgerKindAuthorization     = "authorization"
+	ledgerKindRelease           = "release"
+	ledgerKindCapture           = "capture"
+	ledgerKindCaptureAdjustment = "capture_adjustment"
+	ledgerKindRefund            = "refund"
+	ledgerKindRefundSettled     = "refund_settled"
+	ledgerKindReversal          = "reversal"
+	ledgerKindHostTransfer      = "host_transfer"
+	ledgerKindTransferReversal  = "transfer_reversal"
+)
+
+// ledgerEntriesLimit — сколько проводок читается по одному PaymentIntent;
+// на практике их единицы
+const ledgerEntriesLimit = 500
+
+// LedgerSubject — платёж или депозит, по которому делаются проводки
+type LedgerSubject struct {
+	Kind            string // repository.RefundKindPayment или RefundKindDeposit
+	PaymentIntentID string
+	UserID          string
+	HostID          string
+	Currency        string
+	Amount          int64 // сумма hold
+	Captured        int64 // списанная сумма
+}
+
+func paymentSubject(pi repository.PaymentIntent) LedgerSubject {
+	return LedgerSubject{
+		Kind:            repository.RefundKindPayment,
+		PaymentIntentID: pi.StripePIID,
+		UserID:          pi.UserID,
+		HostID:          pi.HostID,
+		Currency:        pi.Currency,
+		Amount:          pi.Amount,
+		Captured:        pi.Amount,
+	}
+}
+
+func depositSubject(d repository.Deposit) LedgerSubject {
+	return LedgerSubject{
+		Kind:            repository.RefundKindDeposit,
+		PaymentIntentID: d.StripePIID,
+		UserID:          d.UserID,
+		HostID:          d.HostID,
+		Currency:        d.Currency,
+		Amount:          d.Amount,
+		Captured:        d.CapturedAmount,
+	}
+}
+
+// LedgerService ведёт главную книгу: каждое движение денег по платежам,
+// депозитам и возвратам записывается сбалансированной проводкой.
+// Методы записи идемпотентны — повторный вызов с тем же состоянием
+// ничего не проводит. Их стоит вызывать в одной транзакции с обновлением
+// статуса, чтобы книга не расходилась с данными.
+type LedgerService interface {
+	// RecordStatus проводит движения, соответствующие статусу PaymentIntent:
+	// hold при requires_capture, списание при succeeded, снятие hold при canceled
+	RecordStatus(ctx context.Context, subj LedgerSubject, status string) error
+	// RecordRefund проводит возврат по его статусу в Stripe; неуспешный
+	// возврат сторнирует ранее сделанные проводки
+	RecordRefund(ctx context.Context, subj LedgerSubject, r repository.Refund) error
+	// RecordTransfer проводит выполненный перевод хосту: долг перед ним
+	// гасится деньгами с баланса платформы
+	RecordTransfer(ctx context.Context, t repository.HostTransfer) error
+	// RecordTransferReversal проводит возврат денег с аккаунта хоста:
+	// баланс платформы пополняется, а переплата хосту гасится
+	RecordTransferReversal(ctx context.Context, r repository.HostTransferReversal) error
+	// HostShare возвращает долю хоста в платеже или депозите по книге:
+	// списанная сумма за вычетом комиссии и возвратов, включая уже переведённое
+	HostShare(ctx context.Context, paymentIntentID string) (int64, error)
+	// Accounts возвращает счета с балансами
+	Accounts(ctx context.Context, f repository.LedgerAccountFilter) ([]repository.LedgerAccount, error)
+	// Account возвращает счёт с балансом или ErrNotFound
+	Account(ctx context.Context, id int64) (repository.LedgerAccount, error)
+	// Entries возвращает проводки, от новых к старым
+	Entries(ctx context.Context, f repository.LedgerEntryFilter) ([]repository.LedgerEntry, error)
+}
+
+type ledgerService struct {
+	repo   repository.LedgerRepo
+	feeBPS int64
+	log    *slog.Logger
+}
+
+// NewLedgerService конструктор. feeBPS — комиссия платформы с платежей
+// в базисных пунктах; с депозитов комиссия не берётся.
+func NewLedgerService(repo repository.LedgerRepo, feeBPS int64, logger *slog.Logger) LedgerService {
+	return &ledgerService{repo: repo, feeBPS: feeBPS, log: logger}
+}
+
+func (s *ledgerService) RecordStatus(ctx context.Context, subj LedgerSubject, status string) error {
+	book, err := s.book(ctx, subj.PaymentIntentID)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case gateway.StatusRequiresCapture:
+		return s.post(ctx, subj, ledgerKindAuthorization, book.key(ledgerKindAuthorization),
+			"hold on customer card", s.authorizationLines(subj))
+	case gateway.StatusSucceeded:
+		if err := s.release(ctx, subj, book); err != nil {
+			return err
+		}
+		return s.capture(ctx, subj, book)
+	case gateway.StatusCanceled, paymentstate.StatusExpired:
+		return s.release(ctx, subj, book)
+	}
+	return nil
+}
+
+func (s *ledgerService) authorizationLines(subj LedgerSubject) []repository.LedgerLine {
+	held := repository.LedgerPaymentsHeld
+	if subj.Kind == repository.RefundKindDeposit {
+		held = repository.LedgerDepositsHeld
+	}
+	return []repository.LedgerLine{
+		{Account: ledgerAccount(repository.LedgerCustomer, subj.UserID, subj.Currency), Amount: subj.Amount},
+		{Account: ledgerAccount(held, "", subj.Currency), Amount: -subj.Amount},
+	}
+}
+
+// release снимает hold, если он был проведён. Списание проводится отдельно:
+// деньги уходят не с hold, а приходят на баланс платформы.
+func (s *ledgerService) release(ctx context.Context, subj LedgerSubject, book ledgerBook) error {
+	auth, ok := book.entries[book.key(ledgerKindAuthorization)]
+	if !ok {
+		return nil
+	}
+	return s.post(ctx, subj, ledgerKindRelease, book.key(ledgerKindRelease),
+		"hold released", reverseLines(auth))
+}
+
+// capture проводит списание: деньги на балансе платформы, за вычетом
+// комиссии причитаются хосту. Если списанная сумма изменилась после
+// первой проводки (депозит), проводится корректировка на разницу.
+func (s *ledgerService) capture(ctx context.Context, subj LedgerSubject, book ledgerBook) error {
+	if subj.Captured <= 0 {
+		return nil
+	}
+	captured, fee := book.captured()
+	delta := subj.Captured - captured
+	if delta == 0 {
+		return nil
+	}
+	feeDelta := platformFee(subj, s.feeBPS) - fee
+	lines := []repository.LedgerLine{
+		{Account: ledgerAccount(repository.LedgerPlatform, "", subj.Currency), Amount: delta},
+		{Account: ledgerAccount(repository.LedgerHostPayable, subj.HostID, subj.Currency), Amount: -(delta - feeDelta)},
+		{Account: ledgerAccount(repository.LedgerFees, "", subj.Currency), Amount: -feeDelta},
+	}
+	if captured == 0 {
+		return s.post(ctx, subj, ledgerKindCapture, book.key(ledgerKindCapture), "captured", lines)
+	}
+	key := book.key(ledgerKindCaptureAdjustment + ":" + strconv.FormatInt(subj.Captured, 10))
+	return s.post(ctx, subj, ledgerKindCaptureAdjustment, key, "captured amount adjusted", lines)
+}
+
+// platformFee — комиссия платформы со списанной суммы; с депозитов не берётся
+func platformFee(subj LedgerSubject, feeBPS int64) int64 {
+	if subj.Kind == repository.RefundKindDeposit {
+		return 0
+	}
+	return subj.Captured * feeBPS / 10000
+}
+
+// RecordRefund: при создании возврата долг перед хостом и комиссия
+// уменьшаются пропорционально сумме возврата, а сумма ждёт на счёте refunds;
+// когда Stripe завершает возврат, деньги уходят с баланса платформы.
+func (s *ledgerService) RecordRefund(ctx context.Context, subj LedgerSubject, r repository.Refund) error {
+	if r.StripeRefundID == "" {
+		return nil
+	}
+	book, err := s.book(ctx, subj.PaymentIntentID)
+	if err != nil {
+		return err
+	}
+	issueKey := "refund:" + r.StripeRefundID
+	settleKey := issueKey + ":settled"
+
+	switch r.Status {
+	case gateway.RefundStatusFailed, gateway.RefundStatusCanceled:
+		for _, key := range []string{settleKey, issueKey} {
+			e, ok := book.entries[key]
+			if !ok {
+				continue
+			}
+			if err := s.post(ctx, subj, ledgerKindReversal, key+":reversed",
+				"refund "+r.Status, reverseLines(e)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, ok := book.entries[issueKey]; !ok {
+		captured, fee := book.captured()
+		var feeShare int64
+		if captured > 0 {
+			feeShare = fee * r.Amount / captured
+		}
+		lines := []repository.LedgerLine{
+			{Account: ledgerAccount(repository.LedgerHostPayable, subj.HostID, subj.Currency), Amount: r.Amount - feeShare},
+			{Account: ledgerAccount(repository.LedgerFees, "", subj.Currency), Amount: feeShare},
+			{Account: ledgerAccount(repository.LedgerRefunds, "", subj.Currency), Amount: -r.Amount},
+		}
+		if err := s.post(ctx, subj, ledgerKindRefund, issueKey, "refund issued", lines); err != nil {
+			return err
+		}
+	}
+	if r.Status != gateway.RefundStatusSucceeded {
+		return nil
+	}
+	return s.post(ctx, subj, ledgerKindRefundSettled, settleKey, "refund settled", []repository.LedgerLine{
+		{Account: ledgerAccount(repository.LedgerRefunds, "", subj.Currency), Amount: r.Amount},
+		{Account: ledgerAccount(repository.LedgerPlatform, "", subj.Currency), Amount: -r.Amount},
+	})
+}
+
+func (s *ledgerService) RecordTransfer(ctx context.Context, t repository.HostTransfer) error {
+	subj := LedgerSubject{Kind: t.Kind, PaymentIntentID: t.StripePIID}
+	return s.post(ctx, subj, ledgerKindHostTransfer, "transfer:"+strconv.FormatInt(t.ID, 10),
+		"transferred to host", []repository.LedgerLine{
+			{Account: ledgerAccount(repository.LedgerHostPayable, t.HostID, t.Currency), Amount: t.Amount},
+			{Account: ledgerAccount(repository.LedgerPlatform, "", t.Currency), Amount: -t.Amount},
+		})
+}
+
+func (s *ledgerService) RecordTransferReversal(ctx context.Context, r repository.HostTransferReversal) error {
+	subj := LedgerSubject{Kind: r.Kind, PaymentIntentID: r.StripePIID}
+	return s.post(ctx, subj, ledgerKindTransferReversal, "transfer_reversal:"+strconv.FormatInt(r.ID, 10),
+		"transfer reversed from host", []repository.LedgerLine{
+			{Account: ledgerAccount(repository.LedgerPlatform, "", r.Currency), Amount: r.Amount},
+			{Account: ledgerAccount(repository.LedgerHostPayable, r.HostID, r.Currency), Amount: -r.Amount},
+		})
+}
+
+// HostShare считает по строкам host_payable всех проводок платежа, кроме
+// самих переводов, — так доля совпадает с книгой вплоть до округления
+// комиссии при частичных возвратах.
+func (s *ledgerService) HostShare(ctx context.Context, paymentIntentID string) (int64, error) {
+	book, err := s.book(ctx, paymentIntentID)
+	if err != nil {
+		return 0, err
+	}
+	var share int64
+	for _, e := range book.entries {
+		if e.Kind == ledgerKindHostTransfer || e.Kind == ledgerKindTransferReversal {
+			continue
+		}
+		for _, p := range e.Postings {
+			if p.AccountType == repository.LedgerHostPayable {
+				share -= p.Amount
+			}
+		}
+	}
+	return share, nil
+}
+
+// post записывает проводку, пропуская нулевые строки
+func (s *ledgerService) post(ctx context.Context, subj LedgerSubject, kind, key, description string, lines []repository.LedgerLine) error {
+	entry := repository.NewLedgerEntry{
+		IdempotencyKey: key,
+		Kind:           kind,
+		Reference:      subj.PaymentIntentID,
+		Description:    subj.Kind + " " + description,
+	}
+	for _, l := range lines {
+		if l.Amount != 0 {
+			entry.Lines = append(entry.Lines, l)
+		}
+	}
+	if len(entry.Lines) == 0 {
+		return nil
+	}
+	posted, err := s.repo.PostLedgerEntry(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("post ledger entry %s: %w", key, err)
+	}
+	if posted {
+		s.log.DebugContext(ctx, "ledger entry posted",
+			"payment_intent_id", subj.PaymentIntentID, "kind", kind, "idempotency_key", key)
+	}
+	return nil
+}
+
+func (s *ledgerService) Accounts(ctx context.Context, f repository.LedgerAccountFilter) ([]repository.LedgerAccount, error) {
+	return s.repo.ListLedgerAccounts(ctx, f)
+}
+
+func (s *ledgerService) Account(ctx context.Context, id int64) (repository.LedgerAccount, error) {
+	a, err := s.repo.GetLedgerAccount(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.LedgerAccount{}, ErrNotFound
+	}
+	return a, err
+}
+
+func (s *ledgerService) Entries(ctx context.Context, f repository.LedgerEntryFilter) ([]repository.LedgerEntry, error) {
+	return s.repo.ListLedgerEntries(ctx, f)
+}
+
+// ledgerBook — уже сделанные проводки по одному PaymentIntent
+type ledgerBook struct {
+	paymentIntentID string
+	entries         map[string]repository.LedgerEntry // по IdempotencyKey
+}
+
+func (s *ledgerService) book(ctx context.Context, paymentIntentID string) (ledgerBook, error) {
+	list, err := s.repo.ListLedgerEntries(ctx, repository.LedgerEntryFilter{
+		Reference: paymentIntentID,
+		Limit:     ledgerEntriesLimit,
+	})
+	if err != nil {
+		return ledgerBook{}, err
+	}
+	b := ledgerBook{paymentIntentID: paymentIntentID, entries: make(map[string]repository.LedgerEntry, len(list))}
+	for _, e := range list {
+		b.entries[e.IdempotencyKey] = e
+	}
+	return b, nil
+}
+
+func (b ledgerBook) key(kind string) string {
+	return b.paymentIntentID + ":" + kind
+}
+
+// captured возвращает проведённую списанную сумму и комиссию с неё
+func (b ledgerBook) captured() (amount, fee int64) {
+	for _, e := range b.entries {
+		if e.Kind != ledgerKindCapture && e.Kind != ledgerKindCaptureAdjustment {
+			continue
+		}
+		for _, p := range e.Postings {
+			switch p.AccountType {
+			case repository.LedgerPlatform:
+				amount += p.Amount
+			case repository.LedgerFees:
+				fee -= p.Amount
+			}
+		}
+	}
+	return amount, fee
+}
+
+func ledgerAccount(typ, owner, currency string) repository.LedgerAccountRef {
+	return repository.LedgerAccountRef{Type: typ, OwnerID: owner, Currency: currency}
+}
+
+// reverseLines — строки сторно для проводки
+func reverseLines(e repository.LedgerEntry) []repository.LedgerLine {
+	lines := make([]repository.LedgerLine, 0, len(e.Postings))
+	for _, p := range e.Postings {
+		lines = append(lines, repository.LedgerLine{
+			Account: ledgerAccount(p.AccountType, p.OwnerID, p.Currency),
+			Amount:  -p.Amount,
+		})
+	}
+	return lines
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+)
+
+// ledgerScenario проводит платёж с частичным возвратом и переводом хосту,
+// неуспешный возврат и депозит, списанная сумма которого изменилась
+func ledgerScenario(ctx context.Context, svc LedgerService) []func() error {
+	payment := LedgerSubject{
+		Kind: repository.RefundKindPayment, PaymentIntentID: "pi_pay", UserID: "user-1", HostID: "host-1",
+		Currency: "usd", Amount: 10000, Captured: 10000,
+	}
+	deposit := LedgerSubject{
+		Kind: repository.RefundKindDeposit, PaymentIntentID: "pi_dep", UserID: "user-1", HostID: "host-1",
+		Currency: "usd", Amount: 5000,
+	}
+	refund := func(id, status string, amount int64) repository.Refund {
+		return repository.Refund{StripeRefundID: id, StripePIID: payment.PaymentIntentID, Amount: amount, Currency: "usd", Status: status}
+	}
+	return []func() error{
+		func() error { return svc.RecordStatus(ctx, payment, gateway.StatusRequiresCapture) },
+		func() error { return svc.RecordStatus(ctx, payment, gateway.StatusSucceeded) },
+		func() error { return svc.RecordRefund(ctx, payment, refund("re_1", gateway.RefundStatusPending, 2000)) },
+		func() error {
+			return svc.RecordRefund(ctx, payment, refund("re_1", gateway.RefundStatusSucceeded, 2000))
+		},
+		func() error { return svc.RecordRefund(ctx, payment, refund("re_2", gateway.RefundStatusPending, 1000)) },
+		func() error { return svc.RecordRefund(ctx, payment, refund("re_2", gateway.RefundStatusFailed, 1000)) },
+		func() error {
+			return svc.RecordTransfer(ctx, repository.HostTransfer{
+				ID: 1, StripePIID: payment.PaymentIntentID, Kind: payment.Kind, HostID: "host-1", Amount: 7200, Currency: "usd",
+			})
+		},
+		func() error { return svc.RecordStatus(ctx, deposit, gateway.StatusRequiresCapture) },
+		func() error {
+			captured := deposit
+			captured.Captured = 3000
+			return svc.RecordStatus(ctx, captured, gateway.StatusSucceeded)
+		},
+		func() error {
+			adjusted := deposit
+			adjusted.Captured = 4000
+			return svc.RecordStatus(ctx, adjusted, gateway.StatusSucceeded)
+		},
+	}
+}
+
+func TestLedgerService_EntriesBalanceAndRepeatsAreIdempotent(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemLedger()
+	svc := NewLedgerService(repo, 1000, discardLogger()) // комиссия 10%
+	steps := ledgerScenario(ctx, svc)
+
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		// Повторный webhook или ретрай приходит с тем же состоянием
+		posted := len(repo.entries)
+		if err := step(); err != nil {
+			t.Fatalf("repeated step %d: %v", i, err)
+		}
+		if len(repo.entries) != posted {
+			t.Errorf("repeated step %d posted %d more entries", i, len(repo.entries)-posted)
+		}
+	}
+	for _, e := range repo.entries {
+		var sum int64
+		for _, p := range e.Postings {
+			sum += p.Amount
+		}
+		if sum != 0 {
+			t.Errorf("entry %s is not balanced: %+v", e.IdempotencyKey, e.Postings)
+		}
+	}
+
+	balances := []struct {
+		typ, owner string
+		want       int64
+	}{
+		{repository.LedgerCustomer, "user-1", 0},
+		{repository.LedgerPaymentsHeld, "", 0},
+		{repository.LedgerDepositsHeld, "", 0},
+		{repository.LedgerRefunds, "", 0},
+		// 10000 списано + 4000 депозит - 2000 возврат - 7200 переведено хосту
+		{repository.LedgerPlatform, "", 4800},
+		// 9000 за платёж - 1800 по возврату + 4000 депозит - 7200 переведено
+		{repository.LedgerHostPayable, "host-1", -4000},
+		{repository.LedgerFees, "", -800},
+	}
+	for _, b := range balances {
+		if got := repo.balance(b.typ, b.owner, "usd"); got != b.want {
+			t.Errorf("%s %s balance = %d, want %d", b.typ, b.owner, got, b.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"Payment-service/internal/events"
+	"Payment-service/internal/publisher"
+	"Payment-service/internal/repository"
+)
+
+const (
+	// outboxPublishLease keeps other relays away from an event being published.
+	outboxPublishLease = time.Minute
+	// outboxRetryBase is the delay before the first retry; it doubles each attempt.
+	outboxRetryBase = 5 * time.Second
+	// outboxRetryMaxDelay caps the backoff. Events are retried until published.
+	outboxRetryMaxDelay = 10 * time.Minute
+)
+
+// OutboxService relays events recorded in the outbox to the configured publisher.
+type OutboxService interface {
+	// RelayDue publishes up to limit pending events and returns how many were claimed.
+	RelayDue(ctx context.Context, limit int) (int, error)
+}
+
+// outboxService is a concrete implementation of OutboxService.
+type outboxService struct {
+	repo      repository.OutboxRepo
+	publisher publisher.Publisher
+	log       *slog.Logger
+}
+
+// NewOutboxService constructs an OutboxService.
+func NewOutboxService(repo repository.OutboxRepo, pub publisher.Publisher, logger *slog.Logger) OutboxService {
+	return &outboxService{repo: repo, publisher: pub, log: logger}
+}
+
+func (s *outboxService) RelayDue(ctx context.Context, limit int) (int, error) {
+	claimed, err := s.repo.ClaimOutboxEvents(ctx, limit, outboxPublishLease)
+	if err != nil {
+		return 0, err
+	}
+	// Claim returns at most one event per aggregate; keep the global order anyway
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].ID < claimed[j].ID })
+
+	for _, e := range claimed {
+		err := s.publisher.Publish(ctx, events.Envelope{
+			ID:            e.ID,
+			Type:          e.EventType,
+			AggregateType: e.AggregateType,
+			AggregateID:   e.AggregateID,
+			OccurredAt:    e.CreatedAt,
+			Data:          e.Payload,
+		})
+		if err != nil {
+			s.log.WarnContext(ctx, "publish outbox event failed",
+				"outbox_event_id", e.ID, "event_type", e.EventType, "attempt", e.Attempts, "error", err)
+			next := time.Now().Add(backoff(outboxRetryBase, outboxRetryMaxDelay, e.Attempts))
+			if markErr := s.repo.MarkOutboxEventFailed(ctx, e.ID, err.Error(), next); markErr != nil {
+				s.log.ErrorContext(ctx, "failed to mark outbox event", "outbox_event_id", e.ID, "error", markErr)
+			}
+			continue
+		}
+		if err := s.repo.MarkOutboxEventPublished(ctx, e.ID); err != nil {
+			// Событие уйдёт повторно после lease — допустимо при at-least-once
+			s.log.ErrorContext(ctx, "failed to mark outbox event published", "outbox_event_id", e.ID, "error", err)
+		}
+	}
+	return len(claimed), nil
+}
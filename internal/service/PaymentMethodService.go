@@ -2,36 +2,39 @@ package service
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
+	"Payment-service/internal/gateway"
 	"Payment-service/internal/repository"
-	stripeadapter "Payment-service/internal/stripeadapter"
-	stripePkg "github.com/stripe/stripe-go/v74"
 )
 
 // PaymentMethodService defines logic for saving and listing cards.
 type PaymentMethodService interface {
 	// CreateSetupIntent issues a SetupIntent for the given customer and usage.
-	CreateSetupIntent(ctx context.Context, customerID string, usage stripePkg.SetupIntentUsage) (string, error)
+	CreateSetupIntent(ctx context.Context, customerID string, usage string) (string, error)
 	// ListByUser retrieves saved cards for a user.
 	ListByUser(ctx context.Context, userID string) ([]repository.PaymentMethod, error)
-	RetrieveAndSavePaymentMethod(ctx context.Context, userID, pmID string) (repository.PaymentMethod, error)
+	// RetrievePaymentMethod reads a card from the gateway without storing it.
+	RetrievePaymentMethod(ctx context.Context, userID, pmID string) (repository.PaymentMethod, error)
+	// SavePaymentMethod stores a card read by RetrievePaymentMethod.
+	SavePaymentMethod(ctx context.Context, pm repository.PaymentMethod) error
 }
 
 // paymentMethodService is a concrete implementation of PaymentMethodService.
 type paymentMethodService struct {
 	repo   repository.PaymentMethodRepo
-	stripe *stripeadapter.Client
+	stripe gateway.PaymentGateway
+	log    *slog.Logger
 }
 
 // NewPaymentMethodService constructs a PaymentMethodService.
-func NewPaymentMethodService(repo repository.PaymentMethodRepo, client *stripeadapter.Client) PaymentMethodService {
-	return &paymentMethodService{repo: repo, stripe: client}
+func NewPaymentMethodService(repo repository.PaymentMethodRepo, client gateway.PaymentGateway, logger *slog.Logger) PaymentMethodService {
+	return &paymentMethodService{repo: repo, stripe: client, log: logger}
 }
 
 // CreateSetupIntent returns a client secret to initialize SetupIntent on the frontend.
-func (s *paymentMethodService) CreateSetupIntent(ctx context.Context, customerID string, usage stripePkg.SetupIntentUsage) (string, error) {
+func (s *paymentMethodService) CreateSetupIntent(ctx context.Context, customerID string, usage string) (string, error) {
 	si, err := s.stripe.CreateSetupIntent(ctx, customerID, usage)
 	if err != nil {
 		return "", err
@@ -44,38 +47,29 @@ func (s *paymentMethodService) ListByUser(ctx context.Context, userID string) ([
 	return s.repo.ListPaymentMethods(ctx, userID)
 }
 
-// internal/service/PaymentMethodService.go
-func (s *paymentMethodService) RetrieveAndSavePaymentMethod(ctx context.Context, userID, pmID string) (repository.PaymentMethod, error) {
-	log.Printf("🔎 Retrieving card from Stripe: pmID=%s", pmID)
-
-	card, err := s.stripe.RetrieveCard(pmID)
+// RetrievePaymentMethod is split from SavePaymentMethod so that webhook
+// processing can call the gateway before it opens a transaction.
+func (s *paymentMethodService) RetrievePaymentMethod(ctx context.Context, userID, pmID string) (repository.PaymentMethod, error) {
+	card, err := s.stripe.RetrieveCard(ctx, pmID)
 	if err != nil {
-		log.Printf("❌ Failed to retrieve card from Stripe: %v", err)
+		s.log.ErrorContext(ctx, "failed to retrieve card", "payment_method_id", pmID, "error", err)
 		return repository.PaymentMethod{}, err
 	}
-
-	log.Printf("✅ Retrieved card: brand=%s, last4=%s, exp=%02d/%d",
-		card.Card.Brand, card.Card.Last4, card.Card.ExpMonth, card.Card.ExpYear,
-	)
-
-	pm := repository.PaymentMethod{
+	return repository.PaymentMethod{
 		UserID:     userID,
-		StripePMID: card.ID,
-		Brand:      string(card.Card.Brand),
-		Last4:      card.Card.Last4,
-		ExpMonth:   int(card.Card.ExpMonth),
-		ExpYear:    int(card.Card.ExpYear),
+		StripePMID: card.PaymentMethodID,
+		Brand:      card.Brand,
+		Last4:      card.Last4,
+		ExpMonth:   card.ExpMonth,
+		ExpYear:    card.ExpYear,
 		CreatedAt:  time.Now(),
-	}
-
-	log.Printf("💾 Saving card to DB for userID=%s", userID)
+	}, nil
+}
 
+func (s *paymentMethodService) SavePaymentMethod(ctx context.Context, pm repository.PaymentMethod) error {
 	if err := s.repo.SavePaymentMethod(ctx, pm); err != nil {
-		log.Printf("❌ Failed to save card to DB: %v", err)
-		return repository.PaymentMethod{}, err
+		s.log.ErrorContext(ctx, "failed to save card", "user_id", pm.UserID, "payment_method_id", pm.StripePMID, "error", err)
+		return err
 	}
-
-	log.Printf("✅ Card saved successfully for userID=%s", userID)
-
-	return pm, nil
+	return nil
 }
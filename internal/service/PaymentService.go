@@ -2,68 +2,83 @@ package service
 
 import (
 	"context"
-	"github.com/stripe/stripe-go/v74"
-	"github.com/stripe/stripe-go/v74/paymentintent"
+	"database/sql"
+	"errors"
+	"log/slog"
 
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/paymentstate"
 	"Payment-service/internal/repository"
-	"Payment-service/internal/stripeadapter"
 )
 
 // PaymentService defines logic for PaymentIntents: authorize, capture, cancel.
 type PaymentService interface {
-	Authorize(ctx context.Context, customerID, userID, bookingID, currency string, amount int64, paymentMethod string) (clientSecret string, paymentIntentID string, err error)
+	Authorize(ctx context.Context, customerID, userID, bookingID, listingID, currency string, amount int64, paymentMethod string) (clientSecret string, paymentIntentID string, err error)
+	// Get returns a stored payment; ErrNotFound if there is none.
+	Get(ctx context.Context, paymentIntentID string) (repository.PaymentIntent, error)
 	Capture(ctx context.Context, paymentIntentID string) error
 	Cancel(ctx context.Context, paymentIntentID string) error
+	// ApplyGatewayUpdate stores the status reported by the gateway (webhooks,
+	// reconciliation). Updates the state machine rejects as stale are ignored.
+	// Returns ErrNotFound if the PaymentIntent is not a payment of ours.
+	ApplyGatewayUpdate(ctx context.Context, pi gateway.PaymentIntent, source string) error
+	// History returns the status transitions of a payment, oldest first.
+	History(ctx context.Context, paymentIntentID string) ([]repository.StatusChange, error)
 }
 
 // paymentService is a concrete implementation of PaymentService.
 type paymentService struct {
-	repo   repository.PaymentIntentRepo
-	stripe *stripeadapter.Client
+	repo     repository.PaymentIntentRepo
+	history  repository.StatusHistoryRepo
+	uow      repository.UnitOfWork
+	ledger   LedgerService
+	payouts  TransferScheduler
+	hosts    HostResolver
+	bookings BookingResolver
+	stripe   gateway.PaymentGateway
+	log      *slog.Logger
 }
 
-func NewPaymentService(repo repository.PaymentIntentRepo, client *stripeadapter.Client) PaymentService {
-	return &paymentService{repo: repo, stripe: client}
-}
-
-// CreatePaymentIntentRequest holds all input fields for creating an intent.
-type CreatePaymentIntentRequest struct {
-	UserID        string
-	CustomerID    string
-	BookingID     string
-	Amount        int64
-	Currency      string
-	PaymentMethod string // optional
-}
-
-// CreatePaymentIntent returns a Stripe PaymentIntent, confirmed if off_session
-func (s *paymentService) CreatePaymentIntent(ctx context.Context, req CreatePaymentIntentRequest) (*stripe.PaymentIntent, error) {
-	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(req.Amount),
-		Currency: stripe.String(req.Currency),
-		Customer: stripe.String(req.CustomerID),
-	}
-	params.AddMetadata("user_id", req.UserID)
-	params.AddMetadata("booking_id", req.BookingID)
-
-	if req.PaymentMethod != "" {
-		params.PaymentMethod = stripe.String(req.PaymentMethod)
-		params.Confirm = stripe.Bool(true)
-		params.OffSession = stripe.Bool(true)
+// NewPaymentService constructs a PaymentService. hosts may be nil: the host
+// of the listing is then unknown and host payouts are booked to a shared account.
+// bookings may be nil: the listing given by the caller is then not checked
+// against the booking.
+func NewPaymentService(
+	repo repository.PaymentIntentRepo,
+	history repository.StatusHistoryRepo,
+	uow repository.UnitOfWork,
+	ledger LedgerService,
+	payouts TransferScheduler,
+	hosts HostResolver,
+	bookings BookingResolver,
+	client gateway.PaymentGateway,
+	logger *slog.Logger,
+) PaymentService {
+	return &paymentService{
+		repo: repo, history: history, uow: uow, ledger: ledger, payouts: payouts, hosts: hosts,
+		bookings: bookings, stripe: client, log: logger,
 	}
-
-	return paymentintent.New(params)
 }
 
 // Authorize creates a PaymentIntent (with or without saved card) and stores it.
-func (s *paymentService) Authorize(ctx context.Context, customerID, userID, bookingID, currency string, amount int64, paymentMethod string) (string, string, error) {
-	pi, err := s.CreatePaymentIntent(ctx, CreatePaymentIntentRequest{
-		UserID:        userID,
-		CustomerID:    customerID,
-		BookingID:     bookingID,
-		Amount:        amount,
-		Currency:      currency,
-		PaymentMethod: paymentMethod,
+// With a saved card the intent is confirmed off-session right away.
+func (s *paymentService) Authorize(ctx context.Context, customerID, userID, bookingID, listingID, currency string, amount int64, paymentMethod string) (string, string, error) {
+	listingID, err := resolveListing(ctx, s.bookings, bookingID, userID, listingID)
+	if err != nil {
+		return "", "", err
+	}
+	hostID, err := resolveHost(ctx, s.hosts, listingID)
+	if err != nil {
+		return "", "", err
+	}
+	pi, err := s.stripe.CreatePaymentIntent(ctx, gateway.CreatePaymentIntentParams{
+		CustomerID:      customerID,
+		Amount:          amount,
+		Currency:        currency,
+		BookingID:       bookingID,
+		UserID:          userID,
+		ListingID:       listingID,
+		PaymentMethodID: paymentMethod,
 	})
 	if err != nil {
 		return "", "", err
@@ -72,33 +87,130 @@ func (s *paymentService) Authorize(ctx context.Context, customerID, userID, book
 	intent := repository.PaymentIntent{
 		StripePIID: pi.ID,
 		BookingID:  bookingID,
+		ListingID:  listingID,
 		UserID:     userID,
+		HostID:     hostID,
 		Amount:     amount,
 		Currency:   currency,
-		Status:     string(pi.Status),
+		Status:     pi.Status,
 	}
 
-	if err := s.repo.CreatePaymentIntent(ctx, intent); err != nil {
+	err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreatePaymentIntent(ctx, intent); err != nil {
+			return err
+		}
+		return s.record(ctx, paymentSubject(intent), intent.Status)
+	})
+	if err != nil {
 		return "", "", err
 	}
+	s.log.InfoContext(ctx, "payment authorized",
+		"payment_intent_id", pi.ID, "booking_id", bookingID, "user_id", userID,
+		"amount", amount, "currency", currency, "status", pi.Status)
 
 	return pi.ClientSecret, pi.ID, nil
 }
 
 // Capture charges a previously authorized PaymentIntent.
 func (s *paymentService) Capture(ctx context.Context, paymentIntentID string) error {
-	pi, err := s.stripe.CapturePaymentIntent(ctx, paymentIntentID)
+	current, err := s.get(ctx, paymentIntentID)
+	if err != nil {
+		return err
+	}
+	if current.Status != gateway.StatusRequiresCapture {
+		return invalidTransition(current.Status, gateway.StatusSucceeded)
+	}
+	pi, err := s.stripe.CapturePaymentIntent(ctx, paymentIntentID, 0)
 	if err != nil {
 		return err
 	}
-	return s.repo.UpdatePaymentIntentStatus(ctx, pi.ID, string(pi.Status))
+	return s.transition(ctx, pi.ID, pi.Status, repository.StatusSourceAPI)
 }
 
 // Cancel releases a hold without charging.
 func (s *paymentService) Cancel(ctx context.Context, paymentIntentID string) error {
+	current, err := s.get(ctx, paymentIntentID)
+	if err != nil {
+		return err
+	}
+	if !paymentstate.CanTransition(current.Status, gateway.StatusCanceled) {
+		return invalidTransition(current.Status, gateway.StatusCanceled)
+	}
 	pi, err := s.stripe.CancelPaymentIntent(ctx, paymentIntentID)
 	if err != nil {
 		return err
 	}
-	return s.repo.UpdatePaymentIntentStatus(ctx, pi.ID, string(pi.Status))
+	return s.transition(ctx, pi.ID, pi.Status, repository.StatusSourceAPI)
+}
+
+func (s *paymentService) ApplyGatewayUpdate(ctx context.Context, pi gateway.PaymentIntent, source string) error {
+	err := s.transition(ctx, pi.ID, pi.Status, source)
+	if errors.Is(err, ErrInvalidState) {
+		logStaleUpdate(ctx, s.log, "payment", pi.ID, source, err)
+		return nil
+	}
+	return err
+}
+
+func (s *paymentService) Get(ctx context.Context, paymentIntentID string) (repository.PaymentIntent, error) {
+	return s.get(ctx, paymentIntentID)
+}
+
+func (s *paymentService) History(ctx context.Context, paymentIntentID string) ([]repository.StatusChange, error) {
+	if _, err := s.get(ctx, paymentIntentID); err != nil {
+		return nil, err
+	}
+	return s.history.ListStatusHistory(ctx, paymentIntentID, repository.RefundKindPayment)
+}
+
+func (s *paymentService) get(ctx context.Context, paymentIntentID string) (repository.PaymentIntent, error) {
+	pi, err := s.repo.GetPaymentIntentByID(ctx, paymentIntentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.PaymentIntent{}, ErrNotFound
+	}
+	return pi, err
+}
+
+// transition moves the payment to status to if the state machine allows it,
+// re-reading the current status when a concurrent update wins the race.
+// The status change, its ledger entries and the host transfer are committed together.
+func (s *paymentService) transition(ctx context.Context, paymentIntentID, to, source string) error {
+	for attempt := 0; attempt < maxTransitionAttempts; attempt++ {
+		current, err := s.get(ctx, paymentIntentID)
+		if err != nil {
+			return err
+		}
+		if current.Status == to {
+			return nil
+		}
+		if !paymentstate.CanTransition(current.Status, to) {
+			return invalidTransition(current.Status, to)
+		}
+		err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+			if err := s.repo.UpdatePaymentIntentStatus(ctx, paymentIntentID, current.Status, to, source); err != nil {
+				return err
+			}
+			return s.record(ctx, paymentSubject(current), to)
+		})
+		if err == nil {
+			s.log.InfoContext(ctx, "payment status changed",
+				"payment_intent_id", paymentIntentID, "from", current.Status, "to", to, "source", source)
+		}
+		if !errors.Is(err, repository.ErrStatusChanged) {
+			return err
+		}
+	}
+	return repository.ErrStatusChanged
+}
+
+// record books a status change in the ledger and, once the payment is
+// captured, queues the host's share for transfer.
+func (s *paymentService) record(ctx context.Context, subj LedgerSubject, status string) error {
+	if err := s.ledger.RecordStatus(ctx, subj, status); err != nil {
+		return err
+	}
+	if status != gateway.StatusSucceeded {
+		return nil
+	}
+	return s.payouts.ScheduleTransfer(ctx, subj)
 }
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"Payment-service/internal/bookingclient"
+	"Payment-service/internal/fakegateway"
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+)
+
+func newTestPaymentService(t *testing.T) (PaymentService, *memStore, *fakegateway.Gateway, string) {
+	t.Helper()
+	store := newMemStore()
+	gw := fakegateway.New("", "")
+	customerID, err := gw.CreateCustomer(context.Background(), "guest@example.com", "user-1")
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	svc := NewPaymentService(store, store, store, nopLedger{}, nopPayouts{}, nil, nil, gw, discardLogger())
+	return svc, store, gw, customerID
+}
+
+func TestPaymentService_AuthorizeConfirmAndSync(t *testing.T) {
+	ctx := context.Background()
+	svc, _, gw, customerID := newTestPaymentService(t)
+
+	secret, id, err := svc.Authorize(ctx, customerID, "user-1", "booking-1", "", "USD", 12000, "")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if secret == "" {
+		t.Error("Authorize returned an empty client secret")
+	}
+	stored, err := svc.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if stored.Status != gateway.StatusRequiresPaymentMethod {
+		t.Fatalf("status after Authorize = %s, want %s", stored.Status, gateway.StatusRequiresPaymentMethod)
+	}
+
+	// Stripe.js подтверждает платёж, статус приходит webhook'ом
+	pi, err := gw.ConfirmPaymentIntent(ctx, id, fakegateway.CardVisa)
+	if err != nil {
+		t.Fatalf("ConfirmPaymentIntent: %v", err)
+	}
+	if err := svc.ApplyGatewayUpdate(ctx, *pi, repository.StatusSourceWebhook); err != nil {
+		t.Fatalf("ApplyGatewayUpdate: %v", err)
+	}
+	stored, _ = svc.Get(ctx, id)
+	if stored.Status != gateway.StatusSucceeded {
+		t.Fatalf("status after confirmation = %s, want %s", stored.Status, gateway.StatusSucceeded)
+	}
+
+	if err := svc.Cancel(ctx, id); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Cancel of a succeeded payment: err = %v, want ErrInvalidState", err)
+	}
+	history, err := svc.History(ctx, id)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(history) != 2 || history[1].Source != repository.StatusSourceWebhook {
+		t.Errorf("history = %+v, want creation and one webhook transition", history)
+	}
+}
+
+func TestPaymentService_DeclinedCardKeepsPaymentOpen(t *testing.T) {
+	ctx := context.Background()
+	svc, _, gw, customerID := newTestPaymentService(t)
+
+	_, id, err := svc.Authorize(ctx, customerID, "user-1", "booking-1", "", "usd", 5000, "")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	_, err = gw.ConfirmPaymentIntent(ctx, id, fakegateway.CardDeclined)
+	var decline *fakegateway.DeclineError
+	if !errors.As(err, &decline) {
+		t.Fatalf("ConfirmPaymentIntent with a declined card: err = %v, want DeclineError", err)
+	}
+	pi, err := gw.GetPaymentIntent(ctx, id)
+	if err != nil {
+		t.Fatalf("GetPaymentIntent: %v", err)
+	}
+	if err := svc.ApplyGatewayUpdate(ctx, *pi, repository.StatusSourceWebhook); err != nil {
+		t.Fatalf("ApplyGatewayUpdate: %v", err)
+	}
+	stored, _ := svc.Get(ctx, id)
+	if stored.Status != gateway.StatusRequiresPaymentMethod {
+		t.Errorf("status after decline = %s, want %s", stored.Status, gateway.StatusRequiresPaymentMethod)
+	}
+	if err := svc.Capture(ctx, id); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Capture of an unauthorized payment: err = %v, want ErrInvalidState", err)
+	}
+}
+
+// fakeBookings — Booking-service с заранее заданными бронями
+type fakeBookings map[string]bookingclient.Booking
+
+func (f fakeBookings) GetByID(ctx context.Context, bookingID string) (bookingclient.Booking, error) {
+	b, ok := f[bookingID]
+	if !ok {
+		return bookingclient.Booking{}, bookingclient.ErrNotFound
+	}
+	return b, nil
+}
+
+func TestPaymentService_ListingComesFromBooking(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	gw := fakegateway.New("", "")
+	customerID, err := gw.CreateCustomer(ctx, "guest@example.com", "user-1")
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	bookings := fakeBookings{"booking-1": {ID: "booking-1", ListingID: "listing-1", UserID: "user-1"}}
+	svc := NewPaymentService(store, store, store, nopLedger{}, nopPayouts{}, nil, bookings, gw, discardLogger())
+
+	_, id, err := svc.Authorize(ctx, customerID, "user-1", "booking-1", "", "usd", 5000, "")
+	if err != nil {
+		t.Fatalf("Authorize without listing: %v", err)
+	}
+	if stored, _ := svc.Get(ctx, id); stored.ListingID != "listing-1" {
+		t.Errorf("listing = %q, want listing-1 from the booking", stored.ListingID)
+	}
+
+	tests := []struct {
+		name, userID, bookingID, listingID string
+	}{
+		{"foreign listing", "user-1", "booking-1", "listing-2"},
+		{"foreign booking", "user-2", "booking-1", ""},
+		{"unknown booking", "user-1", "booking-2", ""},
+	}
+	for _, tt := range tests {
+		_, _, err := svc.Authorize(ctx, customerID, tt.userID, tt.bookingID, tt.listingID, "usd", 5000, "")
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: err = %v, want ErrInvalidInput", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/paymentstate"
+	"Payment-service/internal/repository"
+)
+
+// ReconcileService compares payments and deposits with the gateway and
+// repairs drift left by lost webhooks.
+type ReconcileService interface {
+	// Reconcile checks every PaymentIntent created since the given time on
+	// either side, repairs drift through the normal status transitions and
+	// stores the report. trigger is repository.ReconcileTriggerSchedule or
+	// repository.ReconcileTriggerManual.
+	Reconcile(ctx context.Context, since time.Time, trigger string) (repository.ReconciliationReport, error)
+	// Reports returns the latest reports without items, newest first.
+	Reports(ctx context.Context, limit int) ([]repository.ReconciliationReport, error)
+	// Report returns a report with its items; ErrNotFound if there is none.
+	Report(ctx context.Context, id int64) (repository.ReconciliationReport, error)
+}
+
+// reconcileService is a concrete implementation of ReconcileService.
+type reconcileService struct {
+	repo     repository.ReconciliationRepo
+	payments PaymentService
+	deposits DepositService
+	stripe   gateway.PaymentGateway
+	log      *slog.Logger
+}
+
+// NewReconcileService constructs a ReconcileService.
+func NewReconcileService(
+	repo repository.ReconciliationRepo,
+	payments PaymentService,
+	deposits DepositService,
+	client gateway.PaymentGateway,
+	logger *slog.Logger,
+) ReconcileService {
+	return &reconcileService{repo: repo, payments: payments, deposits: deposits, stripe: client, log: logger}
+}
+
+func (s *reconcileService) Reconcile(ctx context.Context, since time.Time, trigger string) (repository.ReconciliationReport, error) {
+	report := repository.ReconciliationReport{TriggeredBy: trigger, Since: since, StartedAt: time.Now()}
+
+	remote, err := s.stripe.ListPaymentIntents(ctx, since)
+	if err != nil {
+		return report, fmt.Errorf("list gateway payment intents: %w", err)
+	}
+	local, err := s.repo.ListReconcileRecords(ctx, since)
+	if err != nil {
+		return report, fmt.Errorf("list local payment intents: %w", err)
+	}
+	byID := make(map[string]repository.ReconcileRecord, len(local))
+	for _, rec := range local {
+		byID[rec.StripePIID] = rec
+	}
+
+	seen := make(map[string]bool, len(remote))
+	for _, pi := range remote {
+		seen[pi.ID] = true
+		rec, ok := byID[pi.ID]
+		if !ok {
+			// Our row may fall just outside the window
+			rec, err = s.repo.GetReconcileRecord(ctx, pi.ID)
+			if errors.Is(err, sql.ErrNoRows) {
+				// Intents without booking metadata were not created by this service
+				if pi.Metadata["booking_id"] != "" {
+					s.add(&report, repository.ReconciliationItem{
+						StripePIID:   pi.ID,
+						Result:       repository.ReconcileMissingLocally,
+						RemoteStatus: pi.Status,
+						Detail:       "booking " + pi.Metadata["booking_id"],
+					})
+				}
+				continue
+			}
+			if err != nil {
+				return report, err
+			}
+		}
+		s.compare(ctx, &report, rec, pi)
+	}
+
+	// Rows the listing did not return are checked one by one
+	for _, rec := range local {
+		if seen[rec.StripePIID] {
+			continue
+		}
+		pi, err := s.stripe.GetPaymentIntent(ctx, rec.StripePIID)
+		if errors.Is(err, gateway.ErrNotFound) {
+			s.add(&report, repository.ReconciliationItem{
+				StripePIID:  rec.StripePIID,
+				Kind:        rec.Kind,
+				Result:      repository.ReconcileMissingRemotely,
+				LocalStatus: rec.Status,
+			})
+			continue
+		}
+		if err != nil {
+			s.add(&report, repository.ReconciliationItem{
+				StripePIID:  rec.StripePIID,
+				Kind:        rec.Kind,
+				Result:      repository.ReconcileUnresolved,
+				LocalStatus: rec.Status,
+				Detail:      "retrieve: " + err.Error(),
+			})
+			continue
+		}
+		s.compare(ctx, &report, rec, *pi)
+	}
+
+	report.FinishedAt = time.Now()
+	report.ID, err = s.repo.CreateReconciliationReport(ctx, report)
+	if err != nil {
+		return report, fmt.Errorf("save reconciliation report: %w", err)
+	}
+	s.log.InfoContext(ctx, "reconciliation finished",
+		"report_id", report.ID, "trigger", trigger, "since", since,
+		"matched", report.Matched, "repaired", report.Repaired,
+		"missing_locally", report.MissingLocally, "missing_remotely", report.MissingRemotely,
+		"unresolved", report.Unresolved, "duration", report.FinishedAt.Sub(report.StartedAt))
+	return report, nil
+}
+
+// compare repairs a drifted record through ApplyGatewayUpdate and checks
+// the result: the state machine may reject the gateway's status.
+func (s *reconcileService) compare(ctx context.Context, report *repository.ReconciliationReport, rec repository.ReconcileRecord, pi gateway.PaymentIntent) {
+	diff := drift(rec, pi)
+	if diff == "" {
+		s.add(report, repository.ReconciliationItem{Result: repository.ReconcileMatched})
+		return
+	}
+	item := repository.ReconciliationItem{
+		StripePIID:   rec.StripePIID,
+		Kind:         rec.Kind,
+		Result:       repository.ReconcileUnresolved,
+		LocalStatus:  rec.Status,
+		RemoteStatus: pi.Status,
+		Detail:       diff,
+	}
+
+	var err error
+	if rec.Kind == repository.RefundKindDeposit {
+		err = s.deposits.ApplyGatewayUpdate(ctx, s.deposits.PrepareGatewayUpdate(ctx, pi), repository.StatusSourceReconciler)
+	} else {
+		err = s.payments.ApplyGatewayUpdate(ctx, pi, repository.StatusSourceReconciler)
+	}
+	if err != nil {
+		item.Detail += "; repair: " + err.Error()
+		s.add(report, item)
+		return
+	}
+	after, err := s.repo.GetReconcileRecord(ctx, rec.StripePIID)
+	if err != nil {
+		item.Detail += "; reload: " + err.Error()
+	} else if drift(after, pi) == "" {
+		item.Result = repository.ReconcileRepaired
+	}
+	s.log.WarnContext(ctx, "payment drift found",
+		"payment_intent_id", rec.StripePIID, "kind", rec.Kind, "result", item.Result, "drift", diff)
+	s.add(report, item)
+}
+
+// add counts an item; only discrepancies are kept in the report.
+func (s *reconcileService) add(report *repository.ReconciliationReport, item repository.ReconciliationItem) {
+	reconciliationResults.WithLabelValues(item.Result).Inc()
+	switch item.Result {
+	case repository.ReconcileMatched:
+		report.Matched++
+		return
+	case repository.ReconcileRepaired:
+		report.Repaired++
+	case repository.ReconcileMissingLocally:
+		report.MissingLocally++
+	case repository.ReconcileMissingRemotely:
+		report.MissingRemotely++
+	default:
+		report.Unresolved++
+	}
+	report.Items = append(report.Items, item)
+}
+
+// drift describes how a stored record differs from the gateway; "" if it does not.
+func drift(rec repository.ReconcileRecord, pi gateway.PaymentIntent) string {
+	var diffs []string
+	remote := pi.Status
+	if rec.Kind == repository.RefundKindDeposit {
+		remote = depositStatus(pi)
+	}
+	// A hold released by the expiry scheduler is canceled in Stripe without a reason
+	expired := rec.Status == paymentstate.StatusExpired && remote == gateway.StatusCanceled
+	if rec.Status != remote && !expired {
+		diffs = append(diffs, fmt.Sprintf("status %s, gateway %s", rec.Status, remote))
+	}
+	if rec.Amount != pi.Amount {
+		diffs = append(diffs, fmt.Sprintf("amount %d, gateway %d", rec.Amount, pi.Amount))
+	}
+	if rec.Kind == repository.RefundKindDeposit && pi.Status == gateway.StatusSucceeded &&
+		pi.AmountReceived > 0 && rec.CapturedAmount != pi.AmountReceived {
+		diffs = append(diffs, fmt.Sprintf("captured %d, gateway %d", rec.CapturedAmount, pi.AmountReceived))
+	}
+	return strings.Join(diffs, "; ")
+}
+
+func (s *reconcileService) Reports(ctx context.Context, limit int) ([]repository.ReconciliationReport, error) {
+	return s.repo.ListReconciliationReports(ctx, limit)
+}
+
+func (s *reconcileService) Report(ctx context.Context, id int64) (repository.ReconciliationReport, error) {
+	r, err := s.repo.GetReconciliationReport(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ReconciliationReport{}, ErrNotFound
+	}
+	return r, err
+}
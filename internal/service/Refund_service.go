@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+)
+
+// RefundService defines logic for refunding captured payments and deposits.
+type RefundService interface {
+	// RefundPayment refunds a captured PaymentIntent. A zero amount refunds the remainder.
+	RefundPayment(ctx context.Context, paymentIntentID string, amount int64, reason string) (repository.Refund, error)
+	// RefundDeposit refunds a captured deposit. A zero amount refunds the remainder.
+	RefundDeposit(ctx context.Context, depositID string, amount int64, reason string) (repository.Refund, error)
+	// ListRefunds returns refunds recorded for a PaymentIntent or deposit.
+	ListRefunds(ctx context.Context, paymentIntentID string) ([]repository.Refund, error)
+	// SyncGatewayRefund stores a refund state reported by the gateway (webhooks).
+	SyncGatewayRefund(ctx context.Context, r gateway.Refund) error
+}
+
+// refundService is a concrete implementation of RefundService.
+type refundService struct {
+	repo     repository.RefundRepo
+	payments repository.PaymentIntentRepo
+	deposits repository.DepositRepo
+	uow      repository.UnitOfWork
+	ledger   LedgerService
+	payouts  TransferScheduler
+	stripe   gateway.PaymentGateway
+	log      *slog.Logger
+}
+
+// NewRefundService constructs a RefundService. payouts adjusts the host's
+// transfer to what is left of their share after the refund.
+func NewRefundService(
+	repo repository.RefundRepo,
+	payments repository.PaymentIntentRepo,
+	deposits repository.DepositRepo,
+	uow repository.UnitOfWork,
+	ledger LedgerService,
+	payouts TransferScheduler,
+	client gateway.PaymentGateway,
+	logger *slog.Logger,
+) RefundService {
+	return &refundService{
+		repo: repo, payments: payments, deposits: deposits, uow: uow, ledger: ledger, payouts: payouts,
+		stripe: client, log: logger,
+	}
+}
+
+func (s *refundService) RefundPayment(ctx context.Context, paymentIntentID string, amount int64, reason string) (repository.Refund, error) {
+	pi, err := s.payments.GetPaymentIntentByID(ctx, paymentIntentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.Refund{}, ErrNotFound
+	}
+	if err != nil {
+		return repository.Refund{}, err
+	}
+	if pi.Status != gateway.StatusSucceeded {
+		return repository.Refund{}, ErrNotCaptured
+	}
+	return s.refund(ctx, paymentSubject(pi), amount, reason)
+}
+
+func (s *refundService) RefundDeposit(ctx context.Context, depositID string, amount int64, reason string) (repository.Refund, error) {
+	d, err := s.deposits.GetDepositByID(ctx, depositID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.Refund{}, ErrNotFound
+	}
+	if err != nil {
+		return repository.Refund{}, err
+	}
+	if d.Status != gateway.StatusSucceeded {
+		return repository.Refund{}, ErrNotCaptured
+	}
+	return s.refund(ctx, depositSubject(d), amount, reason)
+}
+
+// refund reserves the amount locally first, so concurrent requests cannot
+// over-refund, and only then asks the gateway to move the money.
+func (s *refundService) refund(ctx context.Context, subj LedgerSubject, amount int64, reason string) (repository.Refund, error) {
+	kind, paymentIntentID, currency, captured := subj.Kind, subj.PaymentIntentID, subj.Currency, subj.Captured
+	if amount < 0 {
+		return repository.Refund{}, ErrInvalidAmount
+	}
+	if amount == 0 {
+		refunded, err := s.repo.SumActiveRefunds(ctx, paymentIntentID)
+		if err != nil {
+			return repository.Refund{}, err
+		}
+		amount = captured - refunded
+		if amount <= 0 {
+			return repository.Refund{}, ErrRefundExceedsCaptured
+		}
+	}
+
+	reserved, err := s.repo.ReserveRefund(ctx, repository.Refund{
+		StripePIID: paymentIntentID,
+		Kind:       kind,
+		Amount:     amount,
+		Currency:   currency,
+		Reason:     reason,
+		Status:     gateway.RefundStatusPending,
+	}, captured)
+	if errors.Is(err, repository.ErrRefundLimitExceeded) {
+		return repository.Refund{}, ErrRefundExceedsCaptured
+	}
+	if err != nil {
+		return repository.Refund{}, err
+	}
+
+	r, err := s.stripe.CreateRefund(ctx, gateway.RefundParams{
+		PaymentIntentID: paymentIntentID,
+		Amount:          amount,
+		Reason:          reason,
+		ReferenceID:     strconv.FormatInt(reserved.ID, 10),
+	})
+	if err != nil {
+		s.log.WarnContext(ctx, "gateway refund failed",
+			"payment_intent_id", paymentIntentID, "refund_id", reserved.ID, "amount", amount, "error", err)
+		// Освобождаем зарезервированную сумму
+		if markErr := s.repo.CompleteRefund(ctx, reserved.ID, "", gateway.RefundStatusFailed); markErr != nil {
+			return repository.Refund{}, errors.Join(err, markErr)
+		}
+		return repository.Refund{}, err
+	}
+
+	reserved.StripeRefundID = r.ID
+	reserved.Status = r.Status
+	err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CompleteRefund(ctx, reserved.ID, r.ID, r.Status); err != nil {
+			return err
+		}
+		return s.record(ctx, subj, reserved)
+	})
+	if err != nil {
+		return repository.Refund{}, err
+	}
+	s.log.InfoContext(ctx, "refund created",
+		"payment_intent_id", paymentIntentID, "refund_id", reserved.ID, "stripe_refund_id", r.ID,
+		"kind", kind, "amount", amount, "currency", currency, "status", r.Status)
+	return reserved, nil
+}
+
+func (s *refundService) ListRefunds(ctx context.Context, paymentIntentID string) ([]repository.Refund, error) {
+	return s.repo.ListRefundsByPaymentIntent(ctx, paymentIntentID)
+}
+
+// SyncGatewayRefund matches the refund by our own ID from metadata when
+// possible; refunds created elsewhere (e.g. Stripe Dashboard) are inserted.
+// The ledger follows the refund status in the same transaction.
+func (s *refundService) SyncGatewayRefund(ctx context.Context, r gateway.Refund) error {
+	subj, known, err := s.subject(ctx, r.PaymentIntentID)
+	if err != nil {
+		return err
+	}
+	refund := repository.Refund{
+		StripeRefundID: r.ID,
+		StripePIID:     r.PaymentIntentID,
+		Kind:           subj.Kind,
+		Amount:         r.Amount,
+		Currency:       r.Currency,
+		Reason:         r.Reason,
+		Status:         r.Status,
+	}
+	return s.uow.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if id, parseErr := strconv.ParseInt(r.ReferenceID, 10, 64); parseErr == nil {
+			err = s.repo.CompleteRefund(ctx, id, r.ID, r.Status)
+		} else {
+			err = s.repo.UpsertRefundByStripeID(ctx, refund)
+		}
+		if err != nil {
+			return err
+		}
+		if !known {
+			return nil
+		}
+		return s.record(ctx, subj, refund)
+	})
+}
+
+// record books the refund in the ledger and moves the host's transfer to
+// their remaining share: a failed refund gives the share back.
+func (s *refundService) record(ctx context.Context, subj LedgerSubject, r repository.Refund) error {
+	if err := s.ledger.RecordRefund(ctx, subj, r); err != nil {
+		return err
+	}
+	return s.payouts.ScheduleTransfer(ctx, subj)
+}
+
+// subject finds the payment or deposit a refund belongs to. known is false
+// when the PaymentIntent is neither; such refunds are not booked.
+func (s *refundService) subject(ctx context.Context, paymentIntentID string) (subj LedgerSubject, known bool, err error) {
+	d, err := s.deposits.GetDepositByID(ctx, paymentIntentID)
+	if err == nil {
+		return depositSubject(d), true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return LedgerSubject{}, false, err
+	}
+	pi, err := s.payments.GetPaymentIntentByID(ctx, paymentIntentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LedgerSubject{Kind: repository.RefundKindPayment, PaymentIntentID: paymentIntentID}, false, nil
+	}
+	if err != nil {
+		return LedgerSubject{}, false, err
+	}
+	return paymentSubject(pi), true, nil
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/logging"
+	"Payment-service/internal/repository"
+	"Payment-service/internal/stripeadapter"
+
+	"github.com/stripe/stripe-go/v74"
+)
+
+const (
+	// eventProcessingLease keeps the retry worker away from an event that is
+	// being processed inline by the webhook handler.
+	eventProcessingLease = 2 * time.Minute
+	// eventRetryBase is the delay before the first retry; it doubles each attempt.
+	eventRetryBase = 30 * time.Second
+	// eventRetryMaxDelay caps the exponential backoff.
+	eventRetryMaxDelay = 6 * time.Hour
+	// eventMaxAttempts is how many times an event is tried before giving up.
+	eventMaxAttempts = 10
+)
+
+// StripeEventService stores verified Stripe events and applies them exactly once.
+type StripeEventService interface {
+	// Receive stores the event and processes it unless it was already received.
+	// Processing failures are recorded for retry and are not returned.
+	Receive(ctx context.Context, event stripe.Event, payload []byte) (duplicate bool, err error)
+	// Replay processes a stored event again, regardless of its previous outcome.
+	Replay(ctx context.Context, eventID string) error
+	// RetryDue processes up to limit failed events whose backoff has elapsed.
+	RetryDue(ctx context.Context, limit int) (int, error)
+	// ListEvents returns recently received events.
+	ListEvents(ctx context.Context, failedOnly bool, limit int) ([]repository.StripeEvent, error)
+}
+
+// stripeEventService is a concrete implementation of StripeEventService.
+type stripeEventService struct {
+	repo           repository.StripeEventRepo
+	uow            repository.UnitOfWork
+	pmService      PaymentMethodService
+	paymentService PaymentService
+	depositService DepositService
+	refundService  RefundService
+	connectService ConnectService
+	log            *slog.Logger
+}
+
+// NewStripeEventService constructs a StripeEventService.
+func NewStripeEventService(
+	repo repository.StripeEventRepo,
+	uow repository.UnitOfWork,
+	pmSvc PaymentMethodService,
+	paySvc PaymentService,
+	depSvc DepositService,
+	refundSvc RefundService,
+	connectSvc ConnectService,
+	logger *slog.Logger,
+) StripeEventService {
+	return &stripeEventService{
+		repo:           repo,
+		uow:            uow,
+		pmService:      pmSvc,
+		paymentService: paySvc,
+		depositService: depSvc,
+		refundService:  refundSvc,
+		connectService: connectSvc,
+		log:            logger,
+	}
+}
+
+func (s *stripeEventService) Receive(ctx context.Context, event stripe.Event, payload []byte) (bool, error) {
+	inserted, err := s.repo.SaveStripeEvent(ctx, repository.StripeEvent{
+		ID:      event.ID,
+		Type:    string(event.Type),
+		Payload: payload,
+	}, eventProcessingLease)
+	if err != nil {
+		return false, err
+	}
+	if !inserted {
+		stripeWebhookEvents.WithLabelValues(string(event.Type), webhookDuplicate).Inc()
+		return true, nil
+	}
+	stripeWebhookEvents.WithLabelValues(string(event.Type), webhookReceived).Inc()
+	s.apply(ctx, event.ID, event, 1)
+	return false, nil
+}
+
+func (s *stripeEventService) Replay(ctx context.Context, eventID string) error {
+	stored, err := s.repo.GetStripeEvent(ctx, eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	event, err := decodeEvent(stored.Payload)
+	if err != nil {
+		return err
+	}
+	ctx = logging.With(ctx, "stripe_event_id", eventID, "stripe_event_type", event.Type)
+	if err := s.processAndMark(ctx, eventID, event); err != nil {
+		if markErr := s.repo.MarkStripeEventFailed(ctx, eventID, err.Error(), nil); markErr != nil {
+			return errors.Join(err, markErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *stripeEventService) RetryDue(ctx context.Context, limit int) (int, error) {
+	due, err := s.repo.ClaimDueStripeEvents(ctx, limit, eventProcessingLease)
+	if err != nil {
+		return 0, err
+	}
+	for _, stored := range due {
+		event, err := decodeEvent(stored.Payload)
+		if err != nil {
+			// Битый payload повторами не починить
+			if markErr := s.repo.MarkStripeEventFailed(ctx, stored.ID, err.Error(), nil); markErr != nil {
+				s.log.ErrorContext(ctx, "failed to mark stripe event", "stripe_event_id", stored.ID, "error", markErr)
+			}
+			continue
+		}
+		s.apply(ctx, stored.ID, event, stored.Attempts)
+	}
+	return len(due), nil
+}
+
+func (s *stripeEventService) ListEvents(ctx context.Context, failedOnly bool, limit int) ([]repository.StripeEvent, error) {
+	return s.repo.ListStripeEvents(ctx, failedOnly, limit)
+}
+
+// apply processes the event and records the outcome, scheduling a retry with
+// exponential backoff on failure.
+func (s *stripeEventService) apply(ctx context.Context, eventID string, event stripe.Event, attempt int) {
+	ctx = logging.With(ctx, "stripe_event_id", eventID, "stripe_event_type", event.Type)
+	if err := s.processAndMark(ctx, eventID, event); err != nil {
+		s.log.WarnContext(ctx, "stripe event processing failed", "attempt", attempt, "error", err)
+		var next *time.Time
+		if attempt < eventMaxAttempts {
+			at := time.Now().Add(backoff(eventRetryBase, eventRetryMaxDelay, attempt))
+			next = &at
+		}
+		if markErr := s.repo.MarkStripeEventFailed(ctx, eventID, err.Error(), next); markErr != nil {
+			s.log.ErrorContext(ctx, "failed to mark stripe event", "error", markErr)
+		}
+	}
+}
+
+// processAndMark applies the event and marks it processed in one transaction,
+// so a failure leaves neither partial effects nor a processed mark behind.
+// Gateway calls are made before the transaction is opened: it only holds a
+// connection for the database writes, and nothing remote needs rolling back.
+func (s *stripeEventService) processAndMark(ctx context.Context, eventID string, event stripe.Event) error {
+	apply, err := s.process(ctx, event)
+	if err == nil {
+		err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+			if err := apply(ctx); err != nil {
+				return err
+			}
+			return s.repo.MarkStripeEventProcessed(ctx, eventID)
+		})
+	}
+	result := webhookProcessed
+	if err != nil {
+		result = webhookFailed
+	}
+	stripeWebhookEvents.WithLabelValues(string(event.Type), result).Inc()
+	return err
+}
+
+// backoff returns base doubled for every attempt after the first, capped at max.
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// syncPaymentIntent applies the PaymentIntent state to the deposits or
+// payment_intents row that tracks it.
+func (s *stripeEventService) syncPaymentIntent(ctx context.Context, pi *gateway.PaymentIntent) error {
+	err := s.depositService.ApplyGatewayUpdate(ctx, *pi, repository.StatusSourceWebhook)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	err = s.paymentService.ApplyGatewayUpdate(ctx, *pi, repository.StatusSourceWebhook)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if pi.Metadata["booking_id"] == "" && pi.Metadata["user_id"] == "" {
+		// Не наш PaymentIntent (создан вне сервиса)
+		s.log.InfoContext(ctx, "ignoring unknown payment intent", "payment_intent_id", pi.ID)
+		return nil
+	}
+	// Событие обогнало запись в БД — повторим позже
+	return fmt.Errorf("payment intent %s: %w", pi.ID, err)
+}
+
+func decodeEvent(payload []byte) (stripe.Event, error) {
+	var event stripe.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return stripe.Event{}, fmt.Errorf("decode stored event: %w", err)
+	}
+	return event, nil
+}
+
+// storeFunc writes the effects of an event; it runs inside the transaction.
+type storeFunc func(ctx context.Context) error
+
+// noop is the storeFunc of events that change nothing.
+func noop(context.Context) error { return nil }
+
+// process parses the event and fetches what it needs from the gateway. The
+// returned storeFunc only writes to the database. Returned errors are retried.
+func (s *stripeEventService) process(ctx context.Context, event stripe.Event) (storeFunc, error) {
+	switch event.Type {
+	case "setup_intent.succeeded":
+		var si stripe.SetupIntent
+		if err := json.Unmarshal(event.Data.Raw, &si); err != nil {
+			return nil, fmt.Errorf("parse setup_intent: %w", err)
+		}
+
+		userID := si.Metadata["user_id"]
+		if userID == "" || si.PaymentMethod == nil || si.PaymentMethod.ID == "" {
+			s.log.WarnContext(ctx, "setup_intent without user_id or payment method", "setup_intent_id", si.ID)
+			return noop, nil
+		}
+		pm, err := s.pmService.RetrievePaymentMethod(ctx, userID, si.PaymentMethod.ID)
+		if err != nil {
+			return nil, fmt.Errorf("retrieve card for user %s: %w", userID, err)
+		}
+		return func(ctx context.Context) error {
+			if err := s.pmService.SavePaymentMethod(ctx, pm); err != nil {
+				return fmt.Errorf("save card for user %s: %w", userID, err)
+			}
+			s.log.InfoContext(ctx, "card saved", "user_id", userID, "payment_method_id", pm.StripePMID)
+			return nil
+		}, nil
+
+	case "payment_intent.succeeded",
+		"payment_intent.amount_capturable_updated",
+		"payment_intent.payment_failed",
+		"payment_intent.canceled",
+		"payment_intent.requires_action":
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", event.Type, err)
+		}
+		update := s.depositService.PrepareGatewayUpdate(ctx, *stripeadapter.ToPaymentIntent(&pi))
+		return func(ctx context.Context) error {
+			return s.syncPaymentIntent(ctx, &update)
+		}, nil
+
+	case "charge.refunded":
+		// Начиная с версии API, которую использует stripe-go v74, charge не
+		// содержит refunds: сами возвраты приходят событиями refund.*.
+		// Встроенный список разбираем только в payload'ах старых версий
+		var ch stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &ch); err != nil {
+			return nil, fmt.Errorf("parse charge.refunded: %w", err)
+		}
+		if ch.Refunds == nil {
+			return noop, nil
+		}
+		refunds := make([]*gateway.Refund, 0, len(ch.Refunds.Data))
+		for _, r := range ch.Refunds.Data {
+			refund := stripeadapter.ToRefund(r)
+			if refund.PaymentIntentID == "" && ch.PaymentIntent != nil {
+				refund.PaymentIntentID = ch.PaymentIntent.ID
+			}
+			refunds = append(refunds, refund)
+		}
+		return func(ctx context.Context) error {
+			for _, r := range refunds {
+				if err := s.refundService.SyncGatewayRefund(ctx, *r); err != nil {
+					return fmt.Errorf("sync refund %s: %w", r.ID, err)
+				}
+			}
+			return nil
+		}, nil
+
+	case "refund.created", "refund.updated", "charge.refund.updated":
+		// Включая возвраты, сделанные в Dashboard или другим клиентом API
+		var r stripe.Refund
+		if err := json.Unmarshal(event.Data.Raw, &r); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", event.Type, err)
+		}
+		refund := stripeadapter.ToRefund(&r)
+		return func(ctx context.Context) error {
+			if err := s.refundService.SyncGatewayRefund(ctx, *refund); err != nil {
+				return fmt.Errorf("sync refund %s: %w", refund.ID, err)
+			}
+			return nil
+		}, nil
+
+	case "account.updated":
+		// Приходит на Connect-endpoint: онбординг хоста продвинулся
+		var a stripe.Account
+		if err := json.Unmarshal(event.Data.Raw, &a); err != nil {
+			return nil, fmt.Errorf("parse account.updated: %w", err)
+		}
+		account := stripeadapter.ToConnectedAccount(&a)
+		return func(ctx context.Context) error {
+			if err := s.connectService.SyncAccount(ctx, *account); err != nil {
+				return fmt.Errorf("sync account %s: %w", account.ID, err)
+			}
+			return nil
+		}, nil
+	}
+	s.log.DebugContext(ctx, "unhandled stripe event type")
+	return noop, nil
+}
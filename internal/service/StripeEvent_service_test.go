@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+
+	"github.com/stripe/stripe-go/v74"
+)
+
+// recordingRefunds запоминает возвраты, пришедшие из событий
+type recordingRefunds struct {
+	RefundService
+	synced []gateway.Refund
+}
+
+func (r *recordingRefunds) SyncGatewayRefund(ctx context.Context, refund gateway.Refund) error {
+	r.synced = append(r.synced, refund)
+	return nil
+}
+
+func stripeEvent(t *testing.T, typ string, obj map[string]interface{}) stripe.Event {
+	t.Helper()
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stripe.Event{Type: typ, Data: &stripe.EventData{Raw: raw}}
+}
+
+func TestStripeEventService_RefundEvents(t *testing.T) {
+	refund := map[string]interface{}{
+		"id":             "re_1",
+		"object":         "refund",
+		"amount":         2500,
+		"currency":       "usd",
+		"payment_intent": "pi_1",
+		"status":         "succeeded",
+		"metadata":       map[string]string{},
+	}
+	tests := []struct {
+		name  string
+		event stripe.Event
+		want  int
+	}{
+		{"refund created in the dashboard", stripeEvent(t, "refund.created", refund), 1},
+		{"refund updated", stripeEvent(t, "refund.updated", refund), 1},
+		{"charge refund updated", stripeEvent(t, "charge.refund.updated", refund), 1},
+		{"charge without embedded refunds", stripeEvent(t, "charge.refunded", map[string]interface{}{
+			"id": "ch_1", "object": "charge", "payment_intent": "pi_1", "amount_refunded": 2500,
+		}), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refunds := &recordingRefunds{}
+			s := &stripeEventService{refundService: refunds, log: discardLogger()}
+			store, err := s.process(context.Background(), tt.event)
+			if err != nil {
+				t.Fatalf("process: %v", err)
+			}
+			if len(refunds.synced) != 0 {
+				t.Fatal("refund synced before the transaction")
+			}
+			if err := store(context.Background()); err != nil {
+				t.Fatalf("store: %v", err)
+			}
+			if len(refunds.synced) != tt.want {
+				t.Fatalf("synced %d refunds, want %d", len(refunds.synced), tt.want)
+			}
+			if tt.want > 0 {
+				got := refunds.synced[0]
+				if got.ID != "re_1" || got.PaymentIntentID != "pi_1" || got.Amount != 2500 || got.Status != gateway.RefundStatusSucceeded {
+					t.Errorf("synced refund = %+v", got)
+				}
+			}
+		})
+	}
+}
+
+// txTracker — UnitOfWork, который помнит, открыта ли транзакция
+type txTracker struct {
+	open bool
+}
+
+func (u *txTracker) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	u.open = true
+	defer func() { u.open = false }()
+	return fn(ctx)
+}
+
+// trackedCards проверяет, что карта читается из шлюза вне транзакции, а сохраняется в ней
+type trackedCards struct {
+	PaymentMethodService
+	uow                      *txTracker
+	retrievedInTx, savedInTx bool
+	saved                    []repository.PaymentMethod
+}
+
+func (c *trackedCards) RetrievePaymentMethod(ctx context.Context, userID, pmID string) (repository.PaymentMethod, error) {
+	c.retrievedInTx = c.uow.open
+	return repository.PaymentMethod{UserID: userID, StripePMID: pmID, Brand: "visa", Last4: "4242"}, nil
+}
+
+func (c *trackedCards) SavePaymentMethod(ctx context.Context, pm repository.PaymentMethod) error {
+	c.savedInTx = c.uow.open
+	c.saved = append(c.saved, pm)
+	return nil
+}
+
+type markedEvents struct {
+	repository.StripeEventRepo
+	processed []string
+}
+
+func (r *markedEvents) MarkStripeEventProcessed(ctx context.Context, id string) error {
+	r.processed = append(r.processed, id)
+	return nil
+}
+
+func TestStripeEventService_GatewayCallsOutsideTransaction(t *testing.T) {
+	uow := &txTracker{}
+	cards := &trackedCards{uow: uow}
+	events := &markedEvents{}
+	s := &stripeEventService{repo: events, uow: uow, pmService: cards, log: discardLogger()}
+
+	event := stripeEvent(t, "setup_intent.succeeded", map[string]interface{}{
+		"id":             "seti_1",
+		"object":         "setup_intent",
+		"metadata":       map[string]string{"user_id": "user-1"},
+		"payment_method": "pm_1",
+	})
+	if err := s.processAndMark(context.Background(), "evt_1", event); err != nil {
+		t.Fatalf("processAndMark: %v", err)
+	}
+	if cards.retrievedInTx {
+		t.Error("card was retrieved from the gateway inside the transaction")
+	}
+	if !cards.savedInTx || len(cards.saved) != 1 || cards.saved[0].StripePMID != "pm_1" {
+		t.Errorf("card saved in transaction = %v, saved = %+v", cards.savedInTx, cards.saved)
+	}
+	if len(events.processed) != 1 || events.processed[0] != "evt_1" {
+		t.Errorf("processed events = %v, want [evt_1]", events.processed)
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"Payment-service/internal/events"
+	"Payment-service/internal/repository"
+	"Payment-service/internal/webhooksig"
+)
+
+const (
+	// webhookDeliveryLease keeps other dispatchers away from a delivery in flight.
+	webhookDeliveryLease = time.Minute
+	// webhookRetryBase is the delay before the first retry; it doubles each attempt.
+	webhookRetryBase = 30 * time.Second
+	// webhookRetryMaxDelay caps the exponential backoff.
+	webhookRetryMaxDelay = 6 * time.Hour
+	// webhookMaxAttempts is how many times a delivery is tried before it is marked failed.
+	webhookMaxAttempts = 12
+	// webhookTimeout bounds a single delivery request.
+	webhookTimeout = 10 * time.Second
+)
+
+// SubscriptionUpdate holds the fields to change; nil fields are kept.
+type SubscriptionUpdate struct {
+	URL         *string
+	EventTypes  *[]string
+	Description *string
+	Active      *bool
+}
+
+// WebhookService manages outgoing webhook subscriptions and delivers events to them.
+type WebhookService interface {
+	// CreateSubscription registers an endpoint with a freshly generated signing secret.
+	// Empty eventTypes subscribes to all events.
+	CreateSubscription(ctx context.Context, endpoint string, eventTypes []string, description string) (repository.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]repository.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (repository.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, id int64, upd SubscriptionUpdate) (repository.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	// RotateSecret replaces the signing secret of a subscription.
+	RotateSecret(ctx context.Context, id int64) (repository.WebhookSubscription, error)
+	// ListDeliveries returns the latest delivery attempts of a subscription.
+	ListDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]repository.WebhookDelivery, error)
+	// RetryDelivery queues a delivery again, e.g. one that ran out of attempts.
+	RetryDelivery(ctx context.Context, deliveryID int64) error
+	// DispatchDue sends up to limit due deliveries and returns how many were claimed.
+	DispatchDue(ctx context.Context, limit int) (int, error)
+}
+
+// webhookService is a concrete implementation of WebhookService.
+type webhookService struct {
+	repo   repository.WebhookRepo
+	client *http.Client
+	log    *slog.Logger
+}
+
+// NewWebhookService constructs a WebhookService.
+func NewWebhookService(repo repository.WebhookRepo, logger *slog.Logger) WebhookService {
+	return &webhookService{repo: repo, client: &http.Client{Timeout: webhookTimeout}, log: logger}
+}
+
+func (s *webhookService) CreateSubscription(ctx context.Context, endpoint string, eventTypes []string, description string) (repository.WebhookSubscription, error) {
+	if err := validateSubscription(endpoint, eventTypes); err != nil {
+		return repository.WebhookSubscription{}, err
+	}
+	secret, err := webhooksig.GenerateSecret()
+	if err != nil {
+		return repository.WebhookSubscription{}, err
+	}
+	return s.repo.CreateWebhookSubscription(ctx, repository.WebhookSubscription{
+		URL:         endpoint,
+		Secret:      secret,
+		EventTypes:  eventTypes,
+		Description: description,
+		Active:      true,
+	})
+}
+
+func (s *webhookService) ListSubscriptions(ctx context.Context) ([]repository.WebhookSubscription, error) {
+	return s.repo.ListWebhookSubscriptions(ctx)
+}
+
+func (s *webhookService) GetSubscription(ctx context.Context, id int64) (repository.WebhookSubscription, error) {
+	sub, err := s.repo.GetWebhookSubscription(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.WebhookSubscription{}, ErrNotFound
+	}
+	return sub, err
+}
+
+func (s *webhookService) UpdateSubscription(ctx context.Context, id int64, upd SubscriptionUpdate) (repository.WebhookSubscription, error) {
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return repository.WebhookSubscription{}, err
+	}
+	if upd.URL != nil {
+		sub.URL = *upd.URL
+	}
+	if upd.EventTypes != nil {
+		sub.EventTypes = *upd.EventTypes
+	}
+	if upd.Description != nil {
+		sub.Description = *upd.Description
+	}
+	if upd.Active != nil {
+		sub.Active = *upd.Active
+	}
+	if err := validateSubscription(sub.URL, sub.EventTypes); err != nil {
+		return repository.WebhookSubscription{}, err
+	}
+	return s.repo.UpdateWebhookSubscription(ctx, sub)
+}
+
+func (s *webhookService) DeleteSubscription(ctx context.Context, id int64) error {
+	err := s.repo.DeleteWebhookSubscription(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *webhookService) RotateSecret(ctx context.Context, id int64) (repository.WebhookSubscription, error) {
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return repository.WebhookSubscription{}, err
+	}
+	if sub.Secret, err = webhooksig.GenerateSecret(); err != nil {
+		return repository.WebhookSubscription{}, err
+	}
+	return s.repo.UpdateWebhookSubscription(ctx, sub)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]repository.WebhookDelivery, error) {
+	if _, err := s.GetSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListWebhookDeliveries(ctx, subscriptionID, limit)
+}
+
+func (s *webhookService) RetryDelivery(ctx context.Context, deliveryID int64) error {
+	err := s.repo.RetryWebhookDelivery(ctx, deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *webhookService) DispatchDue(ctx context.Context, limit int) (int, error) {
+	due, err := s.repo.ClaimWebhookDeliveries(ctx, limit, webhookDeliveryLease)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range due {
+		code, err := s.send(ctx, d)
+		if err == nil {
+			if markErr := s.repo.MarkWebhookDelivered(ctx, d.DeliveryID, code); markErr != nil {
+				s.log.ErrorContext(ctx, "failed to mark webhook delivery", "delivery_id", d.DeliveryID, "error", markErr)
+			}
+			continue
+		}
+
+		// URL подписчика не пишем: в нём бывают токены
+		s.log.WarnContext(ctx, "webhook delivery failed",
+			"delivery_id", d.DeliveryID, "event_type", d.EventType, "attempt", d.Attempts, "error", err)
+		var next *time.Time
+		if d.Attempts < webhookMaxAttempts {
+			at := time.Now().Add(backoff(webhookRetryBase, webhookRetryMaxDelay, d.Attempts))
+			next = &at
+		}
+		if markErr := s.repo.MarkWebhookDeliveryFailed(ctx, d.DeliveryID, code, err.Error(), next); markErr != nil {
+			s.log.ErrorContext(ctx, "failed to mark webhook delivery", "delivery_id", d.DeliveryID, "error", markErr)
+		}
+	}
+	return len(due), nil
+}
+
+// send posts the signed event and returns the response status code (0 if none).
+func (s *webhookService) send(ctx context.Context, d repository.WebhookDispatch) (int, error) {
+	body, err := json.Marshal(events.Envelope{
+		ID:            d.EventID,
+		Type:          d.EventType,
+		AggregateType: d.AggregateType,
+		AggregateID:   d.AggregateID,
+		OccurredAt:    d.OccurredAt,
+		Data:          d.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Payment-service-Webhooks/1.0")
+	req.Header.Set("X-Event-Id", strconv.FormatInt(d.EventID, 10))
+	req.Header.Set("X-Event-Type", d.EventType)
+	req.Header.Set(webhooksig.Header, webhooksig.Sign(d.Secret, body, time.Now()))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func validateSubscription(endpoint string, eventTypes []string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidInput)
+	}
+	for _, t := range eventTypes {
+		if !events.IsKnownType(t) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidInput, t)
+		}
+	}
+	return nil
+}
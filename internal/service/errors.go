@@ -0,0 +1,20 @@
+package service
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidAmount is returned for non-positive or out-of-range amounts.
+	ErrInvalidAmount = errors.New("invalid amount")
+	// ErrInvalidState is returned when an operation is not allowed in the current status.
+	ErrInvalidState = errors.New("operation not allowed in current status")
+	// ErrNotCaptured is returned when refunding a payment that was never captured.
+	ErrNotCaptured = errors.New("payment is not captured")
+	// ErrInvalidInput is returned for malformed request data, e.g. a webhook URL.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrRefundExceedsCaptured is returned when refunds would exceed the captured amount.
+	ErrRefundExceedsCaptured = errors.New("refunds exceed captured amount")
+	// ErrNotConfigured is returned when an optional feature lacks its settings.
+	ErrNotConfigured = errors.New("not configured")
+)
@@ -0,0 +1,532 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"Payment-service/internal/gateway"
+	"Payment-service/internal/repository"
+)
+
+// memStore — in-memory хранилище платежей и депозитов для тестов сервисов.
+// WithTx только отмечает, что транзакция открыта: откат в этих тестах не проверяется.
+type memStore struct {
+	mu       sync.Mutex
+	payments map[string]repository.PaymentIntent
+	deposits map[string]repository.Deposit
+	history  []repository.StatusChange
+	txDepth  int
+}
+
+var (
+	_ repository.PaymentIntentRepo = (*memStore)(nil)
+	_ repository.DepositRepo       = (*memStore)(nil)
+	_ repository.StatusHistoryRepo = (*memStore)(nil)
+	_ repository.UnitOfWork        = (*memStore)(nil)
+)
+
+func newMemStore() *memStore {
+	return &memStore{
+		payments: make(map[string]repository.PaymentIntent),
+		deposits: make(map[string]repository.Deposit),
+	}
+}
+
+func (m *memStore) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.mu.Lock()
+	m.txDepth++
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.txDepth--
+		m.mu.Unlock()
+	}()
+	return fn(ctx)
+}
+
+// inTx сообщает, выполняется ли сейчас WithTx
+func (m *memStore) inTx() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.txDepth > 0
+}
+
+func (m *memStore) addHistory(id, kind, from, to, source string) {
+	m.history = append(m.history, repository.StatusChange{
+		ID: int64(len(m.history) + 1), StripePIID: id, Kind: kind,
+		FromStatus: from, ToStatus: to, Source: source, CreatedAt: time.Now(),
+	})
+}
+
+func (m *memStore) CreatePaymentIntent(ctx context.Context, pi repository.PaymentIntent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.payments[pi.StripePIID] = pi
+	m.addHistory(pi.StripePIID, repository.RefundKindPayment, "", pi.Status, repository.StatusSourceAPI)
+	return nil
+}
+
+func (m *memStore) UpdatePaymentIntentStatus(ctx context.Context, stripePIID, fromStatus, toStatus, source string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pi, ok := m.payments[stripePIID]
+	if !ok || pi.Status != fromStatus {
+		return repository.ErrStatusChanged
+	}
+	pi.Status = toStatus
+	m.payments[stripePIID] = pi
+	if fromStatus != toStatus {
+		m.addHistory(stripePIID, repository.RefundKindPayment, fromStatus, toStatus, source)
+	}
+	return nil
+}
+
+func (m *memStore) GetPaymentIntentByID(ctx context.Context, stripePIID string) (repository.PaymentIntent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pi, ok := m.payments[stripePIID]
+	if !ok {
+		return repository.PaymentIntent{}, sql.ErrNoRows
+	}
+	return pi, nil
+}
+
+func (m *memStore) CreateDeposit(ctx context.Context, d repository.Deposit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.CreatedAt, d.UpdatedAt = time.Now(), time.Now()
+	m.deposits[d.StripePIID] = d
+	m.addHistory(d.StripePIID, repository.RefundKindDeposit, "", d.Status, repository.StatusSourceAPI)
+	return nil
+}
+
+func (m *memStore) UpdateDepositStatus(ctx context.Context, stripePIID, fromStatus, toStatus, source string) error {
+	return m.updateDeposit(stripePIID, fromStatus, toStatus, source, func(*repository.Deposit) {})
+}
+
+func (m *memStore) UpdateDepositCapture(ctx context.Context, stripePIID, fromStatus, toStatus string, capturedAmount int64, reason, source string) error {
+	return m.updateDeposit(stripePIID, fromStatus, toStatus, source, func(d *repository.Deposit) {
+		d.CapturedAmount = capturedAmount
+		if reason != "" {
+			d.CaptureReason = reason
+		}
+	})
+}
+
+func (m *memStore) updateDeposit(stripePIID, fromStatus, toStatus, source string, apply func(*repository.Deposit)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deposits[stripePIID]
+	if !ok || d.Status != fromStatus {
+		return repository.ErrStatusChanged
+	}
+	d.Status = toStatus
+	d.UpdatedAt = time.Now()
+	apply(&d)
+	m.deposits[stripePIID] = d
+	if fromStatus != toStatus {
+		m.addHistory(stripePIID, repository.RefundKindDeposit, fromStatus, toStatus, source)
+	}
+	return nil
+}
+
+func (m *memStore) GetDepositByID(ctx context.Context, stripePIID string) (repository.Deposit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deposits[stripePIID]
+	if !ok {
+		return repository.Deposit{}, sql.ErrNoRows
+	}
+	return d, nil
+}
+
+func (m *memStore) ListDepositsByBookingID(ctx context.Context, bookingID string) ([]repository.Deposit, error) {
+	return m.listDeposits(func(d repository.Deposit) bool { return d.BookingID == bookingID }), nil
+}
+
+func (m *memStore) ListDepositsByUserID(ctx context.Context, userID string) ([]repository.Deposit, error) {
+	return m.listDeposits(func(d repository.Deposit) bool { return d.UserID == userID }), nil
+}
+
+func (m *memStore) listDeposits(match func(repository.Deposit) bool) []repository.Deposit {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.Deposit
+	for _, d := range m.deposits {
+		if match(d) {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StripePIID < out[j].StripePIID })
+	return out
+}
+
+func (m *memStore) SetDepositHoldExpiry(ctx context.Context, stripePIID string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deposits[stripePIID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	// Как и в Postgres, срок задаётся только один раз
+	if d.HoldExpiresAt == nil {
+		d.HoldExpiresAt = &expiresAt
+		m.deposits[stripePIID] = d
+	}
+	return nil
+}
+
+func (m *memStore) ListExpiringDeposits(ctx context.Context, before time.Time, after *repository.DepositCursor, limit int) ([]repository.Deposit, error) {
+	list := m.listDeposits(func(d repository.Deposit) bool {
+		return d.Status == gateway.StatusRequiresCapture && d.HoldExpiresAt != nil && d.HoldExpiresAt.Before(before)
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].HoldExpiresAt.Before(*list[j].HoldExpiresAt) })
+	out := list[:0]
+	for _, d := range list {
+		if after != nil && (d.HoldExpiresAt.Before(after.HoldExpiresAt) ||
+			d.HoldExpiresAt.Equal(after.HoldExpiresAt) && d.StripePIID <= after.StripePIID) {
+			continue
+		}
+		out = append(out, d)
+	}
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (m *memStore) MarkDepositExpiryNotified(ctx context.Context, stripePIID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deposits[stripePIID]
+	if !ok || d.ExpiryNotifiedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	d.ExpiryNotifiedAt = &now
+	m.deposits[stripePIID] = d
+	return true, nil
+}
+
+func (m *memStore) ListStatusHistory(ctx context.Context, stripePIID, kind string) ([]repository.StatusChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.StatusChange
+	for _, h := range m.history {
+		if h.StripePIID == stripePIID && h.Kind == kind {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+// memLedger — главная книга в памяти с тем же контрактом, что у storage.Store:
+// проводка с уже известным IdempotencyKey не записывается
+type memLedger struct {
+	mu       sync.Mutex
+	entries  []repository.LedgerEntry
+	accounts map[repository.LedgerAccountRef]int64
+}
+
+var _ repository.LedgerRepo = (*memLedger)(nil)
+
+func newMemLedger() *memLedger {
+	return &memLedger{accounts: make(map[repository.LedgerAccountRef]int64)}
+}
+
+func (m *memLedger) PostLedgerEntry(ctx context.Context, e repository.NewLedgerEntry) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, old := range m.entries {
+		if old.IdempotencyKey == e.IdempotencyKey {
+			return false, nil
+		}
+	}
+	entry := repository.LedgerEntry{
+		ID: int64(len(m.entries) + 1), IdempotencyKey: e.IdempotencyKey, Kind: e.Kind,
+		Reference: e.Reference, Description: e.Description, CreatedAt: time.Now(),
+	}
+	for _, l := range e.Lines {
+		id, ok := m.accounts[l.Account]
+		if !ok {
+			id = int64(len(m.accounts) + 1)
+			m.accounts[l.Account] = id
+		}
+		entry.Postings = append(entry.Postings, repository.LedgerPosting{
+			EntryID: entry.ID, AccountID: id, AccountType: l.Account.Type,
+			OwnerID: l.Account.OwnerID, Currency: l.Account.Currency, Amount: l.Amount,
+		})
+	}
+	m.entries = append(m.entries, entry)
+	return true, nil
+}
+
+func (m *memLedger) ListLedgerEntries(ctx context.Context, f repository.LedgerEntryFilter) ([]repository.LedgerEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.LedgerEntry
+	for i := len(m.entries) - 1; i >= 0 && (f.Limit == 0 || len(out) < f.Limit); i-- {
+		if f.Reference == "" || m.entries[i].Reference == f.Reference {
+			out = append(out, m.entries[i])
+		}
+	}
+	return out, nil
+}
+
+func (m *memLedger) ListLedgerAccounts(ctx context.Context, f repository.LedgerAccountFilter) ([]repository.LedgerAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.LedgerAccount
+	for ref, id := range m.accounts {
+		if f.Type != "" && ref.Type != f.Type || f.OwnerID != "" && ref.OwnerID != f.OwnerID ||
+			f.Currency != "" && ref.Currency != f.Currency {
+			continue
+		}
+		out = append(out, m.account(ref, id))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *memLedger) GetLedgerAccount(ctx context.Context, id int64) (repository.LedgerAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ref, accountID := range m.accounts {
+		if accountID == id {
+			return m.account(ref, id), nil
+		}
+	}
+	return repository.LedgerAccount{}, sql.ErrNoRows
+}
+
+func (m *memLedger) account(ref repository.LedgerAccountRef, id int64) repository.LedgerAccount {
+	a := repository.LedgerAccount{ID: id, Type: ref.Type, OwnerID: ref.OwnerID, Currency: ref.Currency}
+	for _, e := range m.entries {
+		for _, p := range e.Postings {
+			if p.AccountID == id {
+				a.Balance += p.Amount
+			}
+		}
+	}
+	return a
+}
+
+// balance — баланс счёта; несуществующий счёт пуст
+func (m *memLedger) balance(typ, owner, currency string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.accounts[repository.LedgerAccountRef{Type: typ, OwnerID: owner, Currency: currency}]
+	if !ok {
+		return 0
+	}
+	return m.account(repository.LedgerAccountRef{Type: typ, OwnerID: owner, Currency: currency}, id).Balance
+}
+
+// nopLedger не проводит ничего; тесты главной книги используют настоящий ledgerService
+type nopLedger struct {
+	LedgerService
+}
+
+func (nopLedger) RecordStatus(ctx context.Context, subj LedgerSubject, status string) error {
+	return nil
+}
+
+func (nopLedger) RecordRefund(ctx context.Context, subj LedgerSubject, r repository.Refund) error {
+	return nil
+}
+
+// nopPayouts не ставит переводы хостам
+type nopPayouts struct{}
+
+func (nopPayouts) ScheduleTransfer(ctx context.Context, subj LedgerSubject) error {
+	return nil
+}
+
+// holdTTLForTests — срок авторизации депозита в тестах
+const holdTTLForTests = 7 * 24 * time.Hour
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// memHosts — аккаунты хостов и очереди переводов и их возвратов в памяти.
+// Lease не моделируется: занятая запись просто откладывается на lease.
+type memHosts struct {
+	mu        sync.Mutex
+	accounts  map[string]repository.HostAccount
+	transfers []repository.HostTransfer
+	reversals []repository.HostTransferReversal
+}
+
+var (
+	_ repository.HostAccountRepo  = (*memHosts)(nil)
+	_ repository.HostTransferRepo = (*memHosts)(nil)
+)
+
+func newMemHosts() *memHosts {
+	return &memHosts{accounts: make(map[string]repository.HostAccount)}
+}
+
+func (m *memHosts) CreateHostAccount(ctx context.Context, a repository.HostAccount) (repository.HostAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.accounts[a.HostID]; ok {
+		return old, nil
+	}
+	m.accounts[a.HostID] = a
+	return a, nil
+}
+
+func (m *memHosts) GetHostAccount(ctx context.Context, hostID string) (repository.HostAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.accounts[hostID]
+	if !ok {
+		return repository.HostAccount{}, sql.ErrNoRows
+	}
+	return a, nil
+}
+
+func (m *memHosts) UpdateHostAccountStatus(ctx context.Context, a repository.HostAccount) (repository.HostAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hostID, old := range m.accounts {
+		if old.StripeAccountID == a.StripeAccountID {
+			a.HostID = hostID
+			m.accounts[hostID] = a
+			return a, nil
+		}
+	}
+	return repository.HostAccount{}, sql.ErrNoRows
+}
+
+func (m *memHosts) UpsertHostTransfer(ctx context.Context, t repository.HostTransfer) (repository.HostTransfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, old := range m.transfers {
+		if old.StripePIID != t.StripePIID {
+			continue
+		}
+		old.Amount = t.Amount
+		switch {
+		case old.Status == repository.HostTransferPaid:
+		case t.Amount == 0:
+			old.Status = repository.HostTransferCanceled
+		case old.Status != repository.HostTransferFailed:
+			old.Status = repository.HostTransferPending
+		}
+		m.transfers[i] = old
+		return old, nil
+	}
+	t.ID = int64(len(m.transfers) + 1)
+	t.Status = repository.HostTransferPending
+	if t.Amount == 0 {
+		t.Status = repository.HostTransferCanceled
+	}
+	t.NextAttemptAt = time.Now()
+	m.transfers = append(m.transfers, t)
+	return t, nil
+}
+
+func (m *memHosts) ClaimHostTransfers(ctx context.Context, limit int, lease time.Duration) ([]repository.HostTransfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.HostTransfer
+	for i, t := range m.transfers {
+		a, ok := m.accounts[t.HostID]
+		if len(out) == limit || t.Status != repository.HostTransferPending || !ok || !a.PayoutsEnabled ||
+			t.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		t.Attempts++
+		t.NextAttemptAt = time.Now().Add(lease)
+		m.transfers[i] = t
+		t.StripeAccountID = a.StripeAccountID
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (m *memHosts) MarkHostTransferPaid(ctx context.Context, id int64, stripeTransferID string, paidAmount int64) (repository.HostTransfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := &m.transfers[id-1]
+	t.Status, t.StripeTransferID, t.PaidAmount = repository.HostTransferPaid, stripeTransferID, paidAmount
+	return *t, nil
+}
+
+func (m *memHosts) MarkHostTransferFailed(ctx context.Context, id int64, errMsg string, nextAttemptAt time.Time, final bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := &m.transfers[id-1]
+	t.LastError, t.NextAttemptAt = &errMsg, nextAttemptAt
+	if final {
+		t.Status = repository.HostTransferFailed
+	}
+	return nil
+}
+
+func (m *memHosts) ListHostTransfers(ctx context.Context, hostID string, limit int) ([]repository.HostTransfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.HostTransfer
+	for i := len(m.transfers) - 1; i >= 0 && len(out) < limit; i-- {
+		if m.transfers[i].HostID == hostID {
+			out = append(out, m.transfers[i])
+		}
+	}
+	return out, nil
+}
+
+func (m *memHosts) EnqueueHostTransferReversal(ctx context.Context, hostTransferID, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transfers[hostTransferID-1].ReversedAmount += amount
+	m.reversals = append(m.reversals, repository.HostTransferReversal{
+		ID: int64(len(m.reversals) + 1), HostTransferID: hostTransferID, Amount: amount,
+		Status: repository.HostTransferPending, NextAttemptAt: time.Now(),
+	})
+	return nil
+}
+
+func (m *memHosts) ClaimHostTransferReversals(ctx context.Context, limit int, lease time.Duration) ([]repository.HostTransferReversal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []repository.HostTransferReversal
+	for i, r := range m.reversals {
+		if len(out) == limit || r.Status != repository.HostTransferPending || r.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		r.Attempts++
+		r.NextAttemptAt = time.Now().Add(lease)
+		m.reversals[i] = r
+		t := m.transfers[r.HostTransferID-1]
+		r.StripePIID, r.Kind, r.HostID, r.Currency, r.StripeTransferID = t.StripePIID, t.Kind, t.HostID, t.Currency, t.StripeTransferID
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (m *memHosts) MarkHostTransferReversalPaid(ctx context.Context, id int64, stripeReversalID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &m.reversals[id-1]
+	r.Status, r.StripeReversalID = repository.HostTransferPaid, stripeReversalID
+	return nil
+}
+
+func (m *memHosts) MarkHostTransferReversalFailed(ctx context.Context, id int64, errMsg string, nextAttemptAt time.Time, final bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &m.reversals[id-1]
+	r.LastError, r.NextAttemptAt = &errMsg, nextAttemptAt
+	if final {
+		r.Status = repository.HostTransferFailed
+	}
+	return nil
+}
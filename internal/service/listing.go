@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"Payment-service/internal/bookingclient"
+	"Payment-service/internal/listingclient"
+)
+
+// HostResolver находит хоста объявления; его реализует listingclient.Client
+type HostResolver interface {
+	GetByID(ctx context.Context, listingID string) (listingclient.Listing, error)
+}
+
+// resolveHost возвращает хоста объявления, которому причитаются деньги.
+// Без Listing-service хост остаётся пустым и долг учитывается на общем счёте.
+func resolveHost(ctx context.Context, hosts HostResolver, listingID string) (string, error) {
+	if hosts == nil || listingID == "" {
+		return "", nil
+	}
+	l, err := hosts.GetByID(ctx, listingID)
+	if errors.Is(err, listingclient.ErrNotFound) {
+		return "", fmt.Errorf("%w: unknown listing %s", ErrInvalidInput, listingID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolve listing host: %w", err)
+	}
+	return l.HostID, nil
+}
+
+// BookingResolver находит бронь; его реализует bookingclient.Client
+type BookingResolver interface {
+	GetByID(ctx context.Context, bookingID string) (bookingclient.Booking, error)
+}
+
+// resolveListing возвращает объявление брони. По объявлению определяются
+// хост, которому причитаются деньги, и его доступ к платежу, поэтому
+// listing_id из запроса должен совпадать с бронью, а бронь — принадлежать
+// пользователю. Без Booking-service listingID принимается как есть.
+func resolveListing(ctx context.Context, bookings BookingResolver, bookingID, userID, listingID string) (string, error) {
+	if bookings == nil {
+		return listingID, nil
+	}
+	b, err := bookings.GetByID(ctx, bookingID)
+	if errors.Is(err, bookingclient.ErrNotFound) {
+		return "", fmt.Errorf("%w: unknown booking %s", ErrInvalidInput, bookingID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolve booking listing: %w", err)
+	}
+	if b.UserID != userID {
+		return "", fmt.Errorf("%w: booking %s belongs to another user", ErrInvalidInput, bookingID)
+	}
+	if listingID != "" && listingID != b.ListingID {
+		return "", fmt.Errorf("%w: listing %s does not match booking %s", ErrInvalidInput, listingID, bookingID)
+	}
+	return b.ListingID, nil
+}
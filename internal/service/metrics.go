@@ -0,0 +1,24 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Results of stripeWebhookEvents
+const (
+	webhookReceived  = "received"
+	webhookDuplicate = "duplicate"
+	webhookProcessed = "processed"
+	webhookFailed    = "failed"
+)
+
+var stripeWebhookEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "stripe_webhook_events_total",
+	Help: "Stripe webhook events by type and result: received, duplicate, processed or failed (each failed attempt counts).",
+}, []string{"type", "result"})
+
+var reconciliationResults = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "payment_reconciliation_results_total",
+	Help: "PaymentIntents checked by reconciliation by result: matched, repaired, missing_locally, missing_remotely or unresolved.",
+}, []string{"result"})
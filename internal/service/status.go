@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// maxTransitionAttempts bounds how often a status change is re-checked after
+// losing a race with a concurrent update (e.g. a webhook).
+const maxTransitionAttempts = 3
+
+func invalidTransition(from, to string) error {
+	return fmt.Errorf("%w: %s -> %s", ErrInvalidState, from, to)
+}
+
+// logStaleUpdate records a gateway update that the state machine rejected.
+// Such updates are out-of-order events and are dropped.
+func logStaleUpdate(ctx context.Context, logger *slog.Logger, kind, id, source string, err error) {
+	logger.InfoContext(ctx, "ignoring stale "+kind+" update",
+		"payment_intent_id", id, "source", source, "reason", err)
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"Payment-service/internal/events"
+	"Payment-service/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// --- Истечение авторизации депозитов ---
+
+// SetDepositHoldExpiry задаёт срок авторизации, если он ещё не задан.
+func (s *Store) SetDepositHoldExpiry(ctx context.Context, stripePIID string, expiresAt time.Time) error {
+	const query = `
+UPDATE deposits
+SET hold_expires_at = $2
+WHERE stripe_pi_id = $1 AND hold_expires_at IS NULL;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, stripePIID, expiresAt)
+	return err
+}
+
+// ListExpiringDeposits возвращает незахваченные депозиты с истекающей авторизацией.
+// Курсор (hold_expires_at, stripe_pi_id) совпадает с порядком индекса.
+func (s *Store) ListExpiringDeposits(ctx context.Context, before time.Time, after *repository.DepositCursor, limit int) ([]repository.Deposit, error) {
+	const query = `
+SELECT ` + depositColumns + `
+FROM deposits
+WHERE status = 'requires_capture' AND hold_expires_at <= $1
+  AND ($2::TIMESTAMPTZ IS NULL OR (hold_expires_at, stripe_pi_id) > ($2, $3))
+ORDER BY hold_expires_at, stripe_pi_id
+LIMIT $4;
+`
+	var afterAt *time.Time
+	var afterID string
+	if after != nil {
+		afterAt, afterID = &after.HoldExpiresAt, after.StripePIID
+	}
+	var list []repository.Deposit
+	err := s.conn(ctx).SelectContext(ctx, &list, query, before, afterAt, afterID, limit)
+	return list, err
+}
+
+// MarkDepositExpiryNotified отмечает уведомление и в той же транзакции пишет
+// событие deposit.hold_expiring; повторный вызов ничего не делает.
+func (s *Store) MarkDepositExpiryNotified(ctx context.Context, stripePIID string) (bool, error) {
+	query := `
+UPDATE deposits
+SET expiry_notified_at = now()
+WHERE stripe_pi_id = $1 AND expiry_notified_at IS NULL
+RETURNING ` + depositColumns + `;
+`
+	notified := false
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var d repository.Deposit
+		err := tx.GetContext(ctx, &d, query, stripePIID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		notified = true
+		return insertOutboxEvent(ctx, tx, events.TypeDepositHoldExpiring, events.AggregateDeposit, stripePIID,
+			depositEvent(d, "", repository.StatusSourceScheduler))
+	})
+	return notified && err == nil, err
+}
+
+// --- DepositHoldPolicyRepo ---
+
+var _ repository.DepositHoldPolicyRepo = (*Store)(nil)
+
+// SaveDepositHoldPolicy создаёт или заменяет политику.
+func (s *Store) SaveDepositHoldPolicy(ctx context.Context, p repository.DepositHoldPolicy) (repository.DepositHoldPolicy, error) {
+	const query = `
+INSERT INTO deposit_hold_policies (scope, scope_id, action, updated_at)
+VALUES ($1, $2, $3, now())
+ON CONFLICT (scope, scope_id) DO UPDATE SET action = EXCLUDED.action, updated_at = now()
+RETURNING scope, scope_id, action, updated_at;
+`
+	var out repository.DepositHoldPolicy
+	err := s.conn(ctx).GetContext(ctx, &out, query, p.Scope, p.ScopeID, p.Action)
+	return out, err
+}
+
+// GetDepositHoldPolicy возвращает политику объявления или брони.
+func (s *Store) GetDepositHoldPolicy(ctx context.Context, scope, scopeID string) (repository.DepositHoldPolicy, error) {
+	const query = `
+SELECT scope, scope_id, action, updated_at
+FROM deposit_hold_policies
+WHERE scope = $1 AND scope_id = $2;
+`
+	var p repository.DepositHoldPolicy
+	err := s.conn(ctx).GetContext(ctx, &p, query, scope, scopeID)
+	return p, err
+}
+
+// DeleteDepositHoldPolicy удаляет политику.
+func (s *Store) DeleteDepositHoldPolicy(ctx context.Context, scope, scopeID string) error {
+	const query = `
+DELETE FROM deposit_hold_policies
+WHERE scope = $1 AND scope_id = $2;
+`
+	res, err := s.conn(ctx).ExecContext(ctx, query, scope, scopeID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ResolveDepositHoldPolicy выбирает политику брони, а без неё — объявления.
+func (s *Store) ResolveDepositHoldPolicy(ctx context.Context, bookingID, listingID string) (repository.DepositHoldPolicy, error) {
+	const query = `
+SELECT scope, scope_id, action, updated_at
+FROM deposit_hold_policies
+WHERE (scope = 'booking' AND scope_id = $1)
+   OR (scope = 'listing' AND scope_id = $2 AND $2 <> '')
+ORDER BY scope = 'booking' DESC
+LIMIT 1;
+`
+	var p repository.DepositHoldPolicy
+	err := s.conn(ctx).GetContext(ctx, &p, query, bookingID, listingID)
+	return p, err
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"Payment-service/internal/repository"
+)
+
+// --- HostAccountRepo / HostTransferRepo ---
+
+var (
+	_ repository.HostAccountRepo  = (*Store)(nil)
+	_ repository.HostTransferRepo = (*Store)(nil)
+)
+
+const hostAccountColumns = `host_id, stripe_account_id, charges_enabled, payouts_enabled, details_submitted,
+       created_at, updated_at`
+
+const hostTransferColumns = `id, stripe_pi_id, kind, host_id, amount, paid_amount, reversed_amount, currency, status,
+       stripe_transfer_id, attempts, next_attempt_at, last_error, created_at, updated_at`
+
+// CreateHostAccount сохраняет аккаунт хоста. При конфликте по host_id
+// DO UPDATE без изменений нужен, чтобы RETURNING вернул существующую строку.
+func (s *Store) CreateHostAccount(ctx context.Context, a repository.HostAccount) (repository.HostAccount, error) {
+	const query = `
+INSERT INTO host_accounts (host_id, stripe_account_id, charges_enabled, payouts_enabled, details_submitted,
+                           created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, now(), now())
+ON CONFLICT (host_id) DO UPDATE SET host_id = EXCLUDED.host_id
+RETURNING ` + hostAccountColumns + `;
+`
+	var out repository.HostAccount
+	err := s.conn(ctx).GetContext(ctx, &out, query,
+		a.HostID, a.StripeAccountID, a.ChargesEnabled, a.PayoutsEnabled, a.DetailsSubmitted)
+	return out, err
+}
+
+// GetHostAccount возвращает аккаунт хоста.
+func (s *Store) GetHostAccount(ctx context.Context, hostID string) (repository.HostAccount, error) {
+	const query = `
+SELECT ` + hostAccountColumns + `
+FROM host_accounts
+WHERE host_id = $1;
+`
+	var a repository.HostAccount
+	err := s.conn(ctx).GetContext(ctx, &a, query, hostID)
+	return a, err
+}
+
+// UpdateHostAccountStatus обновляет флаги онбординга по Stripe Account ID.
+func (s *Store) UpdateHostAccountStatus(ctx context.Context, a repository.HostAccount) (repository.HostAccount, error) {
+	const query = `
+UPDATE host_accounts
+SET charges_enabled = $2, payouts_enabled = $3, details_submitted = $4, updated_at = now()
+WHERE stripe_account_id = $1
+RETURNING ` + hostAccountColumns + `;
+`
+	var out repository.HostAccount
+	err := s.conn(ctx).GetContext(ctx, &out, query,
+		a.StripeAccountID, a.ChargesEnabled, a.PayoutsEnabled, a.DetailsSubmitted)
+	return out, err
+}
+
+// UpsertHostTransfer ставит перевод в очередь, один на PaymentIntent, или
+// меняет его сумму. Строка остаётся заблокированной до конца транзакции, так
+// что параллельные возвраты по одному платежу применяются по очереди.
+func (s *Store) UpsertHostTransfer(ctx context.Context, t repository.HostTransfer) (repository.HostTransfer, error) {
+	const query = `
+INSERT INTO host_transfers (stripe_pi_id, kind, host_id, amount, currency, status, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, CASE WHEN $4::BIGINT > 0 THEN 'pending' ELSE 'canceled' END, now(), now())
+ON CONFLICT (stripe_pi_id) DO UPDATE
+SET amount = EXCLUDED.amount,
+    status = CASE
+        WHEN host_transfers.status = 'paid' THEN 'paid'
+        WHEN EXCLUDED.amount = 0 THEN 'canceled'
+        WHEN host_transfers.status = 'failed' THEN 'failed'
+        ELSE 'pending'
+    END,
+    updated_at = now()
+RETURNING ` + hostTransferColumns + `;
+`
+	var out repository.HostTransfer
+	err := s.conn(ctx).GetContext(ctx, &out, query, t.StripePIID, t.Kind, t.HostID, t.Amount, t.Currency)
+	return out, err
+}
+
+// ClaimHostTransfers занимает переводы так же, как ClaimOutboxEvents:
+// попытка засчитывается сразу, а next_attempt_at сдвигается на lease.
+// Переводы хостов без аккаунта или с отключёнными выплатами ждут.
+func (s *Store) ClaimHostTransfers(ctx context.Context, limit int, lease time.Duration) ([]repository.HostTransfer, error) {
+	query := `
+UPDATE host_transfers t
+SET attempts = t.attempts + 1, next_attempt_at = now() + make_interval(secs => $2), updated_at = now()
+FROM host_accounts a
+WHERE a.host_id = t.host_id
+  AND t.id IN (
+    SELECT p.id FROM host_transfers p
+    JOIN host_accounts pa ON pa.host_id = p.host_id AND pa.payouts_enabled
+    WHERE p.status = 'pending' AND p.next_attempt_at <= now()
+    ORDER BY p.id
+    LIMIT $1
+    FOR UPDATE OF p SKIP LOCKED
+)
+RETURNING t.id, t.stripe_pi_id, t.kind, t.host_id, t.amount, t.paid_amount, t.reversed_amount, t.currency, t.status,
+          t.stripe_transfer_id, t.attempts, t.next_attempt_at, t.last_error, t.created_at, t.updated_at,
+          a.stripe_account_id;
+`
+	var list []repository.HostTransfer
+	err := s.conn(ctx).SelectContext(ctx, &list, query, limit, lease.Seconds())
+	return list, err
+}
+
+// MarkHostTransferPaid отмечает перевод выполненным.
+func (s *Store) MarkHostTransferPaid(ctx context.Context, id int64, stripeTransferID string, paidAmount int64) (repository.HostTransfer, error) {
+	const query = `
+UPDATE host_transfers
+SET status = 'paid', stripe_transfer_id = $2, paid_amount = $3, last_error = NULL, updated_at = now()
+WHERE id = $1
+RETURNING ` + hostTransferColumns + `;
+`
+	var out repository.HostTransfer
+	err := s.conn(ctx).GetContext(ctx, &out, query, id, stripeTransferID, paidAmount)
+	return out, err
+}
+
+// MarkHostTransferFailed сохраняет ошибку перевода; final переводит его в failed.
+func (s *Store) MarkHostTransferFailed(ctx context.Context, id int64, errMsg string, nextAttemptAt time.Time, final bool) error {
+	const query = `
+UPDATE host_transfers
+SET last_error = $2, next_attempt_at = $3,
+    status = CASE WHEN $4::BOOLEAN THEN 'failed' ELSE status END,
+    updated_at = now()
+WHERE id = $1;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, id, errMsg, nextAttemptAt, final)
+	return err
+}
+
+// ListHostTransfers возвращает переводы хоста.
+func (s *Store) ListHostTransfers(ctx context.Context, hostID string, limit int) ([]repository.HostTransfer, error) {
+	const query = `
+SELECT ` + hostTransferColumns + `
+FROM host_transfers
+WHERE host_id = $1
+ORDER BY id DESC
+LIMIT $2;
+`
+	var list []repository.HostTransfer
+	err := s.conn(ctx).SelectContext(ctx, &list, query, hostID, limit)
+	return list, err
+}
+
+// EnqueueHostTransferReversal ставит возврат в очередь вместе с увеличением
+// reversed_amount, чтобы повторный пересчёт доли не поставил его дважды.
+func (s *Store) EnqueueHostTransferReversal(ctx context.Context, hostTransferID, amount int64) error {
+	const query = `
+WITH t AS (
+    UPDATE host_transfers
+    SET reversed_amount = reversed_amount + $2, updated_at = now()
+    WHERE id = $1
+    RETURNING id
+)
+INSERT INTO host_transfer_reversals (host_transfer_id, amount, created_at, updated_at)
+SELECT id, $2, now(), now() FROM t;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, hostTransferID, amount)
+	return err
+}
+
+// ClaimHostTransferReversals занимает возвраты так же, как ClaimHostTransfers.
+func (s *Store) ClaimHostTransferReversals(ctx context.Context, limit int, lease time.Duration) ([]repository.HostTransferReversal, error) {
+	const query = `
+UPDATE host_transfer_reversals r
+SET attempts = r.attempts + 1, next_attempt_at = now() + make_interval(secs => $2), updated_at = now()
+FROM host_transfers t
+WHERE t.id = r.host_transfer_id
+  AND r.id IN (
+    SELECT p.id FROM host_transfer_reversals p
+    WHERE p.status = 'pending' AND p.next_attempt_at <= now()
+    ORDER BY p.id
+    LIMIT $1
+    FOR UPDATE OF p SKIP LOCKED
+)
+RETURNING r.id, r.host_transfer_id, r.amount, r.status, r.stripe_reversal_id, r.attempts,
+          r.next_attempt_at, r.last_error, r.created_at, r.updated_at,
+          t.stripe_pi_id, t.kind, t.host_id, t.currency, t.stripe_transfer_id;
+`
+	var list []repository.HostTransferReversal
+	err := s.conn(ctx).SelectContext(ctx, &list, query, limit, lease.Seconds())
+	return list, err
+}
+
+// MarkHostTransferReversalPaid отмечает возврат выполненным.
+func (s *Store) MarkHostTransferReversalPaid(ctx context.Context, id int64, stripeReversalID string) error {
+	const query = `
+UPDATE host_transfer_reversals
+SET status = 'paid', stripe_reversal_id = $2, last_error = NULL, updated_at = now()
+WHERE id = $1;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, id, stripeReversalID)
+	return err
+}
+
+// MarkHostTransferReversalFailed сохраняет ошибку возврата; final переводит его в failed.
+func (s *Store) MarkHostTransferReversalFailed(ctx context.Context, id int64, errMsg string, nextAttemptAt time.Time, final bool) error {
+	const query = `
+UPDATE host_transfer_reversals
+SET last_error = $2, next_attempt_at = $3,
+    status = CASE WHEN $4::BOOLEAN THEN 'failed' ELSE status END,
+    updated_at = now()
+WHERE id = $1;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, id, errMsg, nextAttemptAt, final)
+	return err
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"Payment-service/internal/repository"
+)
+
+// --- IdempotencyRepo ---
+
+// idempotencyLockTimeout — через сколько незавершённый запрос считается брошенным
+// (например, процесс упал), и ключ можно занять снова.
+const idempotencyLockTimeout = time.Minute
+
+var _ repository.IdempotencyRepo = (*Store)(nil)
+
+// StartIdempotentRequest пытается занять ключ; просроченные записи перезаписываются.
+func (s *Store) StartIdempotentRequest(ctx context.Context, rec repository.IdempotencyRecord, ttl time.Duration) (*repository.IdempotencyRecord, error) {
+	const insert = `
+INSERT INTO idempotency_keys (user_id, idem_key, route, request_hash, created_at)
+VALUES ($1, $2, $3, $4, now())
+ON CONFLICT (user_id, idem_key, route) DO UPDATE
+SET request_hash = EXCLUDED.request_hash, response_code = NULL, response_body = NULL,
+    content_type = '', created_at = now(), completed_at = NULL
+WHERE idempotency_keys.created_at < now() - make_interval(secs => $5)
+   OR (idempotency_keys.completed_at IS NULL
+       AND idempotency_keys.created_at < now() - make_interval(secs => $6))
+RETURNING user_id;
+`
+	var owner string
+	err := s.conn(ctx).GetContext(ctx, &owner, insert,
+		rec.UserID, rec.Key, rec.Route, rec.RequestHash, ttl.Seconds(), idempotencyLockTimeout.Seconds(),
+	)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// Ключ уже занят живой записью
+	const query = `
+SELECT user_id, idem_key, route, request_hash, COALESCE(response_code, 0) AS response_code,
+       COALESCE(response_body, ''::bytea) AS response_body, content_type, created_at
+FROM idempotency_keys
+WHERE user_id = $1 AND idem_key = $2 AND route = $3;
+`
+	var existing repository.IdempotencyRecord
+	if err := s.conn(ctx).GetContext(ctx, &existing, query, rec.UserID, rec.Key, rec.Route); err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// CompleteIdempotentRequest сохраняет ответ на запрос.
+func (s *Store) CompleteIdempotentRequest(ctx context.Context, rec repository.IdempotencyRecord) error {
+	const query = `
+UPDATE idempotency_keys
+SET response_code = $4, response_body = $5, content_type = $6, completed_at = now()
+WHERE user_id = $1 AND idem_key = $2 AND route = $3;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query,
+		rec.UserID, rec.Key, rec.Route, rec.ResponseCode, rec.ResponseBody, rec.ContentType,
+	)
+	return err
+}
+
+// ReleaseIdempotentRequest удаляет незавершённую запись.
+func (s *Store) ReleaseIdempotentRequest(ctx context.Context, userID, key, route string) error {
+	const query = `
+DELETE FROM idempotency_keys
+WHERE user_id = $1 AND idem_key = $2 AND route = $3 AND completed_at IS NULL;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, userID, key, route)
+	return err
+}
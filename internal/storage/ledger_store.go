@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"Payment-service/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// --- LedgerRepo ---
+
+var _ repository.LedgerRepo = (*Store)(nil)
+
+// PostLedgerEntry сохраняет проводку и её строки в одной транзакции. Баланс
+// проводки дополнительно проверяет отложенный триггер при коммите.
+func (s *Store) PostLedgerEntry(ctx context.Context, e repository.NewLedgerEntry) (bool, error) {
+	const entryQuery = `
+INSERT INTO ledger_entries (idempotency_key, kind, reference, description, created_at)
+VALUES ($1, $2, $3, $4, now())
+ON CONFLICT (idempotency_key) DO NOTHING
+RETURNING id;
+`
+	// DO UPDATE вместо DO NOTHING, чтобы RETURNING вернул id существующего счёта
+	const accountQuery = `
+INSERT INTO ledger_accounts (type, owner_id, currency, created_at)
+VALUES ($1, $2, $3, now())
+ON CONFLICT (type, owner_id, currency) DO UPDATE SET type = EXCLUDED.type
+RETURNING id;
+`
+	const postingQuery = `
+INSERT INTO ledger_postings (entry_id, account_id, amount)
+VALUES ($1, $2, $3);
+`
+	created := false
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var entryID int64
+		err := tx.GetContext(ctx, &entryID, entryQuery, e.IdempotencyKey, e.Kind, e.Reference, e.Description)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, l := range e.Lines {
+			var accountID int64
+			if err := tx.GetContext(ctx, &accountID, accountQuery, l.Account.Type, l.Account.OwnerID, l.Account.Currency); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, postingQuery, entryID, accountID, l.Amount); err != nil {
+				return err
+			}
+		}
+		created = true
+		return nil
+	})
+	return created && err == nil, err
+}
+
+// ListLedgerEntries возвращает проводки от новых к старым вместе со строками.
+func (s *Store) ListLedgerEntries(ctx context.Context, f repository.LedgerEntryFilter) ([]repository.LedgerEntry, error) {
+	const entriesQuery = `
+SELECT e.id, e.idempotency_key, e.kind, e.reference, e.description, e.created_at
+FROM ledger_entries e
+WHERE ($1::BIGINT = 0 OR EXISTS (SELECT 1 FROM ledger_postings p WHERE p.entry_id = e.id AND p.account_id = $1))
+  AND ($2 = '' OR e.reference = $2)
+ORDER BY e.id DESC
+LIMIT $3;
+`
+	const postingsQuery = `
+SELECT p.id, p.entry_id, p.account_id, a.type AS account_type, a.owner_id, a.currency, p.amount
+FROM ledger_postings p
+JOIN ledger_accounts a ON a.id = p.account_id
+WHERE p.entry_id = ANY($1)
+ORDER BY p.id;
+`
+	var entries []repository.LedgerEntry
+	if err := s.conn(ctx).SelectContext(ctx, &entries, entriesQuery, f.AccountID, f.Reference, f.Limit); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	ids := make([]int64, len(entries))
+	byID := make(map[int64]*repository.LedgerEntry, len(entries))
+	for i := range entries {
+		ids[i] = entries[i].ID
+		byID[entries[i].ID] = &entries[i]
+	}
+	var postings []repository.LedgerPosting
+	if err := s.conn(ctx).SelectContext(ctx, &postings, postingsQuery, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	for _, p := range postings {
+		e := byID[p.EntryID]
+		e.Postings = append(e.Postings, p)
+	}
+	return entries, nil
+}
+
+const ledgerAccountQuery = `
+SELECT a.id, a.type, a.owner_id, a.currency, a.created_at, COALESCE(SUM(p.amount), 0) AS balance
+FROM ledger_accounts a
+LEFT JOIN ledger_postings p ON p.account_id = a.id
+`
+
+// ListLedgerAccounts возвращает счета с балансами.
+func (s *Store) ListLedgerAccounts(ctx context.Context, f repository.LedgerAccountFilter) ([]repository.LedgerAccount, error) {
+	query := ledgerAccountQuery + `
+WHERE ($1 = '' OR a.type = $1)
+  AND ($2 = '' OR a.owner_id = $2)
+  AND ($3 = '' OR a.currency = $3)
+GROUP BY a.id
+ORDER BY a.type, a.owner_id, a.currency;
+`
+	var list []repository.LedgerAccount
+	err := s.conn(ctx).SelectContext(ctx, &list, query, f.Type, f.OwnerID, f.Currency)
+	return list, err
+}
+
+// GetLedgerAccount возвращает счёт с балансом.
+func (s *Store) GetLedgerAccount(ctx context.Context, id int64) (repository.LedgerAccount, error) {
+	query := ledgerAccountQuery + `
+WHERE a.id = $1
+GROUP BY a.id;
+`
+	var a repository.LedgerAccount
+	err := s.conn(ctx).GetContext(ctx, &a, query, id)
+	return a, err
+}
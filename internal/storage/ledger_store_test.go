@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"Payment-service/internal/migrations"
+	"Payment-service/internal/repository"
+)
+
+// testStore подключается к TEST_DATABASE_URL и накатывает миграции.
+// Без переменной тест пропускается: Postgres есть не везде, где гоняют тесты.
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	s, err := InitStore(url)
+	if err != nil {
+		t.Fatalf("InitStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	m, err := migrations.New(s.DB)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return s
+}
+
+func TestPostLedgerEntry_UnbalancedIsRejectedAtCommit(t *testing.T) {
+	ctx := context.Background()
+	s := testStore(t)
+	// Проводки не удаляются, поэтому у каждого запуска свои ключи
+	ref := "pi_test_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	line := func(typ string, amount int64) repository.LedgerLine {
+		return repository.LedgerLine{Account: repository.LedgerAccountRef{Type: typ, Currency: "usd"}, Amount: amount}
+	}
+
+	_, err := s.PostLedgerEntry(ctx, repository.NewLedgerEntry{
+		IdempotencyKey: ref + ":unbalanced", Kind: "test", Reference: ref,
+		Lines: []repository.LedgerLine{line("platform", 100), line("fees", -90)},
+	})
+	if err == nil {
+		t.Fatal("unbalanced entry was committed")
+	}
+
+	// В общей транзакции ошибка тоже всплывает только при коммите
+	err = s.WithTx(ctx, func(ctx context.Context) error {
+		_, err := s.PostLedgerEntry(ctx, repository.NewLedgerEntry{
+			IdempotencyKey: ref + ":unbalanced-tx", Kind: "test", Reference: ref,
+			Lines: []repository.LedgerLine{line("platform", 100)},
+		})
+		return err
+	})
+	if err == nil {
+		t.Fatal("unbalanced entry was committed in an outer transaction")
+	}
+
+	posted, err := s.PostLedgerEntry(ctx, repository.NewLedgerEntry{
+		IdempotencyKey: ref + ":balanced", Kind: "test", Reference: ref,
+		Lines: []repository.LedgerLine{line("platform", 100), line("fees", -100)},
+	})
+	if err != nil || !posted {
+		t.Fatalf("balanced entry: posted = %v, err = %v", posted, err)
+	}
+
+	entries, err := s.ListLedgerEntries(ctx, repository.LedgerEntryFilter{Reference: ref, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListLedgerEntries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].IdempotencyKey != ref+":balanced" {
+		t.Errorf("entries = %+v, want only the balanced one", entries)
+	}
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectTimeout ограничивает запросы к базе на один scrape
+const collectTimeout = 5 * time.Second
+
+var (
+	paymentIntentsDesc = prometheus.NewDesc(
+		"payment_intents",
+		"Number of payment intents by status.",
+		[]string{"status"}, nil,
+	)
+	depositsDesc = prometheus.NewDesc(
+		"deposits",
+		"Number of deposits by status.",
+		[]string{"status"}, nil,
+	)
+	// Возвраты не вычитаются: чистую выручку показывает главная книга
+	succeededAmountDesc = prometheus.NewDesc(
+		"authorized_amount_succeeded",
+		"Amount of succeeded payment intents and captured amount of deposits in minor units "+
+			"by currency and kind (payment or deposit). Refunds are not subtracted.",
+		[]string{"currency", "kind"}, nil,
+	)
+)
+
+// StatsCollector отдаёт в Prometheus число платежей и депозитов по статусам
+// и суммы списаний по валютам. Запросы к базе сканируют таблицы целиком,
+// поэтому результат кэшируется на ttl и общий для параллельных scrape.
+type StatsCollector struct {
+	store *Store
+	ttl   time.Duration
+
+	mu          sync.Mutex
+	cached      []prometheus.Metric
+	collectedAt time.Time
+}
+
+// NewStatsCollector конструктор. ttl <= 0 — база читается при каждом scrape.
+func NewStatsCollector(s *Store, ttl time.Duration) *StatsCollector {
+	return &StatsCollector{store: s, ttl: ttl}
+}
+
+// Describe реализует prometheus.Collector.
+func (c *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- paymentIntentsDesc
+	ch <- depositsDesc
+	ch <- succeededAmountDesc
+}
+
+// Collect реализует prometheus.Collector. Ошибка запроса не валит scrape:
+// соответствующие серии просто пропускаются.
+func (c *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached == nil || time.Since(c.collectedAt) >= c.ttl {
+		c.cached = c.query()
+		c.collectedAt = time.Now()
+	}
+	for _, m := range c.cached {
+		ch <- m
+	}
+}
+
+// query читает статистику из базы
+func (c *StatsCollector) query() []prometheus.Metric {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	metrics := []prometheus.Metric{}
+	emit := func(m prometheus.Metric) { metrics = append(metrics, m) }
+	c.countByStatus(ctx, emit, paymentIntentsDesc, "payment_intents", `SELECT status, COUNT(*) FROM payment_intents GROUP BY status`)
+	c.countByStatus(ctx, emit, depositsDesc, "deposits", `SELECT status, COUNT(*) FROM deposits GROUP BY status`)
+
+	const capturedQuery = `
+    SELECT currency, 'payment' AS kind, SUM(amount) FROM payment_intents
+     WHERE status = 'succeeded' GROUP BY currency
+    UNION ALL
+    SELECT currency, 'deposit' AS kind, SUM(captured_amount) FROM deposits
+     WHERE captured_amount > 0 GROUP BY currency;
+    `
+	rows, err := c.store.DB.QueryContext(ctx, capturedQuery)
+	if err != nil {
+		slog.Error("metrics: captured amounts", "error", err)
+		return metrics
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var currency, kind string
+		var sum int64
+		if err := rows.Scan(&currency, &kind, &sum); err != nil {
+			slog.Error("metrics: captured amounts", "error", err)
+			return metrics
+		}
+		emit(prometheus.MustNewConstMetric(succeededAmountDesc, prometheus.GaugeValue, float64(sum), currency, kind))
+	}
+	return metrics
+}
+
+func (c *StatsCollector) countByStatus(ctx context.Context, emit func(prometheus.Metric), desc *prometheus.Desc, table, query string) {
+	rows, err := c.store.DB.QueryContext(ctx, query)
+	if err != nil {
+		slog.Error("metrics: count by status", "table", table, "error", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			slog.Error("metrics: count by status", "table", table, "error", err)
+			return
+		}
+		emit(prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(n), status))
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"Payment-service/internal/events"
+	"Payment-service/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// --- OutboxRepo ---
+
+var _ repository.OutboxRepo = (*Store)(nil)
+
+const outboxColumns = `id, aggregate_type, aggregate_id, event_type, payload, created_at,
+       published_at, attempts, last_error, next_attempt_at`
+
+// insertOutboxEvent добавляет событие в outbox в рамках транзакции изменения данных
+// и ставит его в очередь доставки всем активным webhook-подпискам на этот тип.
+func insertOutboxEvent(ctx context.Context, tx *sqlx.Tx, eventType, aggregateType, aggregateID string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	const query = `
+WITH evt AS (
+    INSERT INTO outbox_events (aggregate_type, aggregate_id, event_type, payload)
+    VALUES ($1, $2, $3, $4)
+    RETURNING id, event_type
+)
+INSERT INTO webhook_deliveries (subscription_id, event_id)
+SELECT s.id, evt.id
+FROM evt
+JOIN webhook_subscriptions s
+  ON s.active AND (cardinality(s.event_types) = 0 OR evt.event_type = ANY (s.event_types));
+`
+	_, err = tx.ExecContext(ctx, query, aggregateType, aggregateID, eventType, payload)
+	return err
+}
+
+// ClaimOutboxEvents занимает события для публикации. Берётся только самое раннее
+// неопубликованное событие агрегата: следующее станет доступно после его публикации.
+// SKIP LOCKED позволяет нескольким репликам работать параллельно.
+func (s *Store) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]repository.OutboxEvent, error) {
+	query := `
+UPDATE outbox_events
+SET attempts = attempts + 1, next_attempt_at = now() + make_interval(secs => $2)
+WHERE id IN (
+    SELECT o.id FROM outbox_events o
+    WHERE o.published_at IS NULL AND o.next_attempt_at <= now()
+      AND NOT EXISTS (
+          SELECT 1 FROM outbox_events p
+          WHERE p.aggregate_type = o.aggregate_type AND p.aggregate_id = o.aggregate_id
+            AND p.published_at IS NULL AND p.id < o.id
+      )
+    ORDER BY o.id
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING ` + outboxColumns + `;
+`
+	var list []repository.OutboxEvent
+	err := s.conn(ctx).SelectContext(ctx, &list, query, limit, lease.Seconds())
+	return list, err
+}
+
+// MarkOutboxEventPublished отмечает событие опубликованным.
+func (s *Store) MarkOutboxEventPublished(ctx context.Context, id int64) error {
+	const query = `
+UPDATE outbox_events
+SET published_at = now(), last_error = NULL
+WHERE id = $1;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkOutboxEventFailed сохраняет ошибку публикации и время следующей попытки.
+func (s *Store) MarkOutboxEventFailed(ctx context.Context, id int64, errMsg string, nextAttemptAt time.Time) error {
+	const query = `
+UPDATE outbox_events
+SET last_error = $2, next_attempt_at = $3
+WHERE id = $1;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, id, errMsg, nextAttemptAt)
+	return err
+}
+
+// --- Данные событий ---
+
+func paymentEvent(pi repository.PaymentIntent, previousStatus, source string) events.Payment {
+	return events.Payment{
+		PaymentIntentID: pi.StripePIID,
+		BookingID:       pi.BookingID,
+		ListingID:       pi.ListingID,
+		UserID:          pi.UserID,
+		Amount:          pi.Amount,
+		Currency:        pi.Currency,
+		Status:          pi.Status,
+		PreviousStatus:  previousStatus,
+		Source:          source,
+	}
+}
+
+func depositEvent(d repository.Deposit, previousStatus, source string) events.Deposit {
+	return events.Deposit{
+		DepositID:      d.StripePIID,
+		BookingID:      d.BookingID,
+		ListingID:      d.ListingID,
+		UserID:         d.UserID,
+		Amount:         d.Amount,
+		Currency:       d.Currency,
+		Status:         d.Status,
+		PreviousStatus: previousStatus,
+		Source:         source,
+		CapturedAmount: d.CapturedAmount,
+		CaptureReason:  d.CaptureReason,
+		HoldExpiresAt:  d.HoldExpiresAt,
+	}
+}
+
+func refundEvent(r repository.Refund) events.Refund {
+	return events.Refund{
+		RefundID:        r.ID,
+		StripeRefundID:  r.StripeRefundID,
+		PaymentIntentID: r.StripePIID,
+		Kind:            r.Kind,
+		Amount:          r.Amount,
+		Currency:        r.Currency,
+		Reason:          r.Reason,
+		Status:          r.Status,
+	}
+}
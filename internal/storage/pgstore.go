@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"Payment-service/internal/events"
 	"Payment-service/internal/repository"
 	"context"
+	"database/sql/driver"
+
+	"github.com/XSAM/otelsql"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Store оборачивает sqlx.DB и реализует репозитории.
@@ -13,11 +19,28 @@ type Store struct {
 }
 
 // InitStore открывает соединение по DATABASE_URL и возвращает Store.
+// Запросы пишут OpenTelemetry-спаны, если в контексте уже есть трассировка:
+// фоновые опросы воркеров и scrape метрик не порождают отдельных трасс.
 func InitStore(databaseURL string) (*Store, error) {
-	db, err := sqlx.Connect("postgres", databaseURL)
+	sqlDB, err := otelsql.Open("postgres", databaseURL,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
 	if err != nil {
 		return nil, err
 	}
+	db := sqlx.NewDb(sqlDB, "postgres")
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return &Store{DB: db}, nil
 }
 
@@ -33,15 +56,24 @@ func (s *Store) CreateCustomer(ctx context.Context, userID, email, stripeID stri
     VALUES ($1, $2, $3, now())
     ON CONFLICT (user_id) DO NOTHING;
     `
-	_, err := s.DB.ExecContext(ctx, query, userID, email, stripeID)
-	return err
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, query, userID, email, stripeID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, events.TypeCustomerCreated, events.AggregateCustomer, userID,
+			events.Customer{UserID: userID, StripeCustomerID: stripeID})
+	})
 }
 
 // GetCustomerByUserID возвращает Stripe Customer ID для заданного user_id.
 func (s *Store) GetCustomerByUserID(ctx context.Context, userID string) (string, error) {
 	var stripeID string
 	query := `SELECT stripe_id FROM customers WHERE user_id = $1`
-	err := s.DB.GetContext(ctx, &stripeID, query, userID)
+	err := s.conn(ctx).GetContext(ctx, &stripeID, query, userID)
 	return stripeID, err
 }
 
@@ -53,10 +85,26 @@ func (s *Store) SavePaymentMethod(ctx context.Context, pm repository.PaymentMeth
     VALUES ($1, $2, $3, $4, $5, $6, now())
     ON CONFLICT (stripe_pm_id) DO NOTHING;
     `
-	_, err := s.DB.ExecContext(ctx, query,
-		pm.UserID, pm.StripePMID, pm.Brand, pm.Last4, pm.ExpMonth, pm.ExpYear,
-	)
-	return err
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, query,
+			pm.UserID, pm.StripePMID, pm.Brand, pm.Last4, pm.ExpMonth, pm.ExpYear,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, events.TypePaymentMethodSaved, events.AggregatePaymentMethod, pm.StripePMID,
+			events.PaymentMethod{
+				UserID:          pm.UserID,
+				PaymentMethodID: pm.StripePMID,
+				Brand:           pm.Brand,
+				Last4:           pm.Last4,
+				ExpMonth:        pm.ExpMonth,
+				ExpYear:         pm.ExpYear,
+			})
+	})
 }
 
 // ListPaymentMethods возвращает все карты пользователя.
@@ -68,44 +116,70 @@ func (s *Store) ListPaymentMethods(ctx context.Context, userID string) ([]reposi
     WHERE user_id = $1
     ORDER BY created_at DESC;
     `
-	err := s.DB.SelectContext(ctx, &methods, query, userID)
+	err := s.conn(ctx).SelectContext(ctx, &methods, query, userID)
 	return methods, err
 }
 
-// CreatePaymentIntent сохраняет новый PaymentIntent в таблице payment_intents.
+// CreatePaymentIntent сохраняет новый PaymentIntent в таблице payment_intents
+// вместе с первой записью истории статусов и событием payment.created.
 func (s *Store) CreatePaymentIntent(ctx context.Context, pi repository.PaymentIntent) error {
 	query := `
     INSERT INTO payment_intents
-      (stripe_pi_id, booking_id, user_id, amount, currency, status, created_at, updated_at)
-    VALUES ($1, $2, $3, $4, $5, $6, now(), now())
+      (stripe_pi_id, booking_id, listing_id, host_id, user_id, amount, currency, status, created_at, updated_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now(), now())
     ON CONFLICT (stripe_pi_id) DO NOTHING;
     `
-	_, err := s.DB.ExecContext(ctx, query,
-		pi.StripePIID, pi.BookingID, pi.UserID, pi.Amount, pi.Currency, pi.Status,
-	)
-	return err
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, query,
+			pi.StripePIID, pi.BookingID, pi.ListingID, pi.HostID, pi.UserID, pi.Amount, pi.Currency, pi.Status,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		if err := insertStatusHistory(ctx, tx, pi.StripePIID, repository.RefundKindPayment, "", pi.Status, repository.StatusSourceAPI); err != nil {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, events.TypePaymentCreated, events.AggregatePayment, pi.StripePIID,
+			paymentEvent(pi, "", repository.StatusSourceAPI))
+	})
 }
 
-// UpdatePaymentIntentStatus обновляет статус существующего платежа.
-func (s *Store) UpdatePaymentIntentStatus(ctx context.Context, stripePIID, status string) error {
+// UpdatePaymentIntentStatus меняет статус платежа, если он всё ещё fromStatus.
+func (s *Store) UpdatePaymentIntentStatus(ctx context.Context, stripePIID, fromStatus, toStatus, source string) error {
 	query := `
     UPDATE payment_intents
-    SET status = $2, updated_at = now()
-    WHERE stripe_pi_id = $1;
+    SET status = $3, updated_at = now()
+    WHERE stripe_pi_id = $1 AND status = $2
+    RETURNING stripe_pi_id, booking_id, listing_id, host_id, user_id, amount, currency, status, created_at, updated_at;
     `
-	_, err := s.DB.ExecContext(ctx, query, stripePIID, status)
-	return err
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var pi repository.PaymentIntent
+		if err := tx.GetContext(ctx, &pi, query, stripePIID, fromStatus, toStatus); err != nil {
+			return transitionError(err)
+		}
+		if fromStatus == toStatus {
+			return nil
+		}
+		if err := insertStatusHistory(ctx, tx, stripePIID, repository.RefundKindPayment, fromStatus, toStatus, source); err != nil {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, events.TypePaymentStatusChanged, events.AggregatePayment, stripePIID,
+			paymentEvent(pi, fromStatus, source))
+	})
 }
 
 // GetPaymentIntentByID возвращает запись PaymentIntent по его Stripe ID.
 func (s *Store) GetPaymentIntentByID(ctx context.Context, stripePIID string) (repository.PaymentIntent, error) {
 	var pi repository.PaymentIntent
 	query := `
-    SELECT stripe_pi_id, booking_id, user_id, amount, currency, status, created_at, updated_at
+    SELECT stripe_pi_id, booking_id, listing_id, host_id, user_id, amount, currency, status, created_at, updated_at
     FROM payment_intents
     WHERE stripe_pi_id = $1;
     `
-	err := s.DB.GetContext(ctx, &pi, query, stripePIID)
+	err := s.conn(ctx).GetContext(ctx, &pi, query, stripePIID)
 	return pi, err
 }
 
@@ -140,8 +214,8 @@ func (s *Store) CreatePaymentIntentRecord(ctx context.Context, pi repository.Pay
 	return s.CreatePaymentIntent(ctx, pi)
 }
 
-func (s *Store) UpdatePaymentIntentStatusRecord(ctx context.Context, stripePIID, status string) error {
-	return s.UpdatePaymentIntentStatus(ctx, stripePIID, status)
+func (s *Store) UpdatePaymentIntentStatusRecord(ctx context.Context, stripePIID, fromStatus, toStatus, source string) error {
+	return s.UpdatePaymentIntentStatus(ctx, stripePIID, fromStatus, toStatus, source)
 }
 
 func (s *Store) GetPaymentIntentByIDRecord(ctx context.Context, stripePIID string) (repository.PaymentIntent, error) {
@@ -150,67 +224,115 @@ func (s *Store) GetPaymentIntentByIDRecord(ctx context.Context, stripePIID strin
 
 // --- DepositRepo ---
 
-// CreateDeposit сохраняет новый депозит в таблице deposits.
+const depositColumns = `stripe_pi_id, booking_id, listing_id, host_id, user_id, amount, currency, status, created_at, updated_at,
+       captured_amount, capture_reason, hold_expires_at, expiry_notified_at`
+
+// CreateDeposit сохраняет новый депозит в таблице deposits
+// вместе с первой записью истории статусов и событием deposit.created.
 func (s *Store) CreateDeposit(ctx context.Context, d repository.Deposit) error {
 	const query = `
 INSERT INTO deposits
-  (stripe_pi_id, booking_id, listing_id, user_id, amount, currency, status, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
+  (stripe_pi_id, booking_id, listing_id, host_id, user_id, amount, currency, status, hold_expires_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now(), now())
 ON CONFLICT (stripe_pi_id) DO NOTHING;
 `
-	_, err := s.DB.ExecContext(ctx, query,
-		d.StripePIID, d.BookingID, d.ListingID, d.UserID,
-		d.Amount, d.Currency, d.Status,
-	)
-	return err
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, query,
+			d.StripePIID, d.BookingID, d.ListingID, d.HostID, d.UserID,
+			d.Amount, d.Currency, d.Status, d.HoldExpiresAt,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		if err := insertStatusHistory(ctx, tx, d.StripePIID, repository.RefundKindDeposit, "", d.Status, repository.StatusSourceAPI); err != nil {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, events.TypeDepositCreated, events.AggregateDeposit, d.StripePIID,
+			depositEvent(d, "", repository.StatusSourceAPI))
+	})
 }
 
-// UpdateDepositStatus обновляет статус депозита.
-func (s *Store) UpdateDepositStatus(ctx context.Context, stripePIID, status string) error {
-	const query = `
+// UpdateDepositStatus меняет статус депозита, если он всё ещё fromStatus.
+func (s *Store) UpdateDepositStatus(ctx context.Context, stripePIID, fromStatus, toStatus, source string) error {
+	query := `
 UPDATE deposits
-SET status = $2, updated_at = now()
-WHERE stripe_pi_id = $1;
+SET status = $3, updated_at = now()
+WHERE stripe_pi_id = $1 AND status = $2
+RETURNING ` + depositColumns + `;
 `
-	_, err := s.DB.ExecContext(ctx, query, stripePIID, status)
-	return err
+	return s.updateDeposit(ctx, query, stripePIID, fromStatus, toStatus, source)
+}
+
+// UpdateDepositCapture сохраняет результат (возможно частичного) захвата депозита.
+func (s *Store) UpdateDepositCapture(ctx context.Context, stripePIID, fromStatus, toStatus string, capturedAmount int64, reason, source string) error {
+	query := `
+UPDATE deposits
+SET status = $3, captured_amount = $4, capture_reason = COALESCE(NULLIF($5, ''), capture_reason), updated_at = now()
+WHERE stripe_pi_id = $1 AND status = $2
+RETURNING ` + depositColumns + `;
+`
+	return s.updateDeposit(ctx, query, stripePIID, fromStatus, toStatus, source, capturedAmount, reason)
+}
+
+// updateDeposit выполняет UPDATE депозита с проверкой текущего статуса и пишет
+// историю и событие. Смена суммы захвата без смены статуса тоже публикуется.
+func (s *Store) updateDeposit(ctx context.Context, query, stripePIID, fromStatus, toStatus, source string, extra ...interface{}) error {
+	args := append([]interface{}{stripePIID, fromStatus, toStatus}, extra...)
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var d repository.Deposit
+		if err := tx.GetContext(ctx, &d, query, args...); err != nil {
+			return transitionError(err)
+		}
+		if fromStatus != toStatus {
+			if err := insertStatusHistory(ctx, tx, stripePIID, repository.RefundKindDeposit, fromStatus, toStatus, source); err != nil {
+				return err
+			}
+		} else if len(extra) == 0 {
+			return nil
+		}
+		return insertOutboxEvent(ctx, tx, events.TypeDepositStatusChanged, events.AggregateDeposit, stripePIID,
+			depositEvent(d, fromStatus, source))
+	})
 }
 
 // GetDepositByID возвращает депозит по stripe_pi_id.
 func (s *Store) GetDepositByID(ctx context.Context, stripePIID string) (repository.Deposit, error) {
 	const query = `
-SELECT stripe_pi_id, booking_id, listing_id, user_id, amount, currency, status, created_at, updated_at
+SELECT ` + depositColumns + `
 FROM deposits
 WHERE stripe_pi_id = $1;
 `
 	var d repository.Deposit
-	err := s.DB.GetContext(ctx, &d, query, stripePIID)
+	err := s.conn(ctx).GetContext(ctx, &d, query, stripePIID)
 	return d, err
 }
 
 // ListDepositsByBookingID возвращает депозиты для конкретной брони.
 func (s *Store) ListDepositsByBookingID(ctx context.Context, bookingID string) ([]repository.Deposit, error) {
 	const query = `
-SELECT stripe_pi_id, booking_id, listing_id, user_id, amount, currency, status, created_at, updated_at
+SELECT ` + depositColumns + `
 FROM deposits
 WHERE booking_id = $1
 ORDER BY created_at DESC;
 `
 	var list []repository.Deposit
-	err := s.DB.SelectContext(ctx, &list, query, bookingID)
+	err := s.conn(ctx).SelectContext(ctx, &list, query, bookingID)
 	return list, err
 }
 
 // ListDepositsByUserID возвращает депозиты для пользователя.
 func (s *Store) ListDepositsByUserID(ctx context.Context, userID string) ([]repository.Deposit, error) {
 	const query = `
-SELECT stripe_pi_id, booking_id, listing_id, user_id, amount, currency, status, created_at, updated_at
+SELECT ` + depositColumns + `
 FROM deposits
 WHERE user_id = $1
 ORDER BY created_at DESC;
 `
 	var list []repository.Deposit
-	err := s.DB.SelectContext(ctx, &list, query, userID)
+	err := s.conn(ctx).SelectContext(ctx, &list, query, userID)
 	return list, err
 }
 
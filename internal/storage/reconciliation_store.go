@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"Payment-service/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// --- ReconciliationRepo ---
+
+var _ repository.ReconciliationRepo = (*Store)(nil)
+
+// reconcileRecordQuery сводит платежи и депозиты к общему виду для сверки.
+const reconcileRecordQuery = `
+SELECT stripe_pi_id, 'payment' AS kind, status, amount, 0::BIGINT AS captured_amount, created_at
+FROM payment_intents
+UNION ALL
+SELECT stripe_pi_id, 'deposit' AS kind, status, amount, captured_amount, created_at
+FROM deposits
+`
+
+// ListReconcileRecords возвращает платежи и депозиты, созданные начиная с since.
+func (s *Store) ListReconcileRecords(ctx context.Context, since time.Time) ([]repository.ReconcileRecord, error) {
+	query := `
+SELECT stripe_pi_id, kind, status, amount, captured_amount
+FROM (` + reconcileRecordQuery + `) r
+WHERE created_at >= $1
+ORDER BY created_at;
+`
+	var list []repository.ReconcileRecord
+	err := s.conn(ctx).SelectContext(ctx, &list, query, since)
+	return list, err
+}
+
+// GetReconcileRecord ищет платёж или депозит по stripe_pi_id.
+func (s *Store) GetReconcileRecord(ctx context.Context, stripePIID string) (repository.ReconcileRecord, error) {
+	query := `
+SELECT stripe_pi_id, kind, status, amount, captured_amount
+FROM (` + reconcileRecordQuery + `) r
+WHERE stripe_pi_id = $1
+LIMIT 1;
+`
+	var rec repository.ReconcileRecord
+	err := s.conn(ctx).GetContext(ctx, &rec, query, stripePIID)
+	return rec, err
+}
+
+// CreateReconciliationReport сохраняет отчёт и его строки в одной транзакции.
+func (s *Store) CreateReconciliationReport(ctx context.Context, r repository.ReconciliationReport) (int64, error) {
+	const reportQuery = `
+INSERT INTO reconciliation_reports
+  (triggered_by, since, started_at, finished_at, matched, repaired, missing_locally, missing_remotely, unresolved)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id;
+`
+	const itemQuery = `
+INSERT INTO reconciliation_items (report_id, stripe_pi_id, kind, result, local_status, remote_status, detail)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+`
+	var id int64
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &id, reportQuery,
+			r.TriggeredBy, r.Since, r.StartedAt, r.FinishedAt,
+			r.Matched, r.Repaired, r.MissingLocally, r.MissingRemotely, r.Unresolved,
+		)
+		if err != nil {
+			return err
+		}
+		for _, it := range r.Items {
+			_, err := tx.ExecContext(ctx, itemQuery,
+				id, it.StripePIID, it.Kind, it.Result, it.LocalStatus, it.RemoteStatus, it.Detail)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+const reconciliationReportColumns = `id, triggered_by, since, started_at, finished_at,
+       matched, repaired, missing_locally, missing_remotely, unresolved`
+
+// ListReconciliationReports возвращает последние отчёты без строк.
+func (s *Store) ListReconciliationReports(ctx context.Context, limit int) ([]repository.ReconciliationReport, error) {
+	const query = `
+SELECT ` + reconciliationReportColumns + `
+FROM reconciliation_reports
+ORDER BY id DESC
+LIMIT $1;
+`
+	var list []repository.ReconciliationReport
+	err := s.conn(ctx).SelectContext(ctx, &list, query, limit)
+	return list, err
+}
+
+// GetReconciliationReport возвращает отчёт вместе со строками.
+func (s *Store) GetReconciliationReport(ctx context.Context, id int64) (repository.ReconciliationReport, error) {
+	const reportQuery = `
+SELECT ` + reconciliationReportColumns + `
+FROM reconciliation_reports
+WHERE id = $1;
+`
+	const itemsQuery = `
+SELECT id, report_id, stripe_pi_id, kind, result, local_status, remote_status, detail
+FROM reconciliation_items
+WHERE report_id = $1
+ORDER BY id;
+`
+	var r repository.ReconciliationReport
+	if err := s.conn(ctx).GetContext(ctx, &r, reportQuery, id); err != nil {
+		return r, err
+	}
+	if err := s.conn(ctx).SelectContext(ctx, &r.Items, itemsQuery, id); err != nil {
+		return repository.ReconciliationReport{}, err
+	}
+	return r, nil
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"Payment-service/internal/events"
+	"Payment-service/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// --- RefundRepo ---
+
+var _ repository.RefundRepo = (*Store)(nil)
+
+const refundColumns = `id, COALESCE(stripe_refund_id, '') AS stripe_refund_id, stripe_pi_id, kind,
+       amount, currency, reason, status, created_at, updated_at`
+
+// ReserveRefund сохраняет возврат, если суммарно возвраты не превышают captured.
+// Транзакционный advisory-lock по stripe_pi_id сериализует параллельные возвраты.
+func (s *Store) ReserveRefund(ctx context.Context, r repository.Refund, captured int64) (repository.Refund, error) {
+	const sumQuery = `
+SELECT COALESCE(SUM(amount), 0)
+FROM refunds
+WHERE stripe_pi_id = $1 AND status NOT IN ('failed', 'canceled');
+`
+	query := `
+INSERT INTO refunds (stripe_refund_id, stripe_pi_id, kind, amount, currency, reason, status, created_at, updated_at)
+VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $7, now(), now())
+RETURNING ` + refundColumns + `;
+`
+	var out repository.Refund
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, r.StripePIID); err != nil {
+			return err
+		}
+		var refunded int64
+		if err := tx.GetContext(ctx, &refunded, sumQuery, r.StripePIID); err != nil {
+			return err
+		}
+		if refunded+r.Amount > captured {
+			return repository.ErrRefundLimitExceeded
+		}
+		if err := tx.GetContext(ctx, &out, query,
+			r.StripeRefundID, r.StripePIID, r.Kind, r.Amount, r.Currency, r.Reason, r.Status,
+		); err != nil {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, events.TypeRefundCreated, events.AggregateRefund, refundAggregateID(out), refundEvent(out))
+	})
+	if err != nil {
+		return repository.Refund{}, err
+	}
+	return out, nil
+}
+
+// refundAggregateID — события возврата упорядочиваются по локальному ID
+func refundAggregateID(r repository.Refund) string {
+	return strconv.FormatInt(r.ID, 10)
+}
+
+// CompleteRefund проставляет Stripe Refund ID и статус возврату.
+func (s *Store) CompleteRefund(ctx context.Context, id int64, stripeRefundID, status string) error {
+	query := `
+UPDATE refunds
+SET stripe_refund_id = COALESCE(NULLIF($2, ''), stripe_refund_id), status = $3, updated_at = now()
+WHERE id = $1
+RETURNING ` + refundColumns + `;
+`
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var out repository.Refund
+		err := tx.GetContext(ctx, &out, query, id, stripeRefundID, status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return insertOutboxEvent(ctx, tx, events.TypeRefundStatusChanged, events.AggregateRefund, refundAggregateID(out), refundEvent(out))
+	})
+}
+
+// UpsertRefundByStripeID создаёт или обновляет возврат по stripe_refund_id.
+// Событие пишется, только если возврат новый или сменил статус.
+func (s *Store) UpsertRefundByStripeID(ctx context.Context, r repository.Refund) error {
+	query := `
+INSERT INTO refunds (stripe_refund_id, stripe_pi_id, kind, amount, currency, reason, status, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
+ON CONFLICT (stripe_refund_id) DO UPDATE
+SET status = EXCLUDED.status, updated_at = now()
+RETURNING ` + refundColumns + `;
+`
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var previous string
+		err := tx.GetContext(ctx, &previous, `SELECT status FROM refunds WHERE stripe_refund_id = $1 FOR UPDATE`, r.StripeRefundID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		existed := err == nil
+
+		var out repository.Refund
+		if err := tx.GetContext(ctx, &out, query,
+			r.StripeRefundID, r.StripePIID, r.Kind, r.Amount, r.Currency, r.Reason, r.Status,
+		); err != nil {
+			return err
+		}
+		switch {
+		case !existed:
+			return insertOutboxEvent(ctx, tx, events.TypeRefundCreated, events.AggregateRefund, refundAggregateID(out), refundEvent(out))
+		case previous != out.Status:
+			return insertOutboxEvent(ctx, tx, events.TypeRefundStatusChanged, events.AggregateRefund, refundAggregateID(out), refundEvent(out))
+		}
+		return nil
+	})
+}
+
+// SumActiveRefunds возвращает сумму возвратов, которые не завершились ошибкой.
+func (s *Store) SumActiveRefunds(ctx context.Context, stripePIID string) (int64, error) {
+	const query = `
+SELECT COALESCE(SUM(amount), 0)
+FROM refunds
+WHERE stripe_pi_id = $1 AND status NOT IN ('failed', 'canceled');
+`
+	var sum int64
+	err := s.conn(ctx).GetContext(ctx, &sum, query, stripePIID)
+	return sum, err
+}
+
+// ListRefundsByPaymentIntent возвращает возвраты по PaymentIntent.
+func (s *Store) ListRefundsByPaymentIntent(ctx context.Context, stripePIID string) ([]repository.Refund, error) {
+	query := `
+SELECT ` + refundColumns + `
+FROM refunds
+WHERE stripe_pi_id = $1
+ORDER BY created_at DESC;
+`
+	var list []repository.Refund
+	err := s.conn(ctx).SelectContext(ctx, &list, query, stripePIID)
+	return list, err
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"Payment-service/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+// --- ServiceKeyRepo ---
+
+var _ repository.ServiceKeyRepo = (*Store)(nil)
+
+const serviceKeyColumns = `id, name, key_prefix, key_hash, scopes, created_at, last_used_at, revoked_at`
+
+// serviceKeyRow — строка service_api_keys; TEXT[] читается через pq.StringArray
+type serviceKeyRow struct {
+	ID         int64          `db:"id"`
+	Name       string         `db:"name"`
+	KeyPrefix  string         `db:"key_prefix"`
+	KeyHash    string         `db:"key_hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	CreatedAt  time.Time      `db:"created_at"`
+	LastUsedAt *time.Time     `db:"last_used_at"`
+	RevokedAt  *time.Time     `db:"revoked_at"`
+}
+
+func (r serviceKeyRow) toModel() repository.ServiceKey {
+	return repository.ServiceKey{
+		ID:         r.ID,
+		Name:       r.Name,
+		KeyPrefix:  r.KeyPrefix,
+		KeyHash:    r.KeyHash,
+		Scopes:     []string(r.Scopes),
+		CreatedAt:  r.CreatedAt,
+		LastUsedAt: r.LastUsedAt,
+		RevokedAt:  r.RevokedAt,
+	}
+}
+
+// CreateServiceKey сохраняет новый ключ сервиса.
+func (s *Store) CreateServiceKey(ctx context.Context, key repository.ServiceKey) (repository.ServiceKey, error) {
+	query := `
+INSERT INTO service_api_keys (name, key_prefix, key_hash, scopes, created_at)
+VALUES ($1, $2, $3, $4, now())
+RETURNING ` + serviceKeyColumns + `;
+`
+	var row serviceKeyRow
+	err := s.conn(ctx).GetContext(ctx, &row, query, key.Name, key.KeyPrefix, key.KeyHash, textArray(key.Scopes))
+	return row.toModel(), err
+}
+
+// GetActiveServiceKeyByHash ищет неотозванный ключ по хэшу.
+func (s *Store) GetActiveServiceKeyByHash(ctx context.Context, keyHash string) (repository.ServiceKey, error) {
+	query := `SELECT ` + serviceKeyColumns + ` FROM service_api_keys WHERE key_hash = $1 AND revoked_at IS NULL;`
+	var row serviceKeyRow
+	err := s.conn(ctx).GetContext(ctx, &row, query, keyHash)
+	return row.toModel(), err
+}
+
+// ListServiceKeys возвращает все ключи, включая отозванные.
+func (s *Store) ListServiceKeys(ctx context.Context) ([]repository.ServiceKey, error) {
+	query := `SELECT ` + serviceKeyColumns + ` FROM service_api_keys ORDER BY id;`
+	var rows []serviceKeyRow
+	if err := s.conn(ctx).SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+	out := make([]repository.ServiceKey, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, r.toModel())
+	}
+	return out, nil
+}
+
+// RevokeServiceKey отзывает ключ; повторный отзыв не меняет revoked_at.
+func (s *Store) RevokeServiceKey(ctx context.Context, id int64) error {
+	const query = `UPDATE service_api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1;`
+	res, err := s.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// InsertServiceAudit пишет вызов в журнал и обновляет last_used_at ключа.
+func (s *Store) InsertServiceAudit(ctx context.Context, e repository.ServiceAuditEntry) error {
+	const query = `
+WITH touched AS (
+    UPDATE service_api_keys SET last_used_at = now() WHERE id = $1
+)
+INSERT INTO service_audit_log (key_id, method, path, on_behalf_of, status_code, client_ip, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, now());
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, e.KeyID, e.Method, e.Path, e.OnBehalfOf, e.StatusCode, e.ClientIP)
+	return err
+}
+
+// ListServiceAudit возвращает последние вызовы по ключу.
+func (s *Store) ListServiceAudit(ctx context.Context, keyID int64, limit int) ([]repository.ServiceAuditEntry, error) {
+	const query = `
+SELECT id, key_id, method, path, on_behalf_of, status_code, client_ip, created_at
+FROM service_audit_log
+WHERE key_id = $1
+ORDER BY id DESC
+LIMIT $2;
+`
+	list := []repository.ServiceAuditEntry{}
+	err := s.conn(ctx).SelectContext(ctx, &list, query, keyID, limit)
+	return list, err
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"Payment-service/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// --- StatusHistoryRepo ---
+
+var _ repository.StatusHistoryRepo = (*Store)(nil)
+
+// insertStatusHistory дописывает переход в status_history в рамках транзакции смены статуса.
+// Пустой fromStatus — запись о создании платежа.
+func insertStatusHistory(ctx context.Context, tx *sqlx.Tx, stripePIID, kind, fromStatus, toStatus, source string) error {
+	const query = `
+INSERT INTO status_history (stripe_pi_id, kind, from_status, to_status, source)
+VALUES ($1, $2, $3, $4, $5);
+`
+	_, err := tx.ExecContext(ctx, query, stripePIID, kind, fromStatus, toStatus, source)
+	return err
+}
+
+// transitionError переводит «UPDATE ... WHERE status = from не нашёл строку»
+// в ErrStatusChanged: статус успели изменить.
+func transitionError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrStatusChanged
+	}
+	return err
+}
+
+// ListStatusHistory возвращает историю статусов PaymentIntent в порядке переходов.
+func (s *Store) ListStatusHistory(ctx context.Context, stripePIID, kind string) ([]repository.StatusChange, error) {
+	const query = `
+SELECT id, stripe_pi_id, kind, from_status, to_status, source, created_at
+FROM status_history
+WHERE stripe_pi_id = $1 AND kind = $2
+ORDER BY id;
+`
+	list := []repository.StatusChange{}
+	err := s.conn(ctx).SelectContext(ctx, &list, query, stripePIID, kind)
+	return list, err
+}
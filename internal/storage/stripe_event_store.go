@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"Payment-service/internal/repository"
+)
+
+// --- StripeEventRepo ---
+
+var _ repository.StripeEventRepo = (*Store)(nil)
+
+const stripeEventColumns = `id, type, payload, received_at, processed_at, error, attempts, next_attempt_at`
+
+// SaveStripeEvent сохраняет событие, дубликаты по id игнорируются.
+func (s *Store) SaveStripeEvent(ctx context.Context, e repository.StripeEvent, lease time.Duration) (bool, error) {
+	const query = `
+INSERT INTO stripe_events (id, type, payload, received_at, attempts, next_attempt_at)
+VALUES ($1, $2, $3, now(), 1, now() + make_interval(secs => $4))
+ON CONFLICT (id) DO NOTHING;
+`
+	res, err := s.conn(ctx).ExecContext(ctx, query, e.ID, e.Type, e.Payload, lease.Seconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// GetStripeEvent возвращает событие по id.
+func (s *Store) GetStripeEvent(ctx context.Context, id string) (repository.StripeEvent, error) {
+	query := `SELECT ` + stripeEventColumns + ` FROM stripe_events WHERE id = $1;`
+	var e repository.StripeEvent
+	err := s.conn(ctx).GetContext(ctx, &e, query, id)
+	return e, err
+}
+
+// ListStripeEvents возвращает последние события, по желанию только упавшие.
+func (s *Store) ListStripeEvents(ctx context.Context, failedOnly bool, limit int) ([]repository.StripeEvent, error) {
+	query := `
+SELECT ` + stripeEventColumns + `
+FROM stripe_events
+WHERE NOT $1 OR (processed_at IS NULL AND error IS NOT NULL)
+ORDER BY received_at DESC
+LIMIT $2;
+`
+	var list []repository.StripeEvent
+	err := s.conn(ctx).SelectContext(ctx, &list, query, failedOnly, limit)
+	return list, err
+}
+
+// ClaimDueStripeEvents занимает события для повторной обработки.
+// SKIP LOCKED позволяет нескольким репликам работать параллельно.
+func (s *Store) ClaimDueStripeEvents(ctx context.Context, limit int, lease time.Duration) ([]repository.StripeEvent, error) {
+	query := `
+UPDATE stripe_events
+SET attempts = attempts + 1, next_attempt_at = now() + make_interval(secs => $2)
+WHERE id IN (
+    SELECT id FROM stripe_events
+    WHERE processed_at IS NULL AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING ` + stripeEventColumns + `;
+`
+	var list []repository.StripeEvent
+	err := s.conn(ctx).SelectContext(ctx, &list, query, limit, lease.Seconds())
+	return list, err
+}
+
+// MarkStripeEventProcessed отмечает событие обработанным.
+func (s *Store) MarkStripeEventProcessed(ctx context.Context, id string) error {
+	const query = `
+UPDATE stripe_events
+SET processed_at = now(), error = NULL, next_attempt_at = NULL
+WHERE id = $1;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkStripeEventFailed сохраняет ошибку обработки и время следующей попытки.
+func (s *Store) MarkStripeEventFailed(ctx context.Context, id, errMsg string, nextAttemptAt *time.Time) error {
+	const query = `
+UPDATE stripe_events
+SET error = $2, next_attempt_at = $3
+WHERE id = $1;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, id, errMsg, nextAttemptAt)
+	return err
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+
+	"Payment-service/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var _ repository.UnitOfWork = (*Store)(nil)
+
+// txKey — ключ контекста, под которым лежит открытая транзакция
+type txKey struct{}
+
+// querier — общее у *sqlx.DB и *sqlx.Tx
+type querier interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// WithTx выполняет fn в транзакции. Все методы Store, вызванные с ctx из fn,
+// работают внутри неё. Вложенный WithTx присоединяется к внешней транзакции.
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// inTx — WithTx для методов Store, которым нужен сам *sqlx.Tx.
+func (s *Store) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	return s.WithTx(ctx, func(ctx context.Context) error {
+		return fn(ctx.Value(txKey{}).(*sqlx.Tx))
+	})
+}
+
+// conn возвращает транзакцию из ctx, если она открыта, иначе пул соединений.
+func (s *Store) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return s.DB
+}
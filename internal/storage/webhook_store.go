@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"Payment-service/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+// --- WebhookRepo ---
+
+var _ repository.WebhookRepo = (*Store)(nil)
+
+const webhookSubscriptionColumns = `id, url, secret, event_types, description, active, created_at, updated_at`
+
+const webhookDeliveryColumns = `d.id, d.subscription_id, d.event_id, e.event_type, d.status, d.attempts,
+       d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at`
+
+// webhookSubscriptionRow — строка webhook_subscriptions; TEXT[] читается через pq.StringArray
+type webhookSubscriptionRow struct {
+	ID          int64          `db:"id"`
+	URL         string         `db:"url"`
+	Secret      string         `db:"secret"`
+	EventTypes  pq.StringArray `db:"event_types"`
+	Description string         `db:"description"`
+	Active      bool           `db:"active"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+}
+
+func (r webhookSubscriptionRow) toModel() repository.WebhookSubscription {
+	return repository.WebhookSubscription{
+		ID:          r.ID,
+		URL:         r.URL,
+		Secret:      r.Secret,
+		EventTypes:  []string(r.EventTypes),
+		Description: r.Description,
+		Active:      r.Active,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// textArray не даёт записать NULL вместо пустого массива в TEXT[]
+func textArray(types []string) pq.StringArray {
+	if types == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(types)
+}
+
+// CreateWebhookSubscription сохраняет новую подписку.
+func (s *Store) CreateWebhookSubscription(ctx context.Context, sub repository.WebhookSubscription) (repository.WebhookSubscription, error) {
+	query := `
+INSERT INTO webhook_subscriptions (url, secret, event_types, description, active, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, now(), now())
+RETURNING ` + webhookSubscriptionColumns + `;
+`
+	var row webhookSubscriptionRow
+	err := s.conn(ctx).GetContext(ctx, &row, query,
+		sub.URL, sub.Secret, textArray(sub.EventTypes), sub.Description, sub.Active,
+	)
+	return row.toModel(), err
+}
+
+// GetWebhookSubscription возвращает подписку по id.
+func (s *Store) GetWebhookSubscription(ctx context.Context, id int64) (repository.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1;`
+	var row webhookSubscriptionRow
+	err := s.conn(ctx).GetContext(ctx, &row, query, id)
+	return row.toModel(), err
+}
+
+// ListWebhookSubscriptions возвращает все подписки.
+func (s *Store) ListWebhookSubscriptions(ctx context.Context) ([]repository.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions ORDER BY id;`
+	var rows []webhookSubscriptionRow
+	if err := s.conn(ctx).SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+	out := make([]repository.WebhookSubscription, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, r.toModel())
+	}
+	return out, nil
+}
+
+// UpdateWebhookSubscription сохраняет изменяемые поля подписки.
+func (s *Store) UpdateWebhookSubscription(ctx context.Context, sub repository.WebhookSubscription) (repository.WebhookSubscription, error) {
+	query := `
+UPDATE webhook_subscriptions
+SET url = $2, secret = $3, event_types = $4, description = $5, active = $6, updated_at = now()
+WHERE id = $1
+RETURNING ` + webhookSubscriptionColumns + `;
+`
+	var row webhookSubscriptionRow
+	err := s.conn(ctx).GetContext(ctx, &row, query,
+		sub.ID, sub.URL, sub.Secret, textArray(sub.EventTypes), sub.Description, sub.Active,
+	)
+	return row.toModel(), err
+}
+
+// DeleteWebhookSubscription удаляет подписку вместе с журналом её доставок.
+func (s *Store) DeleteWebhookSubscription(ctx context.Context, id int64) error {
+	res, err := s.conn(ctx).ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// ListWebhookDeliveries возвращает последние доставки подписки.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]repository.WebhookDelivery, error) {
+	query := `
+SELECT ` + webhookDeliveryColumns + `
+FROM webhook_deliveries d
+JOIN outbox_events e ON e.id = d.event_id
+WHERE d.subscription_id = $1
+ORDER BY d.id DESC
+LIMIT $2;
+`
+	list := []repository.WebhookDelivery{}
+	err := s.conn(ctx).SelectContext(ctx, &list, query, subscriptionID, limit)
+	return list, err
+}
+
+// ClaimWebhookDeliveries занимает доставки для отправки.
+// SKIP LOCKED позволяет нескольким репликам работать параллельно.
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]repository.WebhookDispatch, error) {
+	const query = `
+WITH due AS (
+    SELECT d.id
+    FROM webhook_deliveries d
+    JOIN webhook_subscriptions s ON s.id = d.subscription_id
+    WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND s.active
+    ORDER BY d.next_attempt_at
+    LIMIT $1
+    FOR UPDATE OF d SKIP LOCKED
+), claimed AS (
+    UPDATE webhook_deliveries d
+    SET attempts = d.attempts + 1, next_attempt_at = now() + make_interval(secs => $2)
+    FROM due
+    WHERE d.id = due.id
+    RETURNING d.id, d.subscription_id, d.event_id, d.attempts
+)
+SELECT c.id AS delivery_id, c.attempts, s.url, s.secret,
+       e.id AS event_id, e.event_type, e.aggregate_type, e.aggregate_id, e.payload, e.created_at AS occurred_at
+FROM claimed c
+JOIN webhook_subscriptions s ON s.id = c.subscription_id
+JOIN outbox_events e ON e.id = c.event_id
+ORDER BY e.id;
+`
+	var list []repository.WebhookDispatch
+	err := s.conn(ctx).SelectContext(ctx, &list, query, limit, lease.Seconds())
+	return list, err
+}
+
+// MarkWebhookDelivered отмечает доставку успешной.
+func (s *Store) MarkWebhookDelivered(ctx context.Context, id int64, statusCode int) error {
+	const query = `
+UPDATE webhook_deliveries
+SET status = 'delivered', last_status_code = $2, last_error = NULL, delivered_at = now()
+WHERE id = $1;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, id, statusCode)
+	return err
+}
+
+// MarkWebhookDeliveryFailed сохраняет ошибку доставки и время следующей попытки.
+func (s *Store) MarkWebhookDeliveryFailed(ctx context.Context, id int64, statusCode int, errMsg string, nextAttemptAt *time.Time) error {
+	const query = `
+UPDATE webhook_deliveries
+SET status = CASE WHEN $4::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+    last_status_code = NULLIF($2, 0), last_error = $3,
+    next_attempt_at = COALESCE($4, next_attempt_at)
+WHERE id = $1;
+`
+	_, err := s.conn(ctx).ExecContext(ctx, query, id, statusCode, errMsg, nextAttemptAt)
+	return err
+}
+
+// RetryWebhookDelivery возвращает доставку в очередь с немедленной попыткой.
+// Счётчик попыток и ошибка сбрасываются, иначе «мёртвая» доставка сразу
+// упрётся в лимит попыток снова.
+func (s *Store) RetryWebhookDelivery(ctx context.Context, id int64) error {
+	const query = `
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, last_error = NULL, next_attempt_at = now(), delivered_at = NULL
+WHERE id = $1;
+`
+	res, err := s.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package stripeadapter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	stripepkg "github.com/stripe/stripe-go/v74"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("Payment-service/internal/stripeadapter")
+
+// startCall opens a client span for a Stripe API call. The returned function
+// ends the span, records metrics and logs the call with the given fields
+// (typically IDs of the Stripe objects involved); the call must use the
+// returned context.
+func (c *Client) startCall(ctx context.Context, op string, args ...any) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "stripe."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("stripe.operation", op)),
+	)
+	return ctx, func(err error) {
+		observe(op, start, err)
+		logger := c.log.With(args...).With("operation", op, "duration", time.Since(start))
+		if err == nil {
+			logger.DebugContext(ctx, "stripe call")
+			span.End()
+			return
+		}
+
+		var stripeErr *stripepkg.Error
+		if errors.As(err, &stripeErr) {
+			span.SetAttributes(
+				attribute.String("stripe.error_type", string(stripeErr.Type)),
+				attribute.String("stripe.error_code", string(stripeErr.Code)),
+				attribute.String("stripe.request_id", stripeErr.RequestID),
+			)
+			logger = logger.With(
+				"stripe_error_type", stripeErr.Type,
+				"stripe_error_code", stripeErr.Code,
+				"stripe_request_id", stripeErr.RequestID,
+			)
+		}
+		logger.WarnContext(ctx, "stripe call failed", "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+	}
+}
@@ -3,31 +3,46 @@ package stripeadapter
 
 import (
 	"context"
-	"github.com/stripe/stripe-go/v74/paymentmethod"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
 	stripepkg "github.com/stripe/stripe-go/v74"
-	stripeCustomer "github.com/stripe/stripe-go/v74/customer"
-	stripePayment "github.com/stripe/stripe-go/v74/paymentintent"
-	stripeSetup "github.com/stripe/stripe-go/v74/setupintent"
+	stripeclient "github.com/stripe/stripe-go/v74/client"
+
+	"Payment-service/internal/gateway"
 )
 
 // Client wraps Stripe operations needed by the Payment-service.
-type Client struct{}
+// Each Client owns its own API backend, so several keys can coexist.
+type Client struct {
+	api *stripeclient.API
+	log *slog.Logger
+}
 
-// NewClient sets the Stripe API key and returns a new Client.
-func NewClient(apiKey string) *Client {
-	stripepkg.Key = apiKey
-	return &Client{}
+var _ gateway.PaymentGateway = (*Client)(nil)
+
+// NewClient returns a new Client bound to the given Stripe secret key.
+func NewClient(apiKey string, logger *slog.Logger) *Client {
+	api := &stripeclient.API{}
+	api.Init(apiKey, nil)
+	return &Client{api: api, log: logger}
 }
 
 // CreateCustomer creates a Stripe Customer with given email and metadata.user_id.
 // Returns the Stripe Customer ID (cus_...).
 func (c *Client) CreateCustomer(ctx context.Context, email, userID string) (string, error) {
+	ctx, done := c.startCall(ctx, "create_customer", "user_id", userID)
 	params := &stripepkg.CustomerParams{
 		Email: stripepkg.String(email),
 	}
+	params.Context = ctx
+	setIdempotencyKey(ctx, &params.Params, "customer")
 	params.AddMetadata("user_id", userID)
-	cust, err := stripeCustomer.New(params)
+	cust, err := c.api.Customers.New(params)
+	done(err)
 	if err != nil {
 		return "", err
 	}
@@ -35,65 +50,268 @@ func (c *Client) CreateCustomer(ctx context.Context, email, userID string) (stri
 }
 
 // CreateSetupIntent issues a SetupIntent to save and verify a card for a Customer.
-// Usage should be one of stripe.SetupIntentUsageOffSession or stripe.SetupIntentUsageOnSession.
-func (c *Client) CreateSetupIntent(ctx context.Context, customerID string, usage stripepkg.SetupIntentUsage) (*stripepkg.SetupIntent, error) {
+// Usage should be one of gateway.UsageOffSession or gateway.UsageOnSession.
+func (c *Client) CreateSetupIntent(ctx context.Context, customerID, usage string) (*gateway.SetupIntent, error) {
+	ctx, done := c.startCall(ctx, "create_setup_intent", "stripe_customer_id", customerID)
 	params := &stripepkg.SetupIntentParams{
 		Customer:           stripepkg.String(customerID),
 		PaymentMethodTypes: stripepkg.StringSlice([]string{"card"}),
-		Usage:              stripepkg.String(string(usage)),
+		Usage:              stripepkg.String(usage),
 	}
-	si, err := stripeSetup.New(params)
+	params.Context = ctx
+	setIdempotencyKey(ctx, &params.Params, "setup_intent")
+	si, err := c.api.SetupIntents.New(params)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
-	return si, nil
+	return toSetupIntent(si), nil
 }
 
-// CreatePaymentIntent creates a manual-capture PaymentIntent for a given Customer.
-// amount is in the smallest currency unit (cents or kopecks).
-// bookingID and userID are added to metadata for reference.
-func (c *Client) CreatePaymentIntent(ctx context.Context, customerID string, amount int64, currency, bookingID, userID string, listingID string) (*stripepkg.PaymentIntent, error) {
+// CreatePaymentIntent creates a PaymentIntent for a given Customer.
+// bookingID, userID and listingID are added to metadata for reference.
+func (c *Client) CreatePaymentIntent(ctx context.Context, p gateway.CreatePaymentIntentParams) (*gateway.PaymentIntent, error) {
+	ctx, done := c.startCall(ctx, "create_payment_intent", "stripe_customer_id", p.CustomerID, "booking_id", p.BookingID)
 	params := &stripepkg.PaymentIntentParams{
-		Amount:             stripepkg.Int64(amount),
-		Currency:           stripepkg.String(currency),
-		Customer:           stripepkg.String(customerID),
-		CaptureMethod:      stripepkg.String("manual"),
+		Amount:             stripepkg.Int64(p.Amount),
+		Currency:           stripepkg.String(p.Currency),
+		Customer:           stripepkg.String(p.CustomerID),
 		PaymentMethodTypes: stripepkg.StringSlice([]string{"card"}),
 	}
-	params.AddMetadata("booking_id", bookingID)
-	params.AddMetadata("user_id", userID)
-	params.AddMetadata("listing_id", listingID)
+	params.Context = ctx
+	setIdempotencyKey(ctx, &params.Params, "payment_intent")
+	if p.ManualCapture {
+		params.CaptureMethod = stripepkg.String(string(stripepkg.PaymentIntentCaptureMethodManual))
+	}
+	if p.PaymentMethodID != "" {
+		params.PaymentMethod = stripepkg.String(p.PaymentMethodID)
+		params.Confirm = stripepkg.Bool(true)
+		params.OffSession = stripepkg.Bool(true)
+	}
+	params.AddMetadata("booking_id", p.BookingID)
+	params.AddMetadata("user_id", p.UserID)
+	if p.ListingID != "" {
+		params.AddMetadata("listing_id", p.ListingID)
+	}
 
-	pi, err := stripePayment.New(params)
+	pi, err := c.api.PaymentIntents.New(params)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
-	return pi, nil
+	return ToPaymentIntent(pi), nil
 }
 
 // CapturePaymentIntent captures (finalizes) a previously created & confirmed PaymentIntent.
+// Stripe releases the uncaptured remainder automatically on partial capture.
 // Returns the updated PaymentIntent with status "succeeded" on success.
-func (c *Client) CapturePaymentIntent(ctx context.Context, paymentIntentID string) (*stripepkg.PaymentIntent, error) {
-	pi, err := stripePayment.Capture(paymentIntentID, nil)
+func (c *Client) CapturePaymentIntent(ctx context.Context, paymentIntentID string, amountToCapture int64) (*gateway.PaymentIntent, error) {
+	ctx, done := c.startCall(ctx, "capture_payment_intent", "payment_intent_id", paymentIntentID)
+	params := &stripepkg.PaymentIntentCaptureParams{}
+	params.Context = ctx
+	setIdempotencyKey(ctx, &params.Params, "capture")
+	if amountToCapture > 0 {
+		params.AmountToCapture = stripepkg.Int64(amountToCapture)
+	}
+	pi, err := c.api.PaymentIntents.Capture(paymentIntentID, params)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
-	return pi, nil
+	return ToPaymentIntent(pi), nil
 }
 
 // CancelPaymentIntent cancels a previously authorized PaymentIntent, releasing the hold.
 // Returns the updated PaymentIntent with status "canceled" on success.
-func (c *Client) CancelPaymentIntent(ctx context.Context, paymentIntentID string) (*stripepkg.PaymentIntent, error) {
-	pi, err := stripePayment.Cancel(paymentIntentID, nil)
+func (c *Client) CancelPaymentIntent(ctx context.Context, paymentIntentID string) (*gateway.PaymentIntent, error) {
+	ctx, done := c.startCall(ctx, "cancel_payment_intent", "payment_intent_id", paymentIntentID)
+	params := &stripepkg.PaymentIntentCancelParams{}
+	params.Context = ctx
+	setIdempotencyKey(ctx, &params.Params, "cancel")
+	pi, err := c.api.PaymentIntents.Cancel(paymentIntentID, params)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
-	return pi, nil
+	return ToPaymentIntent(pi), nil
 }
-func (c *Client) RetrieveCard(pmID string) (*stripepkg.PaymentMethod, error) {
-	pm, err := paymentmethod.Get(pmID, nil)
+
+// GetPaymentIntent fetches a PaymentIntent; an unknown ID maps to gateway.ErrNotFound.
+// The latest charge is expanded so that CaptureBefore is set for authorized cards.
+func (c *Client) GetPaymentIntent(ctx context.Context, paymentIntentID string) (*gateway.PaymentIntent, error) {
+	ctx, done := c.startCall(ctx, "retrieve_payment_intent", "payment_intent_id", paymentIntentID)
+	params := &stripepkg.PaymentIntentParams{}
+	params.Context = ctx
+	params.AddExpand("latest_charge")
+	pi, err := c.api.PaymentIntents.Get(paymentIntentID, params)
+	done(err)
+	var stripeErr *stripepkg.Error
+	if errors.As(err, &stripeErr) && stripeErr.Code == stripepkg.ErrorCodeResourceMissing {
+		return nil, fmt.Errorf("%w: payment intent %s", gateway.ErrNotFound, paymentIntentID)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return pm, nil
+	return ToPaymentIntent(pi), nil
+}
+
+// ListPaymentIntents pages through all PaymentIntents created since the given time.
+func (c *Client) ListPaymentIntents(ctx context.Context, since time.Time) ([]gateway.PaymentIntent, error) {
+	ctx, done := c.startCall(ctx, "list_payment_intents", "since", since)
+	params := &stripepkg.PaymentIntentListParams{}
+	params.Context = ctx
+	params.Limit = stripepkg.Int64(100)
+	params.CreatedRange = &stripepkg.RangeQueryParams{GreaterThanOrEqual: since.Unix()}
+	it := c.api.PaymentIntents.List(params)
+	var out []gateway.PaymentIntent
+	for it.Next() {
+		out = append(out, *ToPaymentIntent(it.PaymentIntent()))
+	}
+	err := it.Err()
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RetrieveCard fetches a PaymentMethod and returns its card details.
+func (c *Client) RetrieveCard(ctx context.Context, pmID string) (*gateway.Card, error) {
+	ctx, done := c.startCall(ctx, "retrieve_payment_method", "payment_method_id", pmID)
+	params := &stripepkg.PaymentMethodParams{}
+	params.Context = ctx
+	pm, err := c.api.PaymentMethods.Get(pmID, params)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	card := &gateway.Card{PaymentMethodID: pm.ID}
+	if pm.Card != nil {
+		card.Brand = string(pm.Card.Brand)
+		card.Last4 = pm.Card.Last4
+		card.ExpMonth = int(pm.Card.ExpMonth)
+		card.ExpYear = int(pm.Card.ExpYear)
+	}
+	return card, nil
+}
+
+// CreateRefund refunds a captured PaymentIntent. Stripe only accepts a fixed set
+// of reasons, anything else is kept in metadata.reason.
+func (c *Client) CreateRefund(ctx context.Context, p gateway.RefundParams) (*gateway.Refund, error) {
+	ctx, done := c.startCall(ctx, "create_refund", "payment_intent_id", p.PaymentIntentID)
+	params := &stripepkg.RefundParams{
+		PaymentIntent: stripepkg.String(p.PaymentIntentID),
+	}
+	params.Context = ctx
+	setIdempotencyKey(ctx, &params.Params, "refund")
+	if p.Amount > 0 {
+		params.Amount = stripepkg.Int64(p.Amount)
+	}
+	if p.ReferenceID != "" {
+		params.AddMetadata("refund_id", p.ReferenceID)
+	}
+	switch stripepkg.RefundReason(p.Reason) {
+	case stripepkg.RefundReasonDuplicate, stripepkg.RefundReasonFraudulent, stripepkg.RefundReasonRequestedByCustomer:
+		params.Reason = stripepkg.String(p.Reason)
+	default:
+		if p.Reason != "" {
+			params.AddMetadata("reason", p.Reason)
+		}
+	}
+	r, err := c.api.Refunds.New(params)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return ToRefund(r), nil
+}
+
+// setIdempotencyKey forwards the request's idempotency key to Stripe. The
+// operation suffix keeps keys distinct when one request makes several calls.
+func setIdempotencyKey(ctx context.Context, params *stripepkg.Params, op string) {
+	if key := gateway.IdempotencyKey(ctx); key != "" {
+		params.SetIdempotencyKey(key + ":" + op)
+	}
+}
+
+// ToPaymentIntent converts a Stripe PaymentIntent, e.g. one received in a webhook.
+func ToPaymentIntent(pi *stripepkg.PaymentIntent) *gateway.PaymentIntent {
+	out := &gateway.PaymentIntent{
+		ID:               pi.ID,
+		ClientSecret:     pi.ClientSecret,
+		Status:           string(pi.Status),
+		Amount:           pi.Amount,
+		AmountCapturable: pi.AmountCapturable,
+		AmountReceived:   pi.AmountReceived,
+		Currency:         string(pi.Currency),
+		Metadata:         pi.Metadata,
+
+		CancellationReason: string(pi.CancellationReason),
+		Created:            time.Unix(pi.Created, 0),
+	}
+	if pi.Customer != nil {
+		out.CustomerID = pi.Customer.ID
+	}
+	if at := captureBefore(pi); at > 0 {
+		out.CaptureBefore = time.Unix(at, 0)
+	}
+	return out
+}
+
+// captureBefore reads latest_charge.payment_method_details.card.capture_before,
+// which this stripe-go version does not map. It is only present in a response
+// that expanded latest_charge.
+func captureBefore(pi *stripepkg.PaymentIntent) int64 {
+	if pi.LastResponse == nil || len(pi.LastResponse.RawJSON) == 0 {
+		return 0
+	}
+	var raw struct {
+		LatestCharge json.RawMessage `json:"latest_charge"`
+	}
+	if err := json.Unmarshal(pi.LastResponse.RawJSON, &raw); err != nil || len(raw.LatestCharge) == 0 || raw.LatestCharge[0] != '{' {
+		return 0
+	}
+	var charge struct {
+		PaymentMethodDetails struct {
+			Card struct {
+				CaptureBefore int64 `json:"capture_before"`
+			} `json:"card"`
+		} `json:"payment_method_details"`
+	}
+	if err := json.Unmarshal(raw.LatestCharge, &charge); err != nil {
+		return 0
+	}
+	return charge.PaymentMethodDetails.Card.CaptureBefore
+}
+
+func toSetupIntent(si *stripepkg.SetupIntent) *gateway.SetupIntent {
+	out := &gateway.SetupIntent{
+		ID:           si.ID,
+		ClientSecret: si.ClientSecret,
+		Status:       string(si.Status),
+	}
+	if si.Customer != nil {
+		out.CustomerID = si.Customer.ID
+	}
+	return out
+}
+
+// ToRefund converts a Stripe Refund, e.g. one received in a webhook.
+// A free-form reason kept in metadata takes precedence over Stripe's enum.
+func ToRefund(r *stripepkg.Refund) *gateway.Refund {
+	out := &gateway.Refund{
+		ID:          r.ID,
+		Amount:      r.Amount,
+		Currency:    string(r.Currency),
+		Status:      string(r.Status),
+		Reason:      r.Metadata["reason"],
+		ReferenceID: r.Metadata["refund_id"],
+	}
+	if r.PaymentIntent != nil {
+		out.PaymentIntentID = r.PaymentIntent.ID
+	}
+	if out.Reason == "" {
+		out.Reason = string(r.Reason)
+	}
+	return out
 }
@@ -0,0 +1,205 @@
+package stripeadapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	stripepkg "github.com/stripe/stripe-go/v74"
+
+	"Payment-service/internal/gateway"
+)
+
+var _ gateway.ConnectGateway = (*Client)(nil)
+
+// CreateConnectedAccount creates an Express account for the host with
+// the transfers capability requested; metadata.host_id links it back.
+func (c *Client) CreateConnectedAccount(ctx context.Context, hostID, email string) (*gateway.ConnectedAccount, error) {
+	ctx, done := c.startCall(ctx, "create_account", "host_id", hostID)
+	params := &stripepkg.AccountParams{
+		Type: stripepkg.String(string(stripepkg.AccountTypeExpress)),
+		Capabilities: &stripepkg.AccountCapabilitiesParams{
+			Transfers: &stripepkg.AccountCapabilitiesTransfersParams{Requested: stripepkg.Bool(true)},
+		},
+	}
+	if email != "" {
+		params.Email = stripepkg.String(email)
+	}
+	params.Context = ctx
+	setIdempotencyKey(ctx, &params.Params, "account")
+	params.AddMetadata("host_id", hostID)
+	acct, err := c.api.Accounts.New(params)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return ToConnectedAccount(acct), nil
+}
+
+// RetrieveConnectedAccount fetches an account to refresh its onboarding state.
+func (c *Client) RetrieveConnectedAccount(ctx context.Context, accountID string) (*gateway.ConnectedAccount, error) {
+	ctx, done := c.startCall(ctx, "retrieve_account", "stripe_account_id", accountID)
+	params := &stripepkg.AccountParams{}
+	params.Context = ctx
+	acct, err := c.api.Accounts.GetByID(accountID, params)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return ToConnectedAccount(acct), nil
+}
+
+// CreateAccountLink creates a one-time onboarding link for the account.
+func (c *Client) CreateAccountLink(ctx context.Context, accountID, refreshURL, returnURL string) (*gateway.AccountLink, error) {
+	ctx, done := c.startCall(ctx, "create_account_link", "stripe_account_id", accountID)
+	params := &stripepkg.AccountLinkParams{
+		Account:    stripepkg.String(accountID),
+		RefreshURL: stripepkg.String(refreshURL),
+		ReturnURL:  stripepkg.String(returnURL),
+		Type:       stripepkg.String(string(stripepkg.AccountLinkTypeAccountOnboarding)),
+	}
+	params.Context = ctx
+	link, err := c.api.AccountLinks.New(params)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return &gateway.AccountLink{URL: link.URL, ExpiresAt: time.Unix(link.ExpiresAt, 0)}, nil
+}
+
+// CreateTransfer sends money to a connected account. With PaymentIntentID set
+// the transfer is funded by that PaymentIntent's charge (source_transaction)
+// and grouped with it, so it can be made before the charge funds are available.
+func (c *Client) CreateTransfer(ctx context.Context, p gateway.TransferParams) (*gateway.Transfer, error) {
+	var chargeID string
+	if p.PaymentIntentID != "" {
+		var err error
+		if chargeID, err = c.latestCharge(ctx, p.PaymentIntentID); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, done := c.startCall(ctx, "create_transfer", "stripe_account_id", p.AccountID, "payment_intent_id", p.PaymentIntentID)
+	params := &stripepkg.TransferParams{
+		Amount:      stripepkg.Int64(p.Amount),
+		Currency:    stripepkg.String(p.Currency),
+		Destination: stripepkg.String(p.AccountID),
+	}
+	params.Context = ctx
+	if p.IdempotencyKey != "" {
+		params.SetIdempotencyKey(p.IdempotencyKey)
+	}
+	if p.PaymentIntentID != "" {
+		params.SourceTransaction = stripepkg.String(chargeID)
+		params.TransferGroup = stripepkg.String(p.PaymentIntentID)
+		params.AddMetadata("payment_intent_id", p.PaymentIntentID)
+	}
+	t, err := c.api.Transfers.New(params)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	out := &gateway.Transfer{ID: t.ID, Amount: t.Amount, Currency: string(t.Currency)}
+	if t.Destination != nil {
+		out.AccountID = t.Destination.ID
+	}
+	return out, nil
+}
+
+// CreateTransferReversal takes money back from a connected account, e.g.
+// when the payment it was paid from is refunded.
+func (c *Client) CreateTransferReversal(ctx context.Context, p gateway.TransferReversalParams) (*gateway.TransferReversal, error) {
+	ctx, done := c.startCall(ctx, "create_transfer_reversal", "stripe_transfer_id", p.TransferID)
+	params := &stripepkg.TransferReversalParams{
+		ID:     stripepkg.String(p.TransferID),
+		Amount: stripepkg.Int64(p.Amount),
+	}
+	params.Context = ctx
+	if p.IdempotencyKey != "" {
+		params.SetIdempotencyKey(p.IdempotencyKey)
+	}
+	r, err := c.api.TransferReversals.New(params)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return &gateway.TransferReversal{ID: r.ID, TransferID: p.TransferID, Amount: r.Amount, Currency: string(r.Currency)}, nil
+}
+
+// latestCharge returns the ID of the charge that captured the PaymentIntent.
+func (c *Client) latestCharge(ctx context.Context, paymentIntentID string) (string, error) {
+	ctx, done := c.startCall(ctx, "retrieve_payment_intent", "payment_intent_id", paymentIntentID)
+	params := &stripepkg.PaymentIntentParams{}
+	params.Context = ctx
+	pi, err := c.api.PaymentIntents.Get(paymentIntentID, params)
+	done(err)
+	if err != nil {
+		return "", err
+	}
+	if pi.LatestCharge == nil || pi.LatestCharge.ID == "" {
+		return "", fmt.Errorf("payment intent %s has no charge", paymentIntentID)
+	}
+	return pi.LatestCharge.ID, nil
+}
+
+// GetAccountBalance reads the balance of a connected account.
+func (c *Client) GetAccountBalance(ctx context.Context, accountID string) (*gateway.AccountBalance, error) {
+	ctx, done := c.startCall(ctx, "retrieve_balance", "stripe_account_id", accountID)
+	params := &stripepkg.BalanceParams{}
+	params.Context = ctx
+	params.SetStripeAccount(accountID)
+	b, err := c.api.Balance.Get(params)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return &gateway.AccountBalance{Available: toMoney(b.Available), Pending: toMoney(b.Pending)}, nil
+}
+
+// ListPayouts returns one page of the connected account's payouts.
+func (c *Client) ListPayouts(ctx context.Context, accountID string, limit int) ([]gateway.Payout, error) {
+	ctx, done := c.startCall(ctx, "list_payouts", "stripe_account_id", accountID)
+	params := &stripepkg.PayoutListParams{}
+	params.Context = ctx
+	params.Limit = stripepkg.Int64(int64(limit))
+	params.Single = true
+	params.SetStripeAccount(accountID)
+	it := c.api.Payouts.List(params)
+	var out []gateway.Payout
+	for it.Next() {
+		p := it.Payout()
+		out = append(out, gateway.Payout{
+			ID:          p.ID,
+			Amount:      p.Amount,
+			Currency:    string(p.Currency),
+			Status:      string(p.Status),
+			ArrivalDate: time.Unix(p.ArrivalDate, 0),
+			CreatedAt:   time.Unix(p.Created, 0),
+		})
+	}
+	err := it.Err()
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ToConnectedAccount converts a Stripe Account, e.g. one received in an account.updated webhook.
+func ToConnectedAccount(a *stripepkg.Account) *gateway.ConnectedAccount {
+	return &gateway.ConnectedAccount{
+		ID:               a.ID,
+		HostID:           a.Metadata["host_id"],
+		ChargesEnabled:   a.ChargesEnabled,
+		PayoutsEnabled:   a.PayoutsEnabled,
+		DetailsSubmitted: a.DetailsSubmitted,
+	}
+}
+
+func toMoney(amounts []*stripepkg.Amount) []gateway.Money {
+	out := make([]gateway.Money, 0, len(amounts))
+	for _, a := range amounts {
+		out = append(out, gateway.Money{Amount: a.Amount, Currency: string(a.Currency)})
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package stripeadapter
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	stripepkg "github.com/stripe/stripe-go/v74"
+)
+
+var (
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stripe_api_requests_total",
+		Help: "Stripe API calls by operation and outcome: ok or the Stripe error type.",
+	}, []string{"operation", "outcome"})
+
+	apiDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "stripe_api_request_duration_seconds",
+		Help:    "Latency of Stripe API calls by operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+)
+
+// observe records a finished Stripe API call.
+func observe(op string, start time.Time, err error) {
+	apiDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+		var stripeErr *stripepkg.Error
+		if errors.As(err, &stripeErr) && stripeErr.Type != "" {
+			outcome = string(stripeErr.Type)
+		}
+	}
+	apiRequests.WithLabelValues(op, outcome).Inc()
+}
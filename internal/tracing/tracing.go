@@ -0,0 +1,81 @@
+// Package tracing настраивает OpenTelemetry: глобальный TracerProvider,
+// экспорт спанов и W3C-пропагацию (traceparent/tracestate) между сервисами.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName — service.name в ресурсе спанов и имя спанов HTTP-сервера
+const ServiceName = "payment-service"
+
+// Допустимые значения Options.Exporter
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Options — настройки экспорта спанов
+type Options struct {
+	Exporter     string  // none, otlp или stdout
+	OTLPEndpoint string  // URL OTLP/HTTP-коллектора; пусто — OTEL_EXPORTER_OTLP_ENDPOINT или localhost:4318
+	SampleRatio  float64 // доля трассировок, которые начинаются в этом сервисе
+}
+
+// Setup регистрирует глобальный TracerProvider и пропагатор. Возвращённую
+// функцию нужно вызвать при остановке, чтобы отправить оставшиеся спаны.
+// С ExporterNone спаны не пишутся, но traceparent по-прежнему пробрасывается.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var httpOpts []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			httpOpts = append(httpOpts, otlptracehttp.WithEndpointURL(opts.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, httpOpts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Решение вызывающего сервиса о сэмплировании уважается
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
@@ -0,0 +1,72 @@
+package userclient
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// userCache — LRU-кэш пользователей по email с TTL.
+// Просроченные записи не удаляются сразу: ими можно ответить, пока User-service недоступен.
+type userCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // от недавно использованных к давно
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key       string
+	user      User
+	expiresAt time.Time
+}
+
+func newUserCache(size int, ttl time.Duration) *userCache {
+	return &userCache{size: size, ttl: ttl, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func cacheKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// get возвращает пользователя и признак того, что запись ещё не просрочена.
+func (c *userCache) get(email string) (user User, fresh, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[cacheKey(email)]
+	if !ok {
+		return User{}, false, false
+	}
+	c.order.MoveToFront(el)
+	e := el.Value.(*cacheEntry)
+	return e.user, time.Now().Before(e.expiresAt), true
+}
+
+func (c *userCache) put(email string, user User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey(email)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.user, e.expiresAt = user, time.Now().Add(c.ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, user: user, expiresAt: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *userCache) remove(email string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[cacheKey(email)]; ok {
+		c.order.Remove(el)
+		delete(c.items, cacheKey(email))
+	}
+}
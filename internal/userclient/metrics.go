@@ -0,0 +1,24 @@
+package userclient
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "userclient_cache_lookups_total",
+		Help: "User lookups by cache result: hit, miss or stale (served while User-service is unavailable).",
+	}, []string{"result"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "userclient_request_duration_seconds",
+		Help:    "Latency of requests to User-service by outcome: ok, not_found or error.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	breakerOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "userclient_circuit_open",
+		Help: "1 while the circuit breaker to User-service is open.",
+	})
+)
@@ -3,9 +3,21 @@ package userclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var (
+	// ErrNotFound — пользователя с таким email нет
+	ErrNotFound = errors.New("user not found")
+	// ErrUnavailable — User-service не ответил (сеть, таймаут, 5xx или открыт circuit breaker)
+	ErrUnavailable = errors.New("user service unavailable")
 )
 
 // User — структура, ожидаемая от User-service
@@ -18,40 +30,162 @@ type User struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// Options — настройки кэша и отказоустойчивости; нулевые поля заменяются значениями по умолчанию
+type Options struct {
+	CacheSize        int           // сколько пользователей держать в кэше (10000)
+	CacheTTL         time.Duration // сколько запись считается свежей (5m)
+	MaxAttempts      int           // попыток на один запрос (3)
+	RetryBase        time.Duration // базовая пауза между попытками, растёт вдвое (100ms)
+	BreakerThreshold int           // отказов подряд до размыкания (5)
+	BreakerCooldown  time.Duration // сколько не ходить в User-service после размыкания (30s)
+}
+
+func (o Options) withDefaults() Options {
+	if o.CacheSize <= 0 {
+		o.CacheSize = 10000
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = 5 * time.Minute
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 3
+	}
+	if o.RetryBase <= 0 {
+		o.RetryBase = 100 * time.Millisecond
+	}
+	if o.BreakerThreshold <= 0 {
+		o.BreakerThreshold = 5
+	}
+	if o.BreakerCooldown <= 0 {
+		o.BreakerCooldown = 30 * time.Second
+	}
+	return o
+}
+
 // Client — HTTP клиент для связи с User-service
 type Client struct {
 	BaseURL    string
 	HTTPClient *http.Client
+
+	opts    Options
+	cache   *userCache
+	breaker *breaker
 }
 
 // New создаёт новый клиент
-func New(baseURL string) *Client {
+func New(baseURL string, opts Options) *Client {
+	opts = opts.withDefaults()
 	return &Client{
-		BaseURL:    baseURL,
-		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: 5 * time.Second,
+			// Спан на каждый запрос и W3C traceparent в заголовках
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		opts:    opts,
+		cache:   newUserCache(opts.CacheSize, opts.CacheTTL),
+		breaker: newBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
 	}
 }
 
-// GetByEmail — отправляет GET-запрос на /api/users/by-email?email=...
+// GetByEmail возвращает пользователя из кэша или запрашивает
+// /api/users/by-email?email=... с повторами. Пока User-service недоступен,
+// отвечает просроченной записью кэша, если она есть.
 func (c *Client) GetByEmail(ctx context.Context, email string) (User, error) {
-	url := fmt.Sprintf("%s/api/users/by-email?email=%s", c.BaseURL, email)
+	cached, fresh, ok := c.cache.get(email)
+	if ok && fresh {
+		cacheLookups.WithLabelValues("hit").Inc()
+		return cached, nil
+	}
+
+	user, err := c.fetchWithRetry(ctx, email)
+	switch {
+	case err == nil:
+		cacheLookups.WithLabelValues("miss").Inc()
+		c.cache.put(email, user)
+		return user, nil
+	case errors.Is(err, ErrNotFound):
+		cacheLookups.WithLabelValues("miss").Inc()
+		c.cache.remove(email)
+		return User{}, err
+	case ok && errors.Is(err, ErrUnavailable):
+		cacheLookups.WithLabelValues("stale").Inc()
+		return cached, nil
+	default:
+		cacheLookups.WithLabelValues("miss").Inc()
+		return User{}, err
+	}
+}
+
+// fetchWithRetry повторяет запрос при ErrUnavailable с экспоненциальной паузой и jitter.
+func (c *Client) fetchWithRetry(ctx context.Context, email string) (User, error) {
+	var err error
+	for attempt := 0; attempt < c.opts.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			// Full jitter: случайная пауза до base·2^attempt, чтобы реплики не повторяли синхронно
+			delay := time.Duration(rand.Int63n(int64(c.opts.RetryBase << attempt)))
+			select {
+			case <-ctx.Done():
+				return User{}, fmt.Errorf("%w: %v", ErrUnavailable, ctx.Err())
+			case <-time.After(delay):
+			}
+		}
+		if !c.breaker.allow() {
+			return User{}, fmt.Errorf("%w: circuit open", ErrUnavailable)
+		}
+		var user User
+		user, err = c.fetch(ctx, email)
+		if ctx.Err() != nil {
+			// Запрос отменил наш клиент — User-service тут ни при чём
+			c.breaker.release()
+			return User{}, fmt.Errorf("%w: %v", ErrUnavailable, ctx.Err())
+		}
+		if errors.Is(err, ErrUnavailable) {
+			c.breaker.failure()
+			continue
+		}
+		// 404 — нормальный ответ сервиса, размыкать из-за него не нужно
+		c.breaker.success()
+		return user, err
+	}
+	return User{}, err
+}
+
+// fetch делает один запрос к User-service
+func (c *Client) fetch(ctx context.Context, email string) (user User, err error) {
+	start := time.Now()
+	defer func() {
+		outcome := "ok"
+		switch {
+		case errors.Is(err, ErrNotFound):
+			outcome = "not_found"
+		case err != nil:
+			outcome = "error"
+		}
+		requestDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	u := fmt.Sprintf("%s/api/users/by-email?email=%s", c.BaseURL, url.QueryEscape(email))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return User{}, fmt.Errorf("create request: %w", err)
 	}
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return User{}, fmt.Errorf("request failed: %w", err)
+		return User{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return User{}, ErrNotFound
+	case resp.StatusCode >= http.StatusInternalServerError, resp.StatusCode == http.StatusTooManyRequests:
+		return User{}, fmt.Errorf("%w: %s", ErrUnavailable, resp.Status)
+	case resp.StatusCode != http.StatusOK:
 		return User{}, fmt.Errorf("unexpected status: %s", resp.Status)
 	}
 
-	var user User
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
 		return User{}, fmt.Errorf("decode response: %w", err)
 	}
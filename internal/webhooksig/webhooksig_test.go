@@ -0,0 +1,84 @@
+package webhooksig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "whsec_test"
+
+func TestSignVerify(t *testing.T) {
+	payload := []byte(`{"type":"payment.status_changed"}`)
+	now := time.Unix(1700000000, 0)
+	signed := Sign(testSecret, payload, now)
+	ts := now.Unix()
+
+	tests := []struct {
+		name    string
+		secret  string
+		payload []byte
+		header  string
+		now     time.Time
+		want    error
+	}{
+		{"valid", testSecret, payload, signed, now, nil},
+		{"within tolerance", testSecret, payload, signed, now.Add(DefaultTolerance), nil},
+		{"clock skew within tolerance", testSecret, payload, signed, now.Add(-DefaultTolerance), nil},
+		{"too old", testSecret, payload, signed, now.Add(DefaultTolerance + time.Second), ErrTooOld},
+		{"from the future", testSecret, payload, signed, now.Add(-DefaultTolerance - time.Second), ErrTooOld},
+		{"wrong secret", "whsec_other", payload, signed, now, ErrNoValidSignature},
+		{"tampered payload", testSecret, []byte(`{"type":"refund"}`), signed, now, ErrNoValidSignature},
+		{"spaces after commas", testSecret, payload, strings.ReplaceAll(signed, ",", ", "), now, nil},
+		{
+			"one of several v1 signatures matches (secret rotation)",
+			testSecret, payload,
+			fmt.Sprintf("t=%d,v1=%s,v1=%s", ts, computeSignature("whsec_old", payload, ts), computeSignature(testSecret, payload, ts)),
+			now, nil,
+		},
+		{
+			"no v1 signature matches",
+			testSecret, payload,
+			fmt.Sprintf("t=%d,v1=%s,v1=deadbeef", ts, computeSignature("whsec_old", payload, ts)),
+			now, ErrNoValidSignature,
+		},
+		{"unknown schemes are ignored", testSecret, payload, signed + ",v0=legacy", now, nil},
+		{"missing timestamp", testSecret, payload, "v1=" + computeSignature(testSecret, payload, ts), now, ErrInvalidHeader},
+		{"missing signature", testSecret, payload, fmt.Sprintf("t=%d", ts), now, ErrInvalidHeader},
+		{"malformed timestamp", testSecret, payload, "t=yesterday,v1=abc", now, ErrInvalidHeader},
+		{"malformed part", testSecret, payload, signed + ",garbage", now, ErrInvalidHeader},
+		{"empty header", testSecret, payload, "", now, ErrInvalidHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.payload, tt.header, DefaultTolerance, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignFormat(t *testing.T) {
+	header := Sign(testSecret, []byte("{}"), time.Unix(1700000000, 0))
+	if !strings.HasPrefix(header, "t=1700000000,v1=") {
+		t.Errorf("header = %q, want t=<unix seconds>,v1=<hex>", header)
+	}
+	// HMAC-SHA256 в hex — 64 символа
+	if sig := header[strings.Index(header, "v1=")+3:]; len(sig) != 64 {
+		t.Errorf("signature %q has %d characters, want 64", sig, len(sig))
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if !strings.HasPrefix(a, "whsec_") || a == b {
+		t.Errorf("GenerateSecret returned %q and %q, want distinct whsec_ secrets", a, b)
+	}
+}
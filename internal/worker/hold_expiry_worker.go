@@ -0,0 +1,30 @@
+// internal/worker/hold_expiry_worker.go
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"Payment-service/internal/service"
+)
+
+// HoldExpiryWorker следит за сроком авторизации незахваченных депозитов.
+type HoldExpiryWorker struct {
+	svc      service.HoldExpiryService
+	interval time.Duration
+}
+
+// NewHoldExpiryWorker конструктор
+func NewHoldExpiryWorker(svc service.HoldExpiryService, interval time.Duration) *HoldExpiryWorker {
+	return &HoldExpiryWorker{svc: svc, interval: interval}
+}
+
+// Run обрабатывает истекающие депозиты каждые interval.
+func (w *HoldExpiryWorker) Run(ctx context.Context) {
+	runEvery(ctx, w.interval, func(ctx context.Context) {
+		if _, err := w.svc.ProcessHolds(ctx); err != nil {
+			slog.ErrorContext(ctx, "deposit hold expiry failed", "error", err)
+		}
+	})
+}
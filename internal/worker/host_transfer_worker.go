@@ -0,0 +1,47 @@
+// internal/worker/host_transfer_worker.go
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"Payment-service/internal/service"
+)
+
+// hostTransferBatch — сколько переводов забираем за один проход
+const hostTransferBatch = 50
+
+// HostTransferWorker переводит хостам их долю списанных платежей и депозитов
+// и возвращает с их аккаунтов переплату после возвратов.
+type HostTransferWorker struct {
+	svc      service.ConnectService
+	interval time.Duration
+}
+
+// NewHostTransferWorker конструктор
+func NewHostTransferWorker(svc service.ConnectService, interval time.Duration) *HostTransferWorker {
+	return &HostTransferWorker{svc: svc, interval: interval}
+}
+
+// Run выполняет ожидающие переводы и их возвраты каждые interval.
+func (w *HostTransferWorker) Run(ctx context.Context) {
+	runEvery(ctx, w.interval, func(ctx context.Context) {
+		drain(ctx, "host transfers", w.svc.TransferDue)
+		drain(ctx, "host transfer reversals", w.svc.ReverseDue)
+	})
+}
+
+// drain вызывает due, пока он забирает полные пачки
+func drain(ctx context.Context, what string, due func(ctx context.Context, limit int) (int, error)) {
+	for ctx.Err() == nil {
+		n, err := due(ctx, hostTransferBatch)
+		if err != nil {
+			slog.ErrorContext(ctx, what+" failed", "error", err)
+			return
+		}
+		if n < hostTransferBatch {
+			return
+		}
+	}
+}
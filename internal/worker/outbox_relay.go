@@ -0,0 +1,41 @@
+// internal/worker/outbox_relay.go
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"Payment-service/internal/service"
+)
+
+// outboxBatch — сколько событий забираем за один проход
+const outboxBatch = 100
+
+// OutboxRelay публикует события из outbox.
+type OutboxRelay struct {
+	svc      service.OutboxService
+	interval time.Duration
+}
+
+// NewOutboxRelay конструктор
+func NewOutboxRelay(svc service.OutboxService, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{svc: svc, interval: interval}
+}
+
+// Run опрашивает outbox каждые interval. За проход берётся по одному событию
+// на агрегат, поэтому проходы повторяются, пока есть что публиковать.
+func (w *OutboxRelay) Run(ctx context.Context) {
+	runEvery(ctx, w.interval, func(ctx context.Context) {
+		for ctx.Err() == nil {
+			n, err := w.svc.RelayDue(ctx, outboxBatch)
+			if err != nil {
+				slog.ErrorContext(ctx, "outbox relay failed", "error", err)
+				return
+			}
+			if n == 0 {
+				return
+			}
+		}
+	})
+}
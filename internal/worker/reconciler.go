@@ -0,0 +1,33 @@
+// internal/worker/reconciler.go
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"Payment-service/internal/repository"
+	"Payment-service/internal/service"
+)
+
+// Reconciler периодически сверяет платежи и депозиты со шлюзом.
+type Reconciler struct {
+	svc      service.ReconcileService
+	interval time.Duration
+	window   time.Duration
+}
+
+// NewReconciler конструктор. window — за какой срок сверяются PaymentIntents.
+func NewReconciler(svc service.ReconcileService, interval, window time.Duration) *Reconciler {
+	return &Reconciler{svc: svc, interval: interval, window: window}
+}
+
+// Run сверяет PaymentIntents за последние window каждые interval.
+func (w *Reconciler) Run(ctx context.Context) {
+	runEvery(ctx, w.interval, func(ctx context.Context) {
+		since := time.Now().Add(-w.window)
+		if _, err := w.svc.Reconcile(ctx, since, repository.ReconcileTriggerSchedule); err != nil {
+			slog.ErrorContext(ctx, "reconciliation failed", "error", err)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+// internal/worker/stripe_event_retrier.go
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"Payment-service/internal/service"
+)
+
+// stripeEventBatch — сколько событий забираем за один проход
+const stripeEventBatch = 50
+
+// StripeEventRetrier повторяет обработку упавших событий Stripe с экспоненциальной задержкой.
+type StripeEventRetrier struct {
+	svc      service.StripeEventService
+	interval time.Duration
+}
+
+// NewStripeEventRetrier конструктор
+func NewStripeEventRetrier(svc service.StripeEventService, interval time.Duration) *StripeEventRetrier {
+	return &StripeEventRetrier{svc: svc, interval: interval}
+}
+
+// Run опрашивает журнал событий каждые interval.
+func (w *StripeEventRetrier) Run(ctx context.Context) {
+	runEvery(ctx, w.interval, func(ctx context.Context) {
+		for ctx.Err() == nil {
+			n, err := w.svc.RetryDue(ctx, stripeEventBatch)
+			if err != nil {
+				slog.ErrorContext(ctx, "stripe event retry failed", "error", err)
+				return
+			}
+			if n < stripeEventBatch {
+				return
+			}
+		}
+	})
+}
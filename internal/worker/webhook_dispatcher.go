@@ -0,0 +1,40 @@
+// internal/worker/webhook_dispatcher.go
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"Payment-service/internal/service"
+)
+
+// webhookBatch — сколько доставок забираем за один проход
+const webhookBatch = 50
+
+// WebhookDispatcher отправляет исходящие webhooks подписчикам.
+type WebhookDispatcher struct {
+	svc      service.WebhookService
+	interval time.Duration
+}
+
+// NewWebhookDispatcher конструктор
+func NewWebhookDispatcher(svc service.WebhookService, interval time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{svc: svc, interval: interval}
+}
+
+// Run опрашивает очередь доставок каждые interval.
+func (w *WebhookDispatcher) Run(ctx context.Context) {
+	runEvery(ctx, w.interval, func(ctx context.Context) {
+		for ctx.Err() == nil {
+			n, err := w.svc.DispatchDue(ctx, webhookBatch)
+			if err != nil {
+				slog.ErrorContext(ctx, "webhook dispatch failed", "error", err)
+				return
+			}
+			if n < webhookBatch {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,26 @@
+// internal/worker/worker.go
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// Worker — фоновый процесс, работающий до отмены ctx
+type Worker interface {
+	Run(ctx context.Context)
+}
+
+// runEvery вызывает tick сразу и далее каждые interval, пока ctx не отменён.
+func runEvery(ctx context.Context, interval time.Duration, tick func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,18 +1,41 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/joho/godotenv"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"Payment-service/internal/config"
+	"Payment-service/internal/logging"
+	"Payment-service/internal/migrations"
 	"Payment-service/internal/routes"
 	"Payment-service/internal/storage"
+	"Payment-service/internal/tracing"
 )
 
 func main() {
+	// Подкоманда: payment-service migrate [up|down [N]|status]
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	// Подкоманда: payment-service reconcile [-window 72h]
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		runReconcile(os.Args[2:])
+		return
+	}
+
 	// 1) Загружаем конфиг
 	_ = godotenv.Load()
 	cfg, err := config.Load()
@@ -20,29 +43,105 @@ func main() {
 		log.Fatalf("config error: %v", err)
 	}
 
+	// 1.1) Структурный лог с маскированием секретов и PII. Он же становится
+	// логгером по умолчанию для slog и стандартного log.
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	logger, err := logging.New(os.Stdout, logging.Options{Level: level, Format: cfg.LogFormat})
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	// 1.2) Трассировка: спаны HTTP, Stripe, User-service и Postgres
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.OTLPEndpoint,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		fatal(logger, "tracing init error", err)
+	}
+
 	// 2) Инициализируем подключение к БД
 	store, err := storage.InitStore(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("db init error: %v", err)
+		fatal(logger, "db init error", err)
 	}
 	defer store.Close()
 
+	// 2.1) Опционально накатываем миграции
+	if cfg.AutoMigrate {
+		m, err := migrations.New(store.DB)
+		if err != nil {
+			fatal(logger, "load migrations", err)
+		}
+		n, err := m.Up(context.Background())
+		if err != nil {
+			fatal(logger, "migrate error", err)
+		}
+		logger.Info("migrations applied", "count", n)
+	}
+
+	// ctx отменяется по SIGINT/SIGTERM и останавливает фоновые воркеры
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 3) Настраиваем HTTP и роуты
-	r := gin.Default()
-	// Теперь передаём webhookSecret и stripeAPIKey
-	routes.RegisterAll(
-		r,
-		store,
-		cfg.StripeWebhookSecret,
-		cfg.StripeSecretKey,
-		cfg.JWTSecret,
-		cfg.UserServiceURL,
-	)
-	fmt.Println(">>> STRIPE_WEBHOOK_SECRET:", cfg.StripeWebhookSecret)
+	// Вместо стандартного логгера gin запросы пишет middleware.RequestLog
+	r := gin.New()
+	r.Use(gin.Recovery())
+	if err := routes.RegisterAll(ctx, r, store, cfg, logger); err != nil {
+		fatal(logger, "init error", err)
+	}
+
 	// 4) Запуск сервера
 	addr := fmt.Sprintf(":%d", cfg.Port)
-	log.Printf("🚀 Payment-service listening on %s\n", addr)
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("server error: %v", err)
+	srv := &http.Server{Addr: addr, Handler: r}
+	go func() {
+		logger.Info("🚀 Payment-service listening", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal(logger, "server error", err)
+		}
+	}()
+
+	// 4.1) Метрики Prometheus на отдельном порту: наружу он не публикуется,
+	// его читает только Prometheus из внутренней сети
+	var metricsSrv *http.Server
+	if cfg.MetricsPort != 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		metricsAddr := fmt.Sprintf(":%d", cfg.MetricsPort)
+		metricsSrv = &http.Server{Addr: metricsAddr, Handler: mux}
+		go func() {
+			logger.Info("metrics listening", "addr", metricsAddr)
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal(logger, "metrics server error", err)
+			}
+		}()
 	}
+
+	<-ctx.Done()
+	logger.Info("shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("shutdown error", "error", err)
+	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("metrics shutdown error", "error", err)
+		}
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("tracing shutdown error", "error", err)
+	}
+}
+
+// fatal пишет ошибку в структурный лог и завершает процесс.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
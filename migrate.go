@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"Payment-service/internal/config"
+	"Payment-service/internal/migrations"
+	"Payment-service/internal/storage"
+)
+
+// runMigrate реализует подкоманду `payment-service migrate [up|down [N]|status]`.
+func runMigrate(args []string) {
+	dbURL, err := config.LoadDatabaseURL()
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	store, err := storage.InitStore(dbURL)
+	if err != nil {
+		log.Fatalf("db init error: %v", err)
+	}
+	defer store.Close()
+
+	m, err := migrations.New(store.DB)
+	if err != nil {
+		log.Fatalf("load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			log.Fatalf("migrate up: %v", err)
+		}
+		log.Printf("applied %d migration(s)", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("migrate down: invalid step count %q", args[1])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+		log.Printf("rolled back %d migration(s)", n)
+	case "status":
+		list, err := m.Status(ctx)
+		if err != nil {
+			log.Fatalf("migrate status: %v", err)
+		}
+		for _, st := range list {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, applied)
+		}
+	default:
+		log.Fatalf("unknown migrate command %q (use up, down [N] or status)", cmd)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"Payment-service/internal/config"
+	"Payment-service/internal/logging"
+	"Payment-service/internal/repository"
+	"Payment-service/internal/routes"
+	"Payment-service/internal/storage"
+)
+
+// runReconcile реализует подкоманду `payment-service reconcile [-window 72h]`:
+// сверяет PaymentIntents за window со Stripe, исправляет расхождения,
+// сохраняет отчёт и печатает его.
+func runReconcile(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	window := fs.Duration("window", 0, "reconcile PaymentIntents created within this period (default RECONCILE_WINDOW)")
+	_ = fs.Parse(args)
+
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	// Лог идёт в stderr, чтобы не смешиваться с отчётом
+	logger, err := logging.New(os.Stderr, logging.Options{Level: level, Format: cfg.LogFormat})
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+
+	store, err := storage.InitStore(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("db init error: %v", err)
+	}
+	defer store.Close()
+
+	svc, err := routes.NewReconcileService(store, cfg, logger)
+	if err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+	if *window <= 0 {
+		*window = cfg.ReconcileWindow
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	r, err := svc.Reconcile(ctx, time.Now().Add(-*window), repository.ReconcileTriggerManual)
+	if err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+
+	fmt.Printf("report %d: matched %d, repaired %d, missing locally %d, missing remotely %d, unresolved %d\n",
+		r.ID, r.Matched, r.Repaired, r.MissingLocally, r.MissingRemotely, r.Unresolved)
+	for _, it := range r.Items {
+		fmt.Printf("%s\t%s\t%s\t%s -> %s\t%s\n",
+			it.Result, it.StripePIID, it.Kind, it.LocalStatus, it.RemoteStatus, it.Detail)
+	}
+}